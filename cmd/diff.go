@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/rastogiji/autodoc-grafana/pkg/parser"
+	flag "github.com/spf13/pflag"
+)
+
+var (
+	// diffFiles holds the positional arguments of the diff command: the old and
+	// new dashboard files, or the dashboard file compared at two git refs
+	diffFiles []string
+	// diffFromRef specifies the git ref of the old version of the dashboard file
+	diffFromRef string
+	// diffToRef specifies the git ref of the new version of the dashboard file,
+	// the working tree if empty
+	diffToRef string
+	// diffFormat specifies the format of the diff: markdown or json
+	diffFormat string
+	// diffOutput specifies the path of the file the diff is written to, stdout if empty
+	diffOutput string
+)
+
+// runDiff executes the diff command: it parses the diff flags, validates
+// them, configures the logger and compares the two dashboard versions.
+//
+// Parameters:
+//   - args: the command-line arguments following the diff command
+//
+// Returns an error if any step fails.
+func (r *runner) runDiff(args []string) error {
+	cli := flag.NewFlagSet("diff", flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(out, "Usage: %s diff [flags] OLD.json NEW.json\n       %s diff [flags] --from-ref REF [--to-ref REF] DASHBOARD.json\n", os.Args[0], os.Args[0])
+		cli.PrintDefaults()
+	}
+	cli.StringVar(&diffFromRef, "from-ref", "", "Git ref of the old version of the dashboard file, e.g. origin/main")
+	cli.StringVar(&diffToRef, "to-ref", "", "Git ref of the new version of the dashboard file (default: the working tree)")
+	cli.StringVar(&diffFormat, "format", "markdown", "Format of the diff: markdown (e.g. for a pull request comment) or json")
+	cli.StringVar(&diffOutput, "output", "", "Path to the file the diff is written to (default: stdout)")
+	cli.StringVar(&libraryPanels, "library-panels", "", "Path to a directory of exported library panel JSON models used to resolve library panel references")
+	cli.StringVar(&metricList, "metric-list", "", "Path to a file listing known metric names, one per line, used to expand metric name patterns")
+	cli.IntVar(&logLevel, "log-level", 0, "Debug: -4, Info: 0, Warn: 4, Error: 8 (default: Info)")
+	cli.BoolVar(&help, "help", false, "Show help message")
+
+	cli.Parse(args)
+	diffFiles = cli.Args()
+
+	if help {
+		cli.Usage()
+		return nil
+	}
+
+	if err := validateDiffFlagValues(); err != nil {
+		return err
+	}
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+		Level: slog.Level(logLevel),
+	})).With(
+		slog.Int("log-level", logLevel),
+		slog.Any("files", diffFiles),
+	)
+	slog.SetDefault(logger)
+
+	return r.diffProcessor()
+}
+
+// validateDiffFlagValues validates the flags and arguments of the diff
+// command. It checks that:
+//   - logLevel is one of the valid values: -4 (Debug), 0 (Info), 4 (Warn), 8 (Error)
+//   - two dashboard files are given, or one with --from-ref
+//   - --to-ref is only used with --from-ref
+//   - neither git ref starts with "-", which git would parse as an option
+//   - diffFormat is either markdown or json
+//
+// Returns an error if validation fails.
+func validateDiffFlagValues() error {
+	if logLevel != -4 && logLevel != 0 && logLevel != 4 && logLevel != 8 {
+		setupLog.Error("Invalid log level", slog.Int("log-level", logLevel), slog.String("valid_values", "Debug(-4), Info(0), Warn(4), Error(8)"))
+		return fmt.Errorf("invalid log level: %d", logLevel)
+	}
+
+	switch {
+	case diffFromRef != "" && len(diffFiles) != 1:
+		setupLog.Error("--from-ref requires one dashboard file", slog.Int("file-count", len(diffFiles)))
+		return errors.New("diff with --from-ref requires exactly one dashboard file")
+	case diffFromRef == "" && diffToRef != "":
+		setupLog.Error("--to-ref requires --from-ref")
+		return errors.New("--to-ref requires --from-ref")
+	case diffFromRef == "" && len(diffFiles) != 2:
+		setupLog.Error("diff requires two dashboard files", slog.Int("file-count", len(diffFiles)))
+		return errors.New("diff requires two dashboard files, or one dashboard file and --from-ref")
+	}
+
+	for _, ref := range []string{diffFromRef, diffToRef} {
+		if strings.HasPrefix(ref, "-") {
+			setupLog.Error("Invalid git ref", slog.String("ref", ref))
+			return fmt.Errorf("invalid git ref %q: refs must not start with -", ref)
+		}
+	}
+
+	if diffFormat != string(parser.FormatMarkdown) && diffFormat != string(parser.FormatJSON) {
+		setupLog.Error("Invalid diff format", slog.String("format", diffFormat), slog.String("valid_values", "markdown, json"))
+		return fmt.Errorf("invalid diff format: %s", diffFormat)
+	}
+	return nil
+}
+
+// processDiff documents the two versions of the dashboard and writes their
+// semantic diff.
+//
+// Returns an error if a version cannot be read or documented, or the diff
+// cannot be written.
+func processDiff() error {
+	opts, err := documentationOptions()
+	if err != nil {
+		return err
+	}
+
+	var from, to *parser.DashboardDoc
+	if diffFromRef != "" {
+		file := diffFiles[0]
+		if from, err = buildDocumentationAtRef(file, diffFromRef, opts); err != nil {
+			return err
+		}
+		if diffToRef != "" {
+			to, err = buildDocumentationAtRef(file, diffToRef, opts)
+		} else {
+			to, err = parser.BuildDocumentation(file, opts)
+		}
+		if err != nil {
+			return err
+		}
+	} else {
+		if from, err = parser.BuildDocumentation(diffFiles[0], opts); err != nil {
+			return err
+		}
+		if to, err = parser.BuildDocumentation(diffFiles[1], opts); err != nil {
+			return err
+		}
+	}
+
+	diff := parser.DiffDocumentation(from, to)
+	var buf bytes.Buffer
+	if err := parser.RenderDiff(&buf, diff, parser.Format(diffFormat)); err != nil {
+		return err
+	}
+	if diffOutput == "" {
+		_, err = out.Write(buf.Bytes())
+		return err
+	}
+	if err := os.WriteFile(diffOutput, buf.Bytes(), 0644); err != nil {
+		slog.Error("Error writing diff", slog.Any("error", err), slog.String("output", diffOutput))
+		return fmt.Errorf("error writing diff: %w", err)
+	}
+	slog.Info("Wrote dashboard diff", slog.String("output", diffOutput))
+	return nil
+}
+
+// buildDocumentationAtRef documents the version of a dashboard file at a git
+// ref. The file is named "<file>@<ref>" in the documentation model.
+//
+// Returns an error if the file cannot be read from git or documented.
+func buildDocumentationAtRef(file, ref string, opts parser.Options) (*parser.DashboardDoc, error) {
+	bs, err := gitShow(file, ref)
+	if err != nil {
+		return nil, err
+	}
+	return parser.BuildDocumentationFromBytes(file+"@"+ref, bs, opts)
+}
+
+// gitShow reads the content of a file at a git ref. The file path is resolved
+// relative to the current directory, like any other input path.
+//
+// Returns an error if git fails, e.g. if the ref or the file doesn't exist.
+func gitShow(file, ref string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", "show", ref+":./"+filepath.Base(file))
+	cmd.Dir = filepath.Dir(file)
+	cmd.Stderr = &stderr
+	bs, err := cmd.Output()
+	if err != nil {
+		slog.Error("Error reading file from git", slog.Any("error", err), slog.String("file", file), slog.String("ref", ref), slog.String("stderr", stderr.String()))
+		return nil, fmt.Errorf("error reading %s at git ref %s: %s: %w", file, ref, strings.TrimSpace(stderr.String()), err)
+	}
+	return bs, nil
+}
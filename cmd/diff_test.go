@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/rastogiji/autodoc-grafana/pkg/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	diffTestOldDashboard = `{"title": "API", "panels": [
+		{"id": 1, "type": "stat", "title": "Requests", "targets": [{"refId": "A", "expr": "sum(http_requests_total)"}]}
+	]}`
+	diffTestNewDashboard = `{"title": "API", "panels": [
+		{"id": 1, "type": "stat", "title": "Requests", "targets": [{"refId": "A", "expr": "sum(http_requests_total)"}]},
+		{"id": 2, "type": "stat", "title": "Errors", "targets": [{"refId": "A", "expr": "sum(http_errors_total)"}]}
+	]}`
+)
+
+func TestRunDiff(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          []string
+		expectError   bool
+		errorMsg      string
+		expectedFiles []string
+		expectedRef   string
+	}{
+		{
+			name:          "two files should call the diff processor",
+			args:          []string{"program", "diff", "old.json", "new.json"},
+			expectedFiles: []string{"old.json", "new.json"},
+		},
+		{
+			name:          "one file with a git ref should call the diff processor",
+			args:          []string{"program", "diff", "--from-ref", "origin/main", "dashboard.json"},
+			expectedFiles: []string{"dashboard.json"},
+			expectedRef:   "origin/main",
+		},
+		{
+			name:        "one file without a git ref should return error",
+			args:        []string{"program", "diff", "dashboard.json"},
+			expectError: true,
+			errorMsg:    "diff requires two dashboard files, or one dashboard file and --from-ref",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			diffFromRef, diffToRef, diffFormat, logLevel, help = "", "", "markdown", 0, false
+			os.Args = tc.args
+
+			called := false
+			runnerInstance := &runner{
+				fileProcessor: func() error {
+					t.Fatal("the file processor should not be called by the diff command")
+					return nil
+				},
+				diffProcessor: func() error {
+					called = true
+					return nil
+				},
+			}
+
+			err := runnerInstance.run()
+			if tc.expectError {
+				assert.ErrorContains(t, err, tc.errorMsg)
+				assert.False(t, called)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, called)
+			assert.Equal(t, tc.expectedFiles, diffFiles)
+			assert.Equal(t, tc.expectedRef, diffFromRef)
+		})
+	}
+}
+
+func TestValidateDiffFlagValues(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       []string
+		fromRef     string
+		toRef       string
+		format      string
+		logLevel    int
+		expectError bool
+		errorMsg    string
+	}{
+		{
+			name:   "two files should pass validation",
+			files:  []string{"old.json", "new.json"},
+			format: "markdown",
+		},
+		{
+			name:    "one file at two git refs should pass validation",
+			files:   []string{"dashboard.json"},
+			fromRef: "main",
+			toRef:   "HEAD",
+			format:  "json",
+		},
+		{
+			name:        "git ref with two files should return error",
+			files:       []string{"old.json", "new.json"},
+			fromRef:     "main",
+			format:      "markdown",
+			expectError: true,
+			errorMsg:    "diff with --from-ref requires exactly one dashboard file",
+		},
+		{
+			name:        "to-ref without from-ref should return error",
+			files:       []string{"dashboard.json"},
+			toRef:       "HEAD",
+			format:      "markdown",
+			expectError: true,
+			errorMsg:    "--to-ref requires --from-ref",
+		},
+		{
+			name:        "from-ref starting with a dash should return error",
+			files:       []string{"dashboard.json"},
+			fromRef:     "--output=/tmp/x",
+			format:      "markdown",
+			expectError: true,
+			errorMsg:    "invalid git ref",
+		},
+		{
+			name:        "to-ref starting with a dash should return error",
+			files:       []string{"dashboard.json"},
+			fromRef:     "main",
+			toRef:       "-p",
+			format:      "markdown",
+			expectError: true,
+			errorMsg:    "invalid git ref",
+		},
+		{
+			name:        "three files should return error",
+			files:       []string{"a.json", "b.json", "c.json"},
+			format:      "markdown",
+			expectError: true,
+			errorMsg:    "diff requires two dashboard files",
+		},
+		{
+			name:        "yaml format should return error",
+			files:       []string{"old.json", "new.json"},
+			format:      "yaml",
+			expectError: true,
+			errorMsg:    "invalid diff format: yaml",
+		},
+		{
+			name:        "invalid log level should return error",
+			files:       []string{"old.json", "new.json"},
+			format:      "markdown",
+			logLevel:    1,
+			expectError: true,
+			errorMsg:    "invalid log level: 1",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			diffFiles, diffFromRef, diffToRef, diffFormat, logLevel = tc.files, tc.fromRef, tc.toRef, tc.format, tc.logLevel
+
+			err := validateDiffFlagValues()
+			if tc.expectError {
+				assert.ErrorContains(t, err, tc.errorMsg)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestProcessDiff(t *testing.T) {
+	libraryPanels, metricList, templateFile, templateDir, strict, check = "", "", "", "", false, false
+
+	dir := t.TempDir()
+	oldFile := filepath.Join(dir, "old.json")
+	newFile := filepath.Join(dir, "new.json")
+	assert.NoError(t, os.WriteFile(oldFile, []byte(diffTestOldDashboard), 0644))
+	assert.NoError(t, os.WriteFile(newFile, []byte(diffTestNewDashboard), 0644))
+
+	t.Run("two files should write the markdown diff to stdout", func(t *testing.T) {
+		var buf bytes.Buffer
+		out = &buf
+		diffFiles, diffFromRef, diffToRef, diffFormat, diffOutput = []string{oldFile, newFile}, "", "", "markdown", ""
+
+		assert.NoError(t, processDiff())
+		assert.Contains(t, buf.String(), "| Added | Errors | Stat |")
+		assert.Contains(t, buf.String(), "- Added `http_errors_total`")
+	})
+
+	t.Run("json format should write the diff to the output file", func(t *testing.T) {
+		diffFiles, diffFromRef, diffToRef, diffFormat, diffOutput = []string{oldFile, newFile}, "", "", "json", filepath.Join(dir, "diff.json")
+
+		assert.NoError(t, processDiff())
+		bs, err := os.ReadFile(diffOutput)
+		assert.NoError(t, err)
+		var diff parser.DashboardDiff
+		assert.NoError(t, json.Unmarshal(bs, &diff))
+		assert.Equal(t, []string{"http_errors_total"}, diff.AddedMetrics)
+	})
+
+	t.Run("missing file should return error", func(t *testing.T) {
+		diffFiles, diffFromRef, diffToRef, diffFormat, diffOutput = []string{oldFile, filepath.Join(dir, "missing.json")}, "", "", "markdown", ""
+
+		assert.Error(t, processDiff())
+	})
+
+	t.Run("git refs should compare committed versions of the file", func(t *testing.T) {
+		repo := t.TempDir()
+		file := filepath.Join(repo, "dashboard.json")
+		git := func(args ...string) {
+			cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+			cmd.Dir = repo
+			output, err := cmd.CombinedOutput()
+			assert.NoError(t, err, string(output))
+		}
+		git("init", "--quiet")
+		assert.NoError(t, os.WriteFile(file, []byte(diffTestOldDashboard), 0644))
+		git("add", "dashboard.json")
+		git("commit", "--quiet", "-m", "old")
+		assert.NoError(t, os.WriteFile(file, []byte(diffTestNewDashboard), 0644))
+
+		var buf bytes.Buffer
+		out = &buf
+		diffFiles, diffFromRef, diffToRef, diffFormat, diffOutput = []string{file}, "HEAD", "", "markdown", ""
+		assert.NoError(t, processDiff())
+		assert.Contains(t, buf.String(), "Comparing `"+file+"@HEAD` with `"+file+"`.")
+		assert.Contains(t, buf.String(), "| Added | Errors | Stat |")
+
+		buf.Reset()
+		diffToRef = "HEAD"
+		assert.NoError(t, processDiff())
+		assert.Contains(t, buf.String(), "No semantic changes.")
+
+		diffFromRef = "no-such-ref"
+		assert.ErrorContains(t, processDiff(), "error reading "+file+" at git ref no-such-ref")
+	})
+}
@@ -1,20 +1,25 @@
 // Package main provides a command-line tool for automatically generating
 // documentation from Grafana dashboard JSON files. It supports processing
 // single files, directories, or glob patterns and outputs structured
-// markdown documentation.
+// markdown documentation. The diff command reports the semantic changes
+// between two versions of a dashboard.
 package main
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/hashicorp/go-multierror"
+	"github.com/rastogiji/autodoc-grafana/pkg/grafana"
 	"github.com/rastogiji/autodoc-grafana/pkg/parser"
+	"github.com/rastogiji/autodoc-grafana/pkg/templates"
 	"github.com/rastogiji/autodoc-grafana/pkg/utils"
 	flag "github.com/spf13/pflag"
 )
@@ -25,10 +30,57 @@ var (
 	commit  = "unknown" // Git commit hash
 	date    = "unknown" // Build date
 
-	// input specifies the path to dashboard file, directory, or glob pattern
-	input string
-	// output specifies the path to output directory where markdown files will be generated
+	// inputs specifies the paths to dashboard files, directories, or glob
+	// patterns, or - to read a dashboard from stdin
+	inputs []string
+	// output specifies the path to output directory where markdown files will
+	// be generated, or - to write the documentation to stdout
 	output string
+	// recursive makes a directory input include the JSON files of its subdirectories
+	recursive bool
+	// includes lists the glob patterns an input file must match to be documented
+	includes []string
+	// excludes lists the glob patterns of the input files that are not documented
+	excludes []string
+	// naming specifies how documentation files are named: basename, uid or title
+	naming string
+	// fromGrafana specifies the URL of a Grafana instance whose dashboards are documented
+	fromGrafana string
+	// grafanaToken specifies the API or service account token used to authenticate to Grafana
+	grafanaToken string
+	// grafanaUser specifies the user name used to authenticate to Grafana with basic auth
+	grafanaUser string
+	// grafanaPassword specifies the password used to authenticate to Grafana with basic auth
+	grafanaPassword string
+	// grafanaFolders lists the UIDs of the Grafana folders whose dashboards are documented
+	grafanaFolders []string
+	// grafanaTags lists the tags of the Grafana dashboards that are documented
+	grafanaTags []string
+	// grafanaQuery specifies the title search query of the Grafana dashboards that are documented
+	grafanaQuery string
+	// libraryPanels specifies the path to a directory of exported library panel JSON models
+	libraryPanels string
+	// metricList specifies the path to a file listing the known metric names, one per line
+	metricList string
+	// strict makes any query that cannot be parsed fail the run instead of being
+	// documented as a warning
+	strict bool
+	// format specifies the output format of the documentation: markdown, json, yaml or html
+	format string
+	// templateFile specifies the path of a custom template replacing the default markdown template
+	templateFile string
+	// templateDir specifies the path of a directory of partial templates used by the custom template
+	templateDir string
+	// inject specifies the path of a markdown file the documentation is injected
+	// into, between its BEGIN_AUTODOC and END_AUTODOC markers
+	inject string
+	// check compares the generated documentation with the existing files and
+	// fails if they differ, without writing anything
+	check bool
+	// report specifies the path of the diagnostics report file, if any
+	report string
+	// reportFormat specifies the format of the diagnostics report: json or sarif
+	reportFormat string
 	// logLevel sets the logging level (Debug: -4, Info: 0, Warn: 4, Error: 8)
 	logLevel int
 	// help indicates whether to show the help message
@@ -39,6 +91,17 @@ var (
 	setupLog = slog.New(slog.NewJSONHandler(os.Stdout, nil))
 	// out is the output writer, configurable for testing
 	out io.Writer = os.Stdout
+	// in is the reader a dashboard is read from with --input -, configurable for testing
+	in io.Reader = os.Stdin
+)
+
+const (
+	// stdinPath is the input path reading a dashboard from stdin, and the
+	// output path writing its documentation to stdout
+	stdinPath = "-"
+	// stdinFile is the file name of a dashboard read from stdin, which names
+	// its documentation file with the basename naming scheme
+	stdinFile = "stdin.json"
 )
 
 // runner encapsulates the application's main execution logic with
@@ -47,6 +110,9 @@ type runner struct {
 	// fileProcessor is the function that handles the actual file processing logic.
 	// It can be injected for testing purposes.
 	fileProcessor func() error
+	// diffProcessor is the function that handles the diff command logic.
+	// It can be injected for testing purposes.
+	diffProcessor func() error
 }
 
 // main is the entry point of the application. It initializes the runner
@@ -54,6 +120,7 @@ type runner struct {
 func main() {
 	autodocRunner := runner{
 		fileProcessor: processFiles,
+		diffProcessor: processDiff,
 	}
 
 	if err := autodocRunner.run(); err != nil {
@@ -62,17 +129,49 @@ func main() {
 }
 
 // run executes the main application logic including command-line flag parsing,
-// validation, logger configuration, and file processing. It returns an error
-// if any step fails.
+// validation, logger configuration, and file processing. The diff command is
+// dispatched to runDiff. It returns an error if any step fails.
 func (r *runner) run() error {
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		return r.runDiff(os.Args[2:])
+	}
+
 	cli := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
-	cli.StringVar(&input, "input", "", "Path to dashboard file, directory, or glob pattern (e.g., dashboard.json, ./dashboards, files/*.json)")
-	cli.StringVar(&output, "output", ".", "Path to output directory where markdown files will be generated (default: current directory)")
+	cli.StringArrayVar(&inputs, "input", nil, "Path to dashboard file, directory, or glob pattern (e.g., dashboard.json, ./dashboards, files/*.json), or - to read a dashboard from stdin (repeatable)")
+	cli.StringVar(&output, "output", ".", "Path to output directory where markdown files will be generated (default: current directory), or - to write the documentation of a single dashboard to stdout")
+	cli.StringVar(&fromGrafana, "from-grafana", "", "URL of a Grafana instance whose dashboards are documented, in addition to any --input; output files mirror the folder structure")
+	cli.StringVar(&grafanaToken, "grafana-token", "", "API or service account token used to authenticate to Grafana (default: $GRAFANA_TOKEN, unless --grafana-user is set)")
+	cli.StringVar(&grafanaUser, "grafana-user", "", "User name used to authenticate to Grafana with basic auth")
+	cli.StringVar(&grafanaPassword, "grafana-password", "", "Password used to authenticate to Grafana with basic auth (default: $GRAFANA_PASSWORD)")
+	cli.StringArrayVar(&grafanaFolders, "grafana-folder", nil, "UID of a Grafana folder whose dashboards are documented (repeatable)")
+	cli.StringArrayVar(&grafanaTags, "grafana-tag", nil, "Tag the Grafana dashboards must have to be documented (repeatable, all tags must match)")
+	cli.StringVar(&grafanaQuery, "grafana-query", "", "Search query the titles of the Grafana dashboards must match to be documented")
+	cli.BoolVar(&recursive, "recursive", false, "Include the JSON files in the subdirectories of an input directory; output files mirror the directory structure")
+	cli.StringArrayVar(&includes, "include", nil, "Glob pattern, relative to the input directory or glob base, that files must match to be documented (repeatable, supports **)")
+	cli.StringArrayVar(&excludes, "exclude", nil, "Glob pattern, relative to the input directory or glob base, of files that are not documented (repeatable, supports **)")
+	cli.StringVar(&naming, "naming", "basename", "Naming scheme of the documentation files: basename (of the dashboard file), uid (of the dashboard) or title (slugified)")
+	cli.StringVar(&format, "format", "markdown", "Output format of the documentation: markdown, json, yaml or html (a static site documenting all dashboards)")
+	cli.StringVar(&templateFile, "template", "", "Path to a custom Go template replacing the default markdown template")
+	cli.StringVar(&templateDir, "template-dir", "", "Path to a directory of partial templates (*.tmpl) available to the custom template")
+	cli.StringVar(&inject, "inject", "", "Path to a markdown file the documentation of all dashboards is injected into, between <!-- BEGIN_AUTODOC --> and <!-- END_AUTODOC --> markers, instead of writing one file per dashboard")
+	cli.StringVar(&libraryPanels, "library-panels", "", "Path to a directory of exported library panel JSON models used to resolve library panel references")
+	cli.StringVar(&metricList, "metric-list", "", "Path to a file listing known metric names, one per line, used to expand metric name patterns")
+	cli.BoolVar(&strict, "strict", false, "Fail with a non-zero exit code when a query cannot be parsed instead of documenting it as a warning")
+	cli.BoolVar(&check, "check", false, "Compare the generated documentation with the existing files without writing anything, print a diff of stale or missing files and fail if there are any")
+	cli.StringVar(&report, "report", "", "Path to a file where all diagnostics of the run are written, e.g. for CI annotations")
+	cli.StringVar(&reportFormat, "report-format", "json", "Format of the diagnostics report: json or sarif")
 	cli.IntVar(&logLevel, "log-level", 0, "Debug: -4, Info: 0, Warn: 4, Error: 8 (default: Info)")
 	cli.BoolVar(&help, "help", false, "Show help message")
 	cli.BoolVar(&showVersion, "version", false, "Show version information")
 
 	cli.Parse(os.Args[1:])
+	// Empty values, e.g. unset GitHub Action inputs, select nothing
+	inputs = nonEmpty(inputs)
+	includes = nonEmpty(includes)
+	excludes = nonEmpty(excludes)
+	grafanaFolders = nonEmpty(grafanaFolders)
+	grafanaTags = nonEmpty(grafanaTags)
+	grafanaCredentialsFromEnv()
 
 	if help {
 		fmt.Fprintf(out, "Usage of %s:\n", os.Args[0])
@@ -89,11 +188,17 @@ func (r *runner) run() error {
 		return err
 	}
 
-	logger := slog.New(slog.NewJSONHandler(out, &slog.HandlerOptions{
+	// Keep stdout for the documentation when it is written there
+	logOutput := out
+	if output == stdinPath {
+		logOutput = os.Stderr
+	}
+	logger := slog.New(slog.NewJSONHandler(logOutput, &slog.HandlerOptions{
 		Level: slog.Level(logLevel),
 	})).With(
 		slog.Int("log-level", logLevel),
-		slog.String("input", input),
+		slog.Any("input", inputs),
+		slog.String("from-grafana", fromGrafana),
 		slog.String("output", output),
 	)
 
@@ -115,7 +220,9 @@ func printVersion() {
 	}
 }
 
-// processFiles handles the actual file processing logic based on the input type.
+// processFiles handles the actual file processing logic based on the input type
+// and writes the diagnostics report when one is requested. In check mode it
+// prints the diff of the stale documentation files instead of writing them.
 // It supports three input modes:
 //   - Glob patterns: processes all matching files
 //   - Single files: processes a single JSON file
@@ -123,88 +230,511 @@ func printVersion() {
 //
 // Returns an error if processing fails for any file.
 func processFiles() error {
-	switch {
-	case utils.IsGlobPattern(input):
-		matches, err := filepath.Glob(input)
+	opts, err := documentationOptions()
+	if err != nil {
+		return err
+	}
+
+	processErr := processInput(opts)
+	if err := writeReport(opts.Diagnostics); err != nil {
+		return err
+	}
+	if processErr != nil {
+		return processErr
+	}
+	return checkStaleFiles(opts.Check)
+}
+
+// checkStaleFiles prints the diff of every stale or missing documentation
+// file found in check mode. Nothing is checked outside of check mode.
+//
+// Returns an error if any documentation file is stale or missing.
+func checkStaleFiles(c *parser.Check) error {
+	if c == nil {
+		return nil
+	}
+	stale := c.Stale()
+	if len(stale) == 0 {
+		slog.Info("Documentation is up to date")
+		return nil
+	}
+	if err := c.WriteDiff(out); err != nil {
+		return err
+	}
+	for _, file := range stale {
+		slog.Error("Documentation file is stale", slog.String("file", file.Path), slog.Bool("missing", file.Missing))
+	}
+	return fmt.Errorf("%d documentation files are stale or missing, regenerate them without --check", len(stale))
+}
+
+// processInput documents the dashboards selected by the input flag with the
+// given options. In html format all dashboards are documented as one site, and
+// in injection mode they are all injected into the same markdown file.
+//
+// Returns an error if processing fails for any file.
+func processInput(opts parser.Options) error {
+	files, err := inputFiles()
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	if output == stdinPath && inject == "" {
+		return writeToStdout(files, opts)
+	}
+
+	if opts.Format == parser.FormatHTML || inject != "" {
+		docs, err := buildDocumentation(files, opts)
 		if err != nil {
-			slog.Error("Error processing glob pattern", slog.Any("error", err))
 			return err
 		}
-		if len(matches) == 0 {
-			slog.Warn("No files found matching pattern")
+		if inject != "" {
+			if err := parser.InjectDocumentation(docs, inject, opts); err != nil {
+				return err
+			}
+			slog.Info("Injected documentation", slog.String("inject", inject), slog.Int("dashboard-count", len(docs)))
 			return nil
 		}
-		slog.Info("Found files matching pattern", slog.Int("file-count", len(matches)))
-		var g multierror.Group
-		for _, match := range matches {
-			g.Go(func() error {
-				if strings.ToLower(filepath.Ext(match)) == ".json" {
-					if err := parser.CreateDocumentationFromFile(match, output); err != nil {
-						return err
-					}
-				} else {
-					slog.Debug("Skipping non-JSON file", slog.String("file", match))
+		if err := parser.CreateSite(docs, output, opts); err != nil {
+			return err
+		}
+		slog.Info("Wrote documentation site", slog.Int("dashboard-count", len(docs)))
+		return nil
+	}
+
+	outputs, err := planOutputs(files, opts)
+	if err != nil {
+		return err
+	}
+
+	var g multierror.Group
+	for _, o := range outputs {
+		g.Go(func() error {
+			dir := filepath.Dir(o.Path)
+			if opts.Check == nil && dir != filepath.Clean(output) {
+				if err := os.MkdirAll(dir, 0755); err != nil {
+					slog.Error("Error creating output directory", slog.Any("error", err), slog.String("output", dir))
+					return fmt.Errorf("error creating output directory %s: %w", dir, err)
 				}
-				return nil
-			})
+			}
+			return parser.WriteDocumentation(o.Doc, o.Path, opts)
+		})
+	}
+	if err := utils.SafeMultierrorWait(&g); err != nil {
+		slog.Error("error processing files", slog.Any("error", err))
+		return err
+	}
+	slog.Info("Processed all dashboard files", slog.Int("count", len(files)))
+	return nil
+}
+
+// writeToStdout documents a single dashboard and writes its documentation to
+// stdout, e.g. to pipe it to another command.
+//
+// Returns an error if more than one dashboard is selected, or the dashboard
+// cannot be documented.
+func writeToStdout(files []dashboardFile, opts parser.Options) error {
+	if len(files) != 1 {
+		slog.Error("Output to stdout requires a single dashboard", slog.Int("file-count", len(files)))
+		return fmt.Errorf("--output - requires exactly one dashboard, got %d", len(files))
+	}
+	docs, err := buildDocumentation(files, opts)
+	if err != nil {
+		return err
+	}
+	return parser.RenderDocumentation(out, docs[0], opts)
+}
+
+// planOutputs documents the dashboard files and names their documentation
+// files, mirroring the relative path of each dashboard file under the output
+// directory. Nothing is written, so that a collision between two
+// documentation files fails the run before any file is overwritten.
+//
+// Returns an error if a dashboard cannot be documented or named, if two
+// dashboards would be documented in the same file, or if a documentation file
+// would overwrite one of the dashboard files.
+func planOutputs(files []dashboardFile, opts parser.Options) ([]parser.Output, error) {
+	docs, err := buildDocumentation(files, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := make([]parser.Output, 0, len(files))
+	var inputs []string
+	for i, file := range files {
+		if file.content == nil {
+			inputs = append(inputs, file.path)
+		}
+		path, err := parser.DocumentationPath(docs[i], filepath.Join(output, filepath.Dir(file.rel)), opts)
+		if err != nil {
+			slog.Error("Error naming documentation file", slog.Any("error", err))
+			return nil, err
 		}
+		outputs = append(outputs, parser.Output{Doc: docs[i], Path: path})
+	}
+	if err := parser.DetectCollisions(outputs, inputs); err != nil {
+		return nil, err
+	}
+	return outputs, nil
+}
 
-		if err := utils.SafeMultierrorWait(&g); err != nil {
-			slog.Error("error processing files", slog.Any("error", err))
-			return err
+// dashboardFile is a dashboard file selected by the input flags.
+type dashboardFile struct {
+	// path is the path of the file
+	path string
+	// rel is the path of the file relative to the input directory or glob
+	// base, which the path of its documentation mirrors under the output directory
+	rel string
+	// content is the JSON model of a dashboard that is not read from path,
+	// e.g. read from stdin or downloaded from Grafana
+	content []byte
+	// key identifies the dashboard to remove duplicates, the cleaned path if empty
+	key string
+}
+
+// inputFiles lists the dashboard files selected by the input flags, in the
+// order of the flags, without duplicates, followed by the dashboards
+// downloaded from Grafana. The - input selects the dashboard read from stdin.
+//
+// Returns an error if an input is invalid or cannot be read.
+func inputFiles() ([]dashboardFile, error) {
+	var files []dashboardFile
+	seen := make(map[string]bool)
+	add := func(selected []dashboardFile) {
+		for _, file := range selected {
+			key := cmp.Or(file.key, filepath.Clean(file.path))
+			if seen[key] {
+				slog.Debug("Skipping duplicate input file", slog.String("file", file.path))
+				continue
+			}
+			seen[key] = true
+			files = append(files, file)
 		}
-		slog.Info("Processed JSON files from glob pattern")
-		return nil
+	}
+
+	for _, input := range inputs {
+		if input == stdinPath {
+			if seen[stdinPath] {
+				continue
+			}
+			bs, err := io.ReadAll(in)
+			if err != nil {
+				slog.Error("Error reading dashboard from stdin", slog.Any("error", err))
+				return nil, fmt.Errorf("error reading dashboard from stdin: %w", err)
+			}
+			add([]dashboardFile{{path: stdinFile, rel: stdinFile, content: bs, key: stdinPath}})
+			continue
+		}
+		selected, err := inputPathFiles(input)
+		if err != nil {
+			return nil, err
+		}
+		add(selected)
+	}
+
+	if fromGrafana != "" {
+		selected, err := grafanaFiles()
+		if err != nil {
+			return nil, err
+		}
+		add(selected)
+	}
+	return files, nil
+}
+
+// grafanaFiles downloads the dashboards of the Grafana instance selected by
+// the Grafana filters. Each dashboard is named after its slug, in directories
+// named after its folders, e.g. platform/kubernetes/cluster-overview.json.
+// The dashboards are then filtered by the include and exclude patterns.
+//
+// Returns an error if the dashboards cannot be listed or downloaded.
+func grafanaFiles() ([]dashboardFile, error) {
+	client, err := grafana.NewClient(fromGrafana, grafana.Auth{
+		Token:    grafanaToken,
+		Username: grafanaUser,
+		Password: grafanaPassword,
+	})
+	if err != nil {
+		slog.Error("Error creating grafana client", slog.Any("error", err))
+		return nil, err
+	}
+	dashboards, err := client.Dashboards(grafana.SearchOptions{
+		FolderUIDs: grafanaFolders,
+		Tags:       grafanaTags,
+		Query:      grafanaQuery,
+	})
+	if err != nil {
+		slog.Error("Error downloading dashboards from grafana", slog.Any("error", err))
+		return nil, fmt.Errorf("error downloading dashboards from %s: %w", fromGrafana, err)
+	}
+	if len(dashboards) == 0 {
+		slog.Warn("No dashboards found in grafana")
+		return nil, nil
+	}
+
+	files := make([]dashboardFile, 0, len(dashboards))
+	for _, dashboard := range dashboards {
+		var segments []string
+		for _, folder := range dashboard.FolderPath {
+			segments = append(segments, cmp.Or(utils.Slugify(folder), "folder"))
+		}
+		// the slug comes from the server, so it is sanitized like the folders
+		name := cmp.Or(utils.Slugify(dashboard.Slug), utils.Slugify(dashboard.Title), utils.Slugify(dashboard.UID), "dashboard")
+		rel := filepath.Join(append(segments, name+".json")...)
+		files = append(files, dashboardFile{path: rel, rel: rel, content: dashboard.Model, key: "grafana:" + dashboard.UID})
+	}
+	return filterFiles(files)
+}
+
+// inputPathFiles lists the dashboard files selected by an input path.
+// It supports three input modes:
+//   - Glob patterns: all matching JSON files, with ** matching any number of
+//     directories
+//   - Single files: the file itself, which must be a JSON file
+//   - Directories: all JSON files in the directory, and in its subdirectories
+//     with the recursive flag
+//
+// Documentation files written by the json and yaml formats, e.g.
+// overview.doc.json, are skipped in globs and directories, and the remaining
+// files are then filtered by the include and exclude patterns.
+//
+// Returns an error if the input is invalid or cannot be read.
+func inputPathFiles(input string) ([]dashboardFile, error) {
+	logger := slog.With(slog.String("input-path", input))
+	switch {
+	case utils.IsGlobPattern(input):
+		matches, err := utils.Glob(input)
+		if err != nil {
+			logger.Error("Error processing glob pattern", slog.Any("error", err))
+			return nil, err
+		}
+		if len(matches) == 0 {
+			logger.Warn("No files found matching pattern")
+			return nil, nil
+		}
+		logger.Info("Found files matching pattern", slog.Int("file-count", len(matches)))
+		base := utils.GlobBase(input)
+		var files []dashboardFile
+		for _, match := range matches {
+			if strings.ToLower(filepath.Ext(match)) != ".json" {
+				logger.Debug("Skipping non-JSON file", slog.String("file", match))
+				continue
+			}
+			if parser.IsDocumentationFile(match) {
+				logger.Debug("Skipping documentation file", slog.String("file", match))
+				continue
+			}
+			rel, err := filepath.Rel(base, match)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, dashboardFile{path: match, rel: rel})
+		}
+		return filterFiles(files)
 	case utils.IsValidFile(input):
 		if strings.ToLower(filepath.Ext(input)) != ".json" {
-			slog.Error("Input file must be a JSON file")
-			return errors.New("input file must be a json file")
-		}
-		slog.Info("Processing single file")
-		if err := parser.CreateDocumentationFromFile(input, output); err != nil {
-			return err
+			logger.Error("Input file must be a JSON file")
+			return nil, errors.New("input file must be a json file")
 		}
-		return nil
+		logger.Info("Processing single file")
+		return []dashboardFile{{path: input, rel: filepath.Base(input)}}, nil
 	case utils.IsValidDirectory(input):
-		files, err := utils.RetrieveJSONFilesFromDirectory(input)
+		var names []string
+		var err error
+		if recursive {
+			names, err = utils.RetrieveJSONFilesFromDirectoryRecursive(input)
+		} else {
+			names, err = utils.RetrieveJSONFilesFromDirectory(input)
+		}
 		if err != nil {
-			slog.Error("Error retrieving files from directory", slog.Any("error", err))
-			return err
+			logger.Error("Error retrieving files from directory", slog.Any("error", err))
+			return nil, err
 		}
 
-		if len(files) == 0 {
-			slog.Warn("No JSON files found in directory")
-			return nil
+		if len(names) == 0 {
+			logger.Warn("No JSON files found in directory")
+			return nil, nil
 		}
 
-		slog.Info("Found JSON files in directory", slog.Int("count", len(files)))
-		var g multierror.Group
-		for _, file := range files {
-			g.Go(func() error {
-				dashboard := filepath.Join(input, file)
-				if err := parser.CreateDocumentationFromFile(dashboard, output); err != nil {
-					return err
-				}
-				return nil
-			})
+		logger.Info("Found JSON files in directory", slog.Int("count", len(names)))
+		files := make([]dashboardFile, 0, len(names))
+		for _, name := range names {
+			if parser.IsDocumentationFile(name) {
+				logger.Debug("Skipping documentation file", slog.String("file", name))
+				continue
+			}
+			files = append(files, dashboardFile{path: filepath.Join(input, name), rel: name})
+		}
+		return filterFiles(files)
+	default:
+		logger.Error("Input path is not a valid file, directory, or glob pattern")
+		return nil, errors.New("input path is not a valid file, directory, or glob pattern")
+	}
+}
+
+// filterFiles keeps the files whose relative path matches at least one
+// include pattern, if any, and no exclude pattern.
+//
+// Returns an error if a pattern is malformed.
+func filterFiles(files []dashboardFile) ([]dashboardFile, error) {
+	if len(includes) == 0 && len(excludes) == 0 {
+		return files, nil
+	}
+	var filtered []dashboardFile
+	for _, file := range files {
+		included := len(includes) == 0
+		for _, pattern := range includes {
+			matched, err := matchFilePattern(pattern, file.rel)
+			if err != nil {
+				return nil, err
+			}
+			included = included || matched
+		}
+		excluded := false
+		for _, pattern := range excludes {
+			matched, err := matchFilePattern(pattern, file.rel)
+			if err != nil {
+				return nil, err
+			}
+			excluded = excluded || matched
+		}
+		if included && !excluded {
+			filtered = append(filtered, file)
+		} else {
+			slog.Debug("Skipping filtered file", slog.String("file", file.path))
 		}
+	}
+	slog.Info("Filtered input files", slog.Int("count", len(filtered)), slog.Int("skipped-count", len(files)-len(filtered)))
+	return filtered, nil
+}
+
+// matchFilePattern reports whether a relative file path matches an include or
+// exclude pattern. Like in .gitignore, a pattern without a slash matches the
+// file name in any directory, e.g. *-test.json.
+//
+// Returns an error if the pattern is malformed.
+func matchFilePattern(pattern, rel string) (bool, error) {
+	rel = filepath.ToSlash(rel)
+	if !strings.Contains(pattern, "/") {
+		rel = filepath.Base(rel)
+	}
+	matched, err := utils.MatchGlob(pattern, rel)
+	if err != nil {
+		return false, fmt.Errorf("invalid file pattern %q: %w", pattern, err)
+	}
+	return matched, nil
+}
 
-		if err := utils.SafeMultierrorWait(&g); err != nil {
-			slog.Error("error processing files", slog.Any("error", err))
+// buildDocumentation builds the documentation model of every dashboard file
+// in parallel. The models are returned in the order of the files.
+//
+// Returns an error if a dashboard cannot be documented.
+func buildDocumentation(files []dashboardFile, opts parser.Options) ([]*parser.DashboardDoc, error) {
+	docs := make([]*parser.DashboardDoc, len(files))
+	var g multierror.Group
+	for i, file := range files {
+		g.Go(func() error {
+			var err error
+			if file.content != nil {
+				docs[i], err = parser.BuildDocumentationFromBytes(file.path, file.content, opts)
+			} else {
+				docs[i], err = parser.BuildDocumentation(file.path, opts)
+			}
 			return err
+		})
+	}
+	if err := utils.SafeMultierrorWait(&g); err != nil {
+		slog.Error("error processing files", slog.Any("error", err))
+		return nil, err
+	}
+	return docs, nil
+}
+
+// documentationOptions builds the parser options from the command-line flags,
+// loading the custom template, the library panels directory and the metric
+// list when provided.
+//
+// Returns an error if the custom template, the library panels or the metric
+// list cannot be loaded.
+func documentationOptions() (parser.Options, error) {
+	opts := parser.Options{Strict: strict, Format: parser.Format(format), Naming: parser.Naming(naming)}
+	if templateFile != "" {
+		tmpl, err := templates.ParseCustomTemplate(templateFile, templateDir)
+		if err != nil {
+			slog.Error("Error loading custom template", slog.Any("error", err))
+			return opts, err
+		}
+		opts.Template = tmpl
+	}
+	if libraryPanels != "" {
+		library, err := parser.LoadLibraryPanels(libraryPanels)
+		if err != nil {
+			slog.Error("Error loading library panels", slog.Any("error", err))
+			return opts, err
+		}
+		opts.LibraryPanels = library
+	}
+	if metricList != "" {
+		metrics, err := parser.LoadMetricList(metricList)
+		if err != nil {
+			slog.Error("Error loading metric list", slog.Any("error", err))
+			return opts, err
 		}
-		slog.Info("Processed all files in the directory")
+		opts.KnownMetrics = metrics
+	}
+	if report != "" {
+		opts.Diagnostics = &parser.Diagnostics{}
+	}
+	if check {
+		opts.Check = &parser.Check{}
+	}
+	return opts, nil
+}
+
+// writeReport writes the diagnostics collected during the run to the report
+// file in the requested format. Nothing is written when no report was requested.
+//
+// Returns an error if the report file cannot be written.
+func writeReport(diagnostics *parser.Diagnostics) error {
+	if report == "" {
 		return nil
-	default:
-		slog.Error("Input path is not a valid file, directory, or glob pattern")
-		return errors.New("input path is not a valid file, directory, or glob pattern")
 	}
+	f, err := os.Create(report)
+	if err != nil {
+		slog.Error("Error creating report file", slog.Any("error", err), slog.String("report", report))
+		return fmt.Errorf("error creating report file: %w", err)
+	}
+	defer f.Close()
+
+	if reportFormat == "sarif" {
+		err = diagnostics.WriteSARIF(f, version)
+	} else {
+		err = diagnostics.WriteJSON(f)
+	}
+	if err != nil {
+		slog.Error("Error writing report", slog.Any("error", err), slog.String("report", report))
+		return err
+	}
+	slog.Info("Wrote diagnostics report", slog.String("report", report), slog.Int("diagnostic-count", len(diagnostics.List())))
+	return nil
 }
 
 // validateFlagValues validates the command-line flag values to ensure they
 // meet the application's requirements. It checks that:
 //   - logLevel is one of the valid values: -4 (Debug), 0 (Info), 4 (Warn), 8 (Error)
-//   - input flag is provided and not empty
+//   - input flag, or the from-grafana flag, is provided and not empty
+//   - Grafana filters are only used with from-grafana, and a single Grafana
+//     authentication method is configured
+//   - documentation written to stdout is neither a site nor checked
+//   - format is a supported output format
+//   - naming is a supported naming scheme
+//   - a custom template is only used with the markdown format, and partials
+//     only with a custom template
+//   - documentation is only injected in the markdown format
+//   - include and exclude patterns are well formed
+//   - reportFormat is either json or sarif
 //
 // Returns an error if validation fails.
 func validateFlagValues() error {
@@ -221,9 +751,94 @@ func validateFlagValues() error {
 		return fmt.Errorf("invalid log level: %d", logLevel)
 	}
 
-	if input == "" {
+	if len(nonEmpty(inputs)) == 0 && fromGrafana == "" {
 		setupLog.Error("input flag is required")
-		return errors.New("input flag is required")
+		return errors.New("input flag is required, or --from-grafana")
+	}
+
+	if fromGrafana == "" && (len(nonEmpty(grafanaFolders)) > 0 || len(nonEmpty(grafanaTags)) > 0 || grafanaQuery != "") {
+		setupLog.Error("Grafana filters require the from-grafana flag")
+		return errors.New("--grafana-folder, --grafana-tag and --grafana-query require --from-grafana")
+	}
+
+	if grafanaToken != "" && grafanaUser != "" {
+		setupLog.Error("Grafana token and basic auth are mutually exclusive")
+		return errors.New("--grafana-token cannot be used with --grafana-user")
+	}
+
+	if grafanaPassword != "" && grafanaUser == "" {
+		setupLog.Error("grafana-password flag requires the grafana-user flag")
+		return errors.New("--grafana-password requires --grafana-user")
+	}
+
+	if output == stdinPath && format == string(parser.FormatHTML) {
+		setupLog.Error("The html format cannot be written to stdout")
+		return errors.New("--output - cannot be used with the html format")
+	}
+
+	if output == stdinPath && check {
+		setupLog.Error("Check mode compares files and cannot be used with stdout")
+		return errors.New("--output - cannot be used with --check")
+	}
+
+	if !parser.Format(format).IsValid() {
+		setupLog.Error("Invalid output format", slog.String("format", format), slog.String("valid_values", "markdown, json, yaml, html"))
+		return fmt.Errorf("invalid output format: %s", format)
+	}
+
+	if !parser.Naming(naming).IsValid() {
+		setupLog.Error("Invalid naming scheme", slog.String("naming", naming), slog.String("valid_values", "basename, uid, title"))
+		return fmt.Errorf("invalid naming scheme: %s", naming)
+	}
+
+	if templateFile != "" && format != string(parser.FormatMarkdown) {
+		setupLog.Error("Custom templates require the markdown format", slog.String("format", format))
+		return fmt.Errorf("--template cannot be used with the %s format", format)
+	}
+
+	if templateDir != "" && templateFile == "" {
+		setupLog.Error("template-dir flag requires the template flag")
+		return errors.New("--template-dir requires --template")
+	}
+
+	if inject != "" && format != string(parser.FormatMarkdown) {
+		setupLog.Error("Injection requires the markdown format", slog.String("format", format))
+		return fmt.Errorf("--inject cannot be used with the %s format", format)
+	}
+
+	for _, pattern := range append(slices.Clone(includes), excludes...) {
+		if _, err := matchFilePattern(pattern, ""); err != nil {
+			setupLog.Error("Invalid file pattern", slog.String("pattern", pattern), slog.Any("error", err))
+			return err
+		}
+	}
+
+	if reportFormat != "json" && reportFormat != "sarif" {
+		setupLog.Error("Invalid report format", slog.String("report-format", reportFormat), slog.String("valid_values", "json, sarif"))
+		return fmt.Errorf("invalid report format: %s", reportFormat)
 	}
 	return nil
 }
+
+// grafanaCredentialsFromEnv reads the Grafana credentials that are not set by
+// flags from the GRAFANA_TOKEN and GRAFANA_PASSWORD environment variables, to
+// keep them out of the process list and shell history. The environment is
+// only read with --from-grafana, and explicit flags take precedence: the token
+// is not read when basic auth is requested with --grafana-user, and the
+// password is only read for it.
+func grafanaCredentialsFromEnv() {
+	if fromGrafana == "" {
+		return
+	}
+	if grafanaToken == "" && grafanaUser == "" {
+		grafanaToken = os.Getenv("GRAFANA_TOKEN")
+	}
+	if grafanaPassword == "" && grafanaUser != "" {
+		grafanaPassword = os.Getenv("GRAFANA_PASSWORD")
+	}
+}
+
+// nonEmpty returns the non-empty values of a repeatable flag.
+func nonEmpty(values []string) []string {
+	return slices.DeleteFunc(slices.Clone(values), func(value string) bool { return value == "" })
+}
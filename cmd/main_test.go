@@ -2,12 +2,17 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/rastogiji/autodoc-grafana/pkg/parser"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -17,7 +22,7 @@ func TestRun(t *testing.T) {
 		args            []string
 		expectError     bool
 		errorMsg        string
-		expectedInput   string
+		expectedInputs  []string
 		expectedOutput  string
 		expectedLevel   int
 		expectedHelp    bool
@@ -28,7 +33,7 @@ func TestRun(t *testing.T) {
 			args:            []string{"program", "--version"},
 			expectError:     false,
 			expectedVersion: true,
-			expectedInput:   "",
+			expectedInputs:  nil,
 			expectedOutput:  ".",
 			expectedLevel:   0,
 			expectedHelp:    false,
@@ -38,7 +43,7 @@ func TestRun(t *testing.T) {
 			args:           []string{"program", "--help"},
 			expectError:    false,
 			expectedHelp:   true,
-			expectedInput:  "",
+			expectedInputs: nil,
 			expectedOutput: ".",
 			expectedLevel:  0,
 		},
@@ -58,7 +63,7 @@ func TestRun(t *testing.T) {
 			name:           "valid flags should parse correctly with defaults",
 			args:           []string{"program", "--input", "dashboard.json"},
 			expectError:    false,
-			expectedInput:  "dashboard.json",
+			expectedInputs: []string{"dashboard.json"},
 			expectedOutput: ".",
 			expectedLevel:  0,
 			expectedHelp:   false,
@@ -67,7 +72,7 @@ func TestRun(t *testing.T) {
 			name:           "custom output directory should be parsed correctly",
 			args:           []string{"program", "--input", "test.json", "--output", "/custom/output"},
 			expectError:    false,
-			expectedInput:  "test.json",
+			expectedInputs: []string{"test.json"},
 			expectedOutput: "/custom/output",
 			expectedLevel:  0,
 			expectedHelp:   false,
@@ -76,7 +81,7 @@ func TestRun(t *testing.T) {
 			name:           "custom log level debug should be parsed correctly",
 			args:           []string{"program", "--input", "test.json", "--log-level", "-4"},
 			expectError:    false,
-			expectedInput:  "test.json",
+			expectedInputs: []string{"test.json"},
 			expectedOutput: ".",
 			expectedLevel:  -4,
 			expectedHelp:   false,
@@ -85,7 +90,7 @@ func TestRun(t *testing.T) {
 			name:           "custom log level info should be parsed correctly",
 			args:           []string{"program", "--input", "test.json", "--log-level", "0"},
 			expectError:    false,
-			expectedInput:  "test.json",
+			expectedInputs: []string{"test.json"},
 			expectedOutput: ".",
 			expectedLevel:  0,
 			expectedHelp:   false,
@@ -94,7 +99,7 @@ func TestRun(t *testing.T) {
 			name:           "all flags together should be parsed correctly",
 			args:           []string{"program", "--input", "dashboard.json", "--output", "/tmp/docs", "--log-level", "-4"},
 			expectError:    false,
-			expectedInput:  "dashboard.json",
+			expectedInputs: []string{"dashboard.json"},
 			expectedOutput: "/tmp/docs",
 			expectedLevel:  -4,
 			expectedHelp:   false,
@@ -103,16 +108,25 @@ func TestRun(t *testing.T) {
 			name:           "glob pattern input should be parsed correctly",
 			args:           []string{"program", "--input", "*.json", "--output", "./output"},
 			expectError:    false,
-			expectedInput:  "*.json",
+			expectedInputs: []string{"*.json"},
 			expectedOutput: "./output",
 			expectedLevel:  0,
 			expectedHelp:   false,
 		},
+		{
+			name:           "repeated input flags should be parsed in order",
+			args:           []string{"program", "--input", "dashboard.json", "--input", "./dashboards/{teamA,teamB}/*.json"},
+			expectError:    false,
+			expectedInputs: []string{"dashboard.json", "./dashboards/{teamA,teamB}/*.json"},
+			expectedOutput: ".",
+			expectedLevel:  0,
+			expectedHelp:   false,
+		},
 		{
 			name:           "directory input should be parsed correctly",
 			args:           []string{"program", "--input", "./dashboards", "--output", "./docs"},
 			expectError:    false,
-			expectedInput:  "./dashboards",
+			expectedInputs: []string{"./dashboards"},
 			expectedOutput: "./docs",
 			expectedLevel:  0,
 			expectedHelp:   false,
@@ -122,7 +136,7 @@ func TestRun(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			// Reset global variables
-			input = ""
+			inputs = nil
 			output = "."
 			logLevel = 0
 			help = false
@@ -163,7 +177,7 @@ func TestRun(t *testing.T) {
 
 			assert.NoError(t, err)
 
-			assert.Equal(t, tc.expectedInput, input, "Input flag should be parsed correctly")
+			assert.Equal(t, tc.expectedInputs, inputs, "Input flag should be parsed correctly")
 			assert.Equal(t, tc.expectedOutput, output, "Output flag should be parsed correctly")
 			assert.Equal(t, tc.expectedLevel, logLevel, "Log level flag should be parsed correctly")
 			assert.Equal(t, tc.expectedHelp, help, "Help flag should be parsed correctly")
@@ -178,7 +192,9 @@ func TestRun(t *testing.T) {
 				logOutput := buf.String()
 				assert.NotEmpty(t, logOutput, "Logger should have been initialized and used")
 				assert.Contains(t, logOutput, fmt.Sprintf(`"log-level":%d`, tc.expectedLevel))
-				assert.Contains(t, logOutput, fmt.Sprintf(`"input":"%s"`, tc.expectedInput))
+				expectedInputs, err := json.Marshal(tc.expectedInputs)
+				assert.NoError(t, err)
+				assert.Contains(t, logOutput, fmt.Sprintf(`"input":%s`, expectedInputs))
 				assert.Contains(t, logOutput, fmt.Sprintf(`"output":"%s"`, tc.expectedOutput))
 			}
 		})
@@ -187,12 +203,42 @@ func TestRun(t *testing.T) {
 
 func TestProcessFiles(t *testing.T) {
 	tests := []struct {
-		name         string
-		expectError  bool
-		input        string
-		output       string
-		errorMessage string
-		setupFiles   func(t *testing.T) string // Returns tmpDir
+		name        string
+		expectError bool
+		input       string
+		// inputs replaces input with several input flags, if set
+		inputs []string
+		// stdin is the content read from stdin
+		stdin         string
+		output        string
+		recursive     bool
+		includes      []string
+		excludes      []string
+		naming        string
+		libraryPanels string
+		metricList    string
+		strict        bool
+		format        string
+		templateFile  string
+		templateDir   string
+		inject        string
+		check         bool
+		report        string
+		reportFormat  string
+		// reportContains is expected in the written report, if set
+		reportContains string
+		// expectedFile is expected to be written, if set
+		expectedFile string
+		// expectedContent is expected in the written file, if set
+		expectedContent string
+		// unexpectedFile is expected not to be written, if set
+		unexpectedFile string
+		// unchangedFile is expected to keep its content, if set
+		unchangedFile string
+		// expectedStdout is expected in the output written to stdout, if set
+		expectedStdout string
+		errorMessage   string
+		setupFiles     func(t *testing.T) string // Returns tmpDir
 	}{
 		{
 			name:        "valid single JSON file should process successfully",
@@ -349,6 +395,317 @@ func TestProcessFiles(t *testing.T) {
 				return tmpDir
 			},
 		},
+		{
+			name:          "library panels directory should be loaded before processing",
+			expectError:   false,
+			input:         "test.json",
+			output:        "output",
+			libraryPanels: "library",
+			setupFiles: func(t *testing.T) string {
+				tmpDir := t.TempDir()
+				libraryDir := filepath.Join(tmpDir, "library")
+				outputDir := filepath.Join(tmpDir, "output")
+
+				err := os.MkdirAll(libraryDir, 0755)
+				assert.NoError(t, err)
+
+				err = os.MkdirAll(outputDir, 0755)
+				assert.NoError(t, err)
+
+				libraryContent := `{
+					"uid": "lib-up",
+					"name": "Up",
+					"model": {"type": "stat", "title": "Up", "targets": [{"expr": "up"}]}
+				}`
+				err = os.WriteFile(filepath.Join(libraryDir, "up.json"), []byte(libraryContent), 0644)
+				assert.NoError(t, err)
+
+				jsonContent := `{
+					"title": "Test Dashboard",
+					"panels": [{"id": 1, "libraryPanel": {"uid": "lib-up", "name": "Up"}}]
+				}`
+				err = os.WriteFile(filepath.Join(tmpDir, "test.json"), []byte(jsonContent), 0644)
+				assert.NoError(t, err)
+
+				return tmpDir
+			},
+		},
+		{
+			name:          "missing library panels directory should return error",
+			expectError:   true,
+			input:         "test.json",
+			output:        "output",
+			libraryPanels: "nonexistent",
+			errorMessage:  "error reading library panels directory",
+			setupFiles: func(t *testing.T) string {
+				tmpDir := t.TempDir()
+				outputDir := filepath.Join(tmpDir, "output")
+
+				err := os.MkdirAll(outputDir, 0755)
+				assert.NoError(t, err)
+
+				return tmpDir
+			},
+		},
+		{
+			name:        "invalid query should be documented as a warning by default",
+			expectError: false,
+			input:       "test.json",
+			output:      "output",
+			setupFiles:  setupInvalidQueryDashboard,
+		},
+		{
+			name:         "invalid query should return error in strict mode",
+			expectError:  true,
+			input:        "test.json",
+			output:       "output",
+			strict:       true,
+			errorMessage: "error parsing promql expression",
+			setupFiles:   setupInvalidQueryDashboard,
+		},
+		{
+			name:           "sarif report should be written even when processing fails",
+			expectError:    true,
+			input:          "test.json",
+			output:         "output",
+			strict:         true,
+			report:         "report.sarif",
+			reportFormat:   "sarif",
+			reportContains: "/panels/0/targets/0/expr",
+			errorMessage:   "error parsing promql expression",
+			setupFiles:     setupInvalidQueryDashboard,
+		},
+		{
+			name:         "report in a missing directory should return error",
+			expectError:  true,
+			input:        "test.json",
+			output:       "output",
+			report:       "missing/report.json",
+			errorMessage: "error creating report file",
+			setupFiles:   setupInvalidQueryDashboard,
+		},
+		{
+			name:         "missing metric list should return error",
+			expectError:  true,
+			input:        "test.json",
+			output:       "output",
+			metricList:   "nonexistent.txt",
+			errorMessage: "error reading metric list",
+			setupFiles: func(t *testing.T) string {
+				tmpDir := t.TempDir()
+				outputDir := filepath.Join(tmpDir, "output")
+
+				err := os.MkdirAll(outputDir, 0755)
+				assert.NoError(t, err)
+
+				return tmpDir
+			},
+		},
+		{
+			name:         "html format should write a site documenting every dashboard",
+			expectError:  false,
+			input:        "test.json",
+			output:       "site",
+			format:       "html",
+			expectedFile: "site/dashboards/test.html",
+			setupFiles:   setupInvalidQueryDashboard,
+		},
+		{
+			name:            "custom template should be used with its partials",
+			expectError:     false,
+			input:           "test.json",
+			output:          "output",
+			templateFile:    "house.tmpl",
+			templateDir:     "partials",
+			expectedFile:    "output/test.md",
+			expectedContent: "- Broken (Stat)\n- Up (Stat)\n",
+			setupFiles: func(t *testing.T) string {
+				tmpDir := setupInvalidQueryDashboard(t)
+				err := os.MkdirAll(filepath.Join(tmpDir, "partials"), 0755)
+				assert.NoError(t, err)
+				err = os.WriteFile(filepath.Join(tmpDir, "partials", "panel.tmpl"), []byte(`- {{.Title}} ({{panelType .Type}})`), 0644)
+				assert.NoError(t, err)
+				err = os.WriteFile(filepath.Join(tmpDir, "house.tmpl"), []byte(`{{range .Panels}}{{template "panel.tmpl" .}}
+{{end}}`), 0644)
+				assert.NoError(t, err)
+				return tmpDir
+			},
+		},
+		{
+			name:         "missing custom template should return error",
+			expectError:  true,
+			input:        "test.json",
+			output:       "output",
+			templateFile: "missing.tmpl",
+			errorMessage: "error parsing custom template",
+			setupFiles:   setupInvalidQueryDashboard,
+		},
+		{
+			name:            "inject should replace the content between the markers",
+			expectError:     false,
+			input:           "test.json",
+			output:          "output",
+			inject:          "README.md",
+			expectedFile:    "README.md",
+			expectedContent: "# Team\n\n<!-- BEGIN_AUTODOC -->\n# Test Dashboard\n",
+			setupFiles: func(t *testing.T) string {
+				tmpDir := setupInvalidQueryDashboard(t)
+				err := os.WriteFile(filepath.Join(tmpDir, "README.md"), []byte("# Team\n\n<!-- BEGIN_AUTODOC -->\n<!-- END_AUTODOC -->\n"), 0644)
+				assert.NoError(t, err)
+				return tmpDir
+			},
+		},
+		{
+			name:        "check should pass when the documentation is up to date",
+			expectError: false,
+			input:       "test.json",
+			output:      "output",
+			check:       true,
+			setupFiles: func(t *testing.T) string {
+				tmpDir := setupInvalidQueryDashboard(t)
+				err := parser.CreateDocumentationFromFile(filepath.Join(tmpDir, "test.json"), filepath.Join(tmpDir, "output"), parser.Options{})
+				assert.NoError(t, err)
+				return tmpDir
+			},
+		},
+		{
+			name:         "check should fail without writing when the documentation is missing",
+			expectError:  true,
+			input:        "test.json",
+			output:       "output",
+			check:        true,
+			errorMessage: "1 documentation files are stale or missing",
+			setupFiles:   setupInvalidQueryDashboard,
+		},
+		{
+			name:           "recursive directory should mirror the directory structure",
+			input:          "dashboards",
+			output:         "output",
+			recursive:      true,
+			expectedFile:   "output/teamA/api/overview.md",
+			unexpectedFile: "output/overview.md",
+			setupFiles:     setupNestedDashboards,
+		},
+		{
+			name:           "directory should ignore subdirectories without recursive",
+			input:          "dashboards",
+			output:         "output",
+			expectedFile:   "output/root.md",
+			unexpectedFile: "output/teamB",
+			setupFiles:     setupNestedDashboards,
+		},
+		{
+			name:           "doublestar glob should match nested files and honor excludes",
+			input:          "dashboards/**/overview*.json",
+			output:         "output",
+			excludes:       []string{"*-test.json"},
+			expectedFile:   "output/teamB/overview.md",
+			unexpectedFile: "output/teamB/overview-test.md",
+			setupFiles:     setupNestedDashboards,
+		},
+		{
+			name:           "include pattern should select files relative to the input directory",
+			input:          "dashboards",
+			output:         "output",
+			recursive:      true,
+			includes:       []string{"teamA/**"},
+			expectedFile:   "output/teamA/api/overview.md",
+			unexpectedFile: "output/root.md",
+			setupFiles:     setupNestedDashboards,
+		},
+		{
+			name:         "uid naming should name the documentation after the dashboard uid",
+			input:        "dashboards",
+			output:       "output",
+			naming:       "uid",
+			expectedFile: "output/api-overview.md",
+			setupFiles:   setupSameTitleDashboards,
+		},
+		{
+			name:           "title naming collision should fail before writing anything",
+			expectError:    true,
+			input:          "dashboards",
+			output:         "output",
+			naming:         "title",
+			errorMessage:   "1 documentation files would be written for several dashboards: output/overview.md would document dashboards/api.json, dashboards/web.json",
+			unexpectedFile: "output/overview.md",
+			setupFiles:     setupSameTitleDashboards,
+		},
+		{
+			name:         "repeated inputs mixing files, directories and globs should be deduplicated",
+			inputs:       []string{"dashboards/root.json", "dashboards", "dashboards/**/overview.json"},
+			output:       "output",
+			expectedFile: "output/teamA/api/overview.md",
+			setupFiles:   setupNestedDashboards,
+		},
+		{
+			name:            "stdin input should document the dashboard read from stdin",
+			inputs:          []string{"-"},
+			stdin:           `{"title": "Piped Dashboard", "panels": []}`,
+			output:          "output",
+			expectedFile:    "output/stdin.md",
+			expectedContent: "# Piped Dashboard\n",
+			setupFiles:      setupNestedDashboards,
+		},
+		{
+			name:           "stdout output should write the documentation to stdout",
+			inputs:         []string{"-"},
+			stdin:          `{"title": "Piped Dashboard", "panels": []}`,
+			output:         "-",
+			format:         "json",
+			expectedStdout: `"title": "Piped Dashboard"`,
+			unexpectedFile: "-",
+			setupFiles:     setupNestedDashboards,
+		},
+		{
+			name:         "stdout output with several dashboards should return error",
+			expectError:  true,
+			input:        "dashboards",
+			output:       "-",
+			recursive:    true,
+			errorMessage: "--output - requires exactly one dashboard, got 4",
+			setupFiles:   setupNestedDashboards,
+		},
+		{
+			name:            "json format next to its input should not overwrite the dashboard",
+			input:           "test.json",
+			output:          ".",
+			format:          "json",
+			expectedFile:    "test.doc.json",
+			expectedContent: `"title": "Test Dashboard"`,
+			unchangedFile:   "test.json",
+			setupFiles:      setupInvalidQueryDashboard,
+		},
+		{
+			name:          "documentation named like its input should return error without overwriting it",
+			expectError:   true,
+			input:         "overview.doc.json",
+			output:        ".",
+			naming:        "uid",
+			format:        "json",
+			unchangedFile: "overview.doc.json",
+			errorMessage:  "overview.doc.json would overwrite the dashboard overview.doc.json",
+			setupFiles: func(t *testing.T) string {
+				tmpDir := t.TempDir()
+				assert.NoError(t, os.WriteFile(filepath.Join(tmpDir, "overview.doc.json"), []byte(`{"uid": "overview", "title": "Overview", "panels": []}`), 0644))
+				return tmpDir
+			},
+		},
+		{
+			name:          "recursive input should skip the json documentation of a previous run",
+			input:         "dashboards",
+			output:        "dashboards",
+			recursive:     true,
+			format:        "json",
+			expectedFile:  "dashboards/teamB/overview.doc.json",
+			unchangedFile: "dashboards/teamB/overview.json",
+			setupFiles: func(t *testing.T) string {
+				tmpDir := setupNestedDashboards(t)
+				path := filepath.Join(tmpDir, "dashboards", "teamB", "overview.doc.json")
+				assert.NoError(t, os.WriteFile(path, []byte(`{"schemaVersion": "1", "title": "dashboards/teamB/overview.json"}`), 0644))
+				return tmpDir
+			},
+		},
 		{
 			name:         "invalid input path should return error",
 			expectError:  true,
@@ -374,8 +731,38 @@ func TestProcessFiles(t *testing.T) {
 			err := os.Chdir(tmpDir)
 			assert.NoError(t, err)
 
-			input = tc.input
+			inputs = []string{tc.input}
+			if tc.inputs != nil {
+				inputs = tc.inputs
+			}
+			in = strings.NewReader(tc.stdin)
 			output = tc.output
+			recursive = tc.recursive
+			includes = tc.includes
+			excludes = tc.excludes
+			naming = tc.naming
+			libraryPanels = tc.libraryPanels
+			metricList = tc.metricList
+			strict = tc.strict
+			format = tc.format
+			if format == "" {
+				format = "markdown"
+			}
+			templateFile = tc.templateFile
+			templateDir = tc.templateDir
+			inject = tc.inject
+			check = tc.check
+			report = tc.report
+			reportFormat = tc.reportFormat
+
+			var buf bytes.Buffer
+			out = &buf
+
+			var unchanged []byte
+			if tc.unchangedFile != "" {
+				unchanged, err = os.ReadFile(tc.unchangedFile)
+				assert.NoError(t, err)
+			}
 
 			err = processFiles()
 
@@ -387,17 +774,171 @@ func TestProcessFiles(t *testing.T) {
 			} else {
 				assert.NoError(t, err)
 			}
+			if tc.reportContains != "" {
+				bs, err := os.ReadFile(tc.report)
+				assert.NoError(t, err)
+				assert.Contains(t, string(bs), tc.reportContains)
+			}
+			if tc.expectedFile != "" {
+				assert.FileExists(t, tc.expectedFile)
+			}
+			if tc.check && tc.expectError {
+				assert.Contains(t, buf.String(), "+++ output/test.md (generated)")
+				assert.NoFileExists(t, "output/test.md")
+			}
+			if tc.expectedStdout != "" {
+				assert.Contains(t, buf.String(), tc.expectedStdout)
+			}
+			if tc.unexpectedFile != "" {
+				assert.NoFileExists(t, tc.unexpectedFile)
+				assert.NoDirExists(t, tc.unexpectedFile)
+			}
+			if tc.unchangedFile != "" {
+				bs, err := os.ReadFile(tc.unchangedFile)
+				assert.NoError(t, err)
+				assert.Equal(t, string(unchanged), string(bs), "input should not be overwritten")
+				assert.NoFileExists(t, strings.TrimSuffix(tc.unchangedFile, ".json")+".doc.doc.json", "documentation should not be documented")
+			}
+			if tc.expectedContent != "" {
+				bs, err := os.ReadFile(tc.expectedFile)
+				assert.NoError(t, err)
+				assert.Contains(t, string(bs), tc.expectedContent)
+			}
 		})
 	}
 }
 
+func TestProcessFilesFromGrafana(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer glsa_test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"message": "invalid API key"}`)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/search":
+			assert.Equal(t, []string{"prod"}, r.URL.Query()["tag"])
+			fmt.Fprint(w, `[{"uid": "api", "title": "API Overview", "folderUid": "payments", "folderTitle": "Payments"}, {"uid": "escape", "title": "Escape", "folderUid": "payments", "folderTitle": "Payments"}]`)
+		case "/api/dashboards/uid/api":
+			fmt.Fprint(w, `{"dashboard": {"uid": "api", "title": "API Overview", "panels": []}, "meta": {"slug": "api-overview", "folderUid": "payments", "folderTitle": "Payments"}}`)
+		case "/api/dashboards/uid/escape":
+			fmt.Fprint(w, `{"dashboard": {"uid": "escape", "title": "Escape", "panels": []}, "meta": {"slug": "../../../escaped", "folderUid": "payments", "folderTitle": "Payments"}}`)
+		case "/api/folders/payments":
+			fmt.Fprint(w, `{"uid": "payments", "title": "Payments", "parents": [{"uid": "teams", "title": "Teams"}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	tmpDir := t.TempDir()
+	assert.NoError(t, os.Chdir(tmpDir))
+	inputs, output, format, naming, inject, check, report = nil, "docs", "markdown", "basename", "", false, ""
+	recursive, includes, excludes, libraryPanels, metricList, templateFile, templateDir = false, nil, nil, "", "", "", ""
+	fromGrafana, grafanaUser, grafanaPassword, grafanaFolders, grafanaTags, grafanaQuery = server.URL, "", "", nil, []string{"prod"}, ""
+	t.Cleanup(func() { fromGrafana, grafanaToken, grafanaTags = "", "", nil })
+	out = &bytes.Buffer{}
+
+	t.Run("dashboards should be documented in their folder path", func(t *testing.T) {
+		grafanaToken = "glsa_test"
+		assert.NoError(t, processFiles())
+		bs, err := os.ReadFile(filepath.Join("docs", "teams", "payments", "api-overview.md"))
+		assert.NoError(t, err)
+		assert.Contains(t, string(bs), "# API Overview")
+	})
+
+	t.Run("slugs should not escape the output directory", func(t *testing.T) {
+		grafanaToken = "glsa_test"
+		assert.NoError(t, processFiles())
+		assert.FileExists(t, filepath.Join("docs", "teams", "payments", "escaped.md"))
+		assert.NoFileExists(t, "escaped.md")
+	})
+
+	t.Run("invalid token should return error", func(t *testing.T) {
+		grafanaToken = "wrong"
+		err := processFiles()
+		assert.ErrorContains(t, err, "error downloading dashboards from "+server.URL)
+		assert.ErrorContains(t, err, "invalid API key")
+	})
+}
+
+// setupNestedDashboards creates a tree of dashboards in team and service
+// subdirectories and an output directory in a temporary directory.
+func setupNestedDashboards(t *testing.T) string {
+	tmpDir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "output"), 0755))
+
+	for _, file := range []string{
+		"dashboards/root.json",
+		"dashboards/teamA/api/overview.json",
+		"dashboards/teamB/overview.json",
+		"dashboards/teamB/overview-test.json",
+	} {
+		path := filepath.Join(tmpDir, file)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(`{"title": "`+file+`", "panels": []}`), 0644))
+	}
+	return tmpDir
+}
+
+// setupSameTitleDashboards creates two dashboards with the same title and
+// different UIDs, and an output directory in a temporary directory.
+func setupSameTitleDashboards(t *testing.T) string {
+	tmpDir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "output"), 0755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "dashboards"), 0755))
+
+	for _, name := range []string{"api", "web"} {
+		jsonContent := `{"uid": "` + name + `-overview", "title": "Overview", "panels": []}`
+		assert.NoError(t, os.WriteFile(filepath.Join(tmpDir, "dashboards", name+".json"), []byte(jsonContent), 0644))
+	}
+	return tmpDir
+}
+
+// setupInvalidQueryDashboard creates a dashboard with an unparsable query and
+// an output directory in a temporary directory.
+func setupInvalidQueryDashboard(t *testing.T) string {
+	tmpDir := t.TempDir()
+	outputDir := filepath.Join(tmpDir, "output")
+
+	err := os.MkdirAll(outputDir, 0755)
+	assert.NoError(t, err)
+
+	jsonContent := `{
+		"title": "Test Dashboard",
+		"panels": [
+			{"id": 1, "type": "stat", "title": "Broken", "targets": [{"refId": "A", "expr": "sum(up"}]},
+			{"id": 2, "type": "stat", "title": "Up", "targets": [{"refId": "A", "expr": "up"}]}
+		]
+	}`
+	err = os.WriteFile(filepath.Join(tmpDir, "test.json"), []byte(jsonContent), 0644)
+	assert.NoError(t, err)
+
+	return tmpDir
+}
+
 func TestValidateFlagValues(t *testing.T) {
 	tests := []struct {
-		name        string
-		logLevel    int
-		input       string
-		expectError bool
-		errorMsg    string
+		name            string
+		logLevel        int
+		input           string
+		format          string
+		templateFile    string
+		templateDir     string
+		inject          string
+		includes        []string
+		excludes        []string
+		naming          string
+		output          string
+		check           bool
+		fromGrafana     string
+		grafanaToken    string
+		grafanaUser     string
+		grafanaPassword string
+		grafanaTags     []string
+		reportFormat    string
+		expectError     bool
+		errorMsg        string
 	}{
 		{
 			name:        "valid log level debug and valid input. should return no error",
@@ -473,6 +1014,135 @@ func TestValidateFlagValues(t *testing.T) {
 			logLevel:    4,
 			input:       "./utils",
 			expectError: false,
+		}, {
+			name:        "yaml output format. should return no error",
+			logLevel:    0,
+			input:       "dashboard.json",
+			format:      "yaml",
+			expectError: false,
+		}, {
+			name:        "html output format. should return no error",
+			logLevel:    0,
+			input:       "./dashboards",
+			format:      "html",
+			expectError: false,
+		}, {
+			name:        "invalid output format. should return error",
+			logLevel:    0,
+			input:       "dashboard.json",
+			format:      "pdf",
+			expectError: true,
+			errorMsg:    "invalid output format: pdf",
+		}, {
+			name:         "sarif report format. should return no error",
+			logLevel:     0,
+			input:        "dashboard.json",
+			reportFormat: "sarif",
+			expectError:  false,
+		}, {
+			name:         "invalid report format. should return error",
+			logLevel:     0,
+			input:        "dashboard.json",
+			reportFormat: "xml",
+			expectError:  true,
+			errorMsg:     "invalid report format: xml",
+		}, {
+			name:         "custom template with markdown format. should return no error",
+			logLevel:     0,
+			input:        "dashboard.json",
+			templateFile: "house.tmpl",
+			templateDir:  "partials",
+			expectError:  false,
+		}, {
+			name:         "custom template with json format. should return error",
+			logLevel:     0,
+			input:        "dashboard.json",
+			format:       "json",
+			templateFile: "house.tmpl",
+			expectError:  true,
+			errorMsg:     "--template cannot be used with the json format",
+		}, {
+			name:        "template directory without template. should return error",
+			logLevel:    0,
+			input:       "dashboard.json",
+			templateDir: "partials",
+			expectError: true,
+			errorMsg:    "--template-dir requires --template",
+		}, {
+			name:        "inject with html format. should return error",
+			logLevel:    0,
+			input:       "./dashboards",
+			format:      "html",
+			inject:      "README.md",
+			expectError: true,
+			errorMsg:    "--inject cannot be used with the html format",
+		}, {
+			name:     "valid include and exclude patterns. should return no error",
+			logLevel: 0,
+			input:    "./dashboards",
+			includes: []string{"teams/**/*.json"},
+			excludes: []string{"*-{test,staging}.json"},
+		}, {
+			name:        "malformed exclude pattern. should return error",
+			logLevel:    0,
+			input:       "./dashboards",
+			excludes:    []string{"[a-"},
+			expectError: true,
+			errorMsg:    `invalid file pattern "[a-"`,
+		}, {
+			name:        "invalid naming scheme. should return error",
+			logLevel:    0,
+			input:       "./dashboards",
+			naming:      "slug",
+			expectError: true,
+			errorMsg:    "invalid naming scheme: slug",
+		}, {
+			name:     "stdin input and stdout output. should return no error",
+			logLevel: 0,
+			input:    "-",
+			output:   "-",
+		}, {
+			name:        "stdout output with html format. should return error",
+			logLevel:    0,
+			input:       "./dashboards",
+			output:      "-",
+			format:      "html",
+			expectError: true,
+			errorMsg:    "--output - cannot be used with the html format",
+		}, {
+			name:        "stdout output with check. should return error",
+			logLevel:    0,
+			input:       "./dashboards",
+			output:      "-",
+			check:       true,
+			expectError: true,
+			errorMsg:    "--output - cannot be used with --check",
+		}, {
+			name:        "grafana url without input. should return no error",
+			logLevel:    0,
+			fromGrafana: "https://grafana.example.com",
+		}, {
+			name:        "grafana tag without grafana url. should return error",
+			logLevel:    0,
+			input:       "./dashboards",
+			grafanaTags: []string{"prod"},
+			expectError: true,
+			errorMsg:    "--grafana-folder, --grafana-tag and --grafana-query require --from-grafana",
+		}, {
+			name:         "grafana token with basic auth. should return error",
+			logLevel:     0,
+			fromGrafana:  "https://grafana.example.com",
+			grafanaToken: "glsa_test",
+			grafanaUser:  "admin",
+			expectError:  true,
+			errorMsg:     "--grafana-token cannot be used with --grafana-user",
+		}, {
+			name:            "grafana password without user. should return error",
+			logLevel:        0,
+			fromGrafana:     "https://grafana.example.com",
+			grafanaPassword: "secret",
+			expectError:     true,
+			errorMsg:        "--grafana-password requires --grafana-user",
 		},
 	}
 
@@ -481,7 +1151,31 @@ func TestValidateFlagValues(t *testing.T) {
 			testLogLevel := tc.logLevel
 			testInput := tc.input
 			logLevel = testLogLevel
-			input = testInput
+			inputs = nonEmpty([]string{testInput})
+			format = tc.format
+			if format == "" {
+				format = "markdown"
+			}
+			reportFormat = tc.reportFormat
+			if reportFormat == "" {
+				reportFormat = "json"
+			}
+			templateFile = tc.templateFile
+			templateDir = tc.templateDir
+			inject = tc.inject
+			includes = tc.includes
+			excludes = tc.excludes
+			naming = tc.naming
+			if naming == "" {
+				naming = "basename"
+			}
+			output = tc.output
+			check = tc.check
+			fromGrafana = tc.fromGrafana
+			grafanaToken = tc.grafanaToken
+			grafanaUser = tc.grafanaUser
+			grafanaPassword = tc.grafanaPassword
+			grafanaTags = tc.grafanaTags
 
 			var buf bytes.Buffer
 			setupLog = slog.New(slog.NewJSONHandler(&buf, nil))
@@ -501,6 +1195,66 @@ func TestValidateFlagValues(t *testing.T) {
 	}
 }
 
+func TestGrafanaCredentialsFromEnv(t *testing.T) {
+	tests := []struct {
+		name             string
+		args             []string
+		env              map[string]string
+		expectedToken    string
+		expectedPassword string
+	}{
+		{
+			name:          "token should be read from the environment with from-grafana",
+			args:          []string{"program", "--from-grafana", "https://grafana.example.com"},
+			env:           map[string]string{"GRAFANA_TOKEN": "glsa_env"},
+			expectedToken: "glsa_env",
+		},
+		{
+			name: "credentials should not be read from the environment without from-grafana",
+			args: []string{"program", "--input", "dashboard.json", "--grafana-user", "admin"},
+			env:  map[string]string{"GRAFANA_TOKEN": "glsa_env", "GRAFANA_PASSWORD": "env-secret"},
+		},
+		{
+			name:          "token flag should override the environment",
+			args:          []string{"program", "--from-grafana", "https://grafana.example.com", "--grafana-token", "glsa_flag"},
+			env:           map[string]string{"GRAFANA_TOKEN": "glsa_env"},
+			expectedToken: "glsa_flag",
+		},
+		{
+			name:             "basic auth flags should override the environment token",
+			args:             []string{"program", "--from-grafana", "https://grafana.example.com", "--grafana-user", "admin", "--grafana-password", "flag-secret"},
+			env:              map[string]string{"GRAFANA_TOKEN": "glsa_env", "GRAFANA_PASSWORD": "env-secret"},
+			expectedPassword: "flag-secret",
+		},
+		{
+			name:             "password should be read from the environment for basic auth",
+			args:             []string{"program", "--from-grafana", "https://grafana.example.com", "--grafana-user", "admin"},
+			env:              map[string]string{"GRAFANA_TOKEN": "glsa_env", "GRAFANA_PASSWORD": "env-secret"},
+			expectedPassword: "env-secret",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("GRAFANA_TOKEN", "")
+			t.Setenv("GRAFANA_PASSWORD", "")
+			for key, value := range tc.env {
+				t.Setenv(key, value)
+			}
+			t.Cleanup(func() { fromGrafana, grafanaToken, grafanaUser, grafanaPassword = "", "", "", "" })
+
+			var buf bytes.Buffer
+			out = &buf
+			os.Args = tc.args
+
+			runnerInstance := &runner{fileProcessor: func() error { return nil }}
+			assert.NoError(t, runnerInstance.run())
+			assert.Equal(t, tc.expectedToken, grafanaToken)
+			assert.Equal(t, tc.expectedPassword, grafanaPassword)
+		})
+	}
+}
+
 // TestPrintVersion tests the printVersion function with different version information scenarios
 func TestPrintVersion(t *testing.T) {
 	tests := []struct {
@@ -0,0 +1,333 @@
+// Package grafana provides a client of the Grafana HTTP API listing and
+// downloading the dashboards of a running Grafana instance, so that they can
+// be documented like dashboard files.
+package grafana
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/rastogiji/autodoc-grafana/pkg/utils"
+)
+
+const (
+	// searchPageSize is the number of dashboards requested per page of /api/search
+	searchPageSize = 1000
+	// maxConcurrentRequests limits the dashboards downloaded at the same time
+	maxConcurrentRequests = 8
+	// defaultTimeout is the timeout of each request to the Grafana API
+	defaultTimeout = 30 * time.Second
+)
+
+// Auth holds the credentials used to authenticate to the Grafana API: an API
+// or service account token, or a user name and password for basic auth.
+type Auth struct {
+	// Token is sent as a bearer token, if set
+	Token string
+	// Username and Password are sent with basic auth, if Username is set
+	Username string
+	Password string
+}
+
+// SearchOptions filters the dashboards listed by Client.Search. Empty
+// filters select every dashboard.
+type SearchOptions struct {
+	// FolderUIDs restricts the search to dashboards in these folders
+	FolderUIDs []string
+	// Tags restricts the search to dashboards having all of these tags
+	Tags []string
+	// Query restricts the search to dashboards whose title matches it
+	Query string
+}
+
+// SearchHit is a dashboard listed by /api/search.
+type SearchHit struct {
+	UID         string   `json:"uid"`
+	Title       string   `json:"title"`
+	URL         string   `json:"url"`
+	Tags        []string `json:"tags"`
+	FolderUID   string   `json:"folderUid"`
+	FolderTitle string   `json:"folderTitle"`
+}
+
+// Dashboard is a dashboard downloaded from Grafana.
+type Dashboard struct {
+	// UID is the dashboard UID
+	UID string
+	// Title is the dashboard title
+	Title string
+	// Slug is the URL slug of the dashboard, derived from its title
+	Slug string
+	// FolderPath contains the titles of the folders of the dashboard, from
+	// the top-level folder down, empty for the root folder
+	FolderPath []string
+	// Model is the JSON model of the dashboard, unwrapped from the
+	// {dashboard, meta} envelope of the API
+	Model json.RawMessage
+}
+
+// dashboardResponse is the envelope returned by /api/dashboards/uid/:uid.
+type dashboardResponse struct {
+	Dashboard json.RawMessage `json:"dashboard"`
+	Meta      struct {
+		Slug        string `json:"slug"`
+		FolderUID   string `json:"folderUid"`
+		FolderTitle string `json:"folderTitle"`
+	} `json:"meta"`
+}
+
+// folderResponse is the folder returned by /api/folders/:uid. Parents is only
+// set by Grafana versions supporting nested folders.
+type folderResponse struct {
+	UID     string `json:"uid"`
+	Title   string `json:"title"`
+	Parents []struct {
+		UID   string `json:"uid"`
+		Title string `json:"title"`
+	} `json:"parents"`
+}
+
+// Client is a client of the Grafana HTTP API. It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	auth       Auth
+	httpClient *http.Client
+
+	mu      sync.Mutex
+	folders map[string][]string
+}
+
+// NewClient creates a client of the Grafana instance at baseURL, e.g.
+// https://grafana.example.com or https://example.com/grafana when Grafana is
+// served from a sub path.
+//
+// Parameters:
+//   - baseURL: the URL of the Grafana instance
+//   - auth: the credentials used to authenticate, which may be empty for
+//     instances allowing anonymous access
+//
+// Returns an error if baseURL is not an absolute http or https URL.
+func NewClient(baseURL string, auth Auth) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid grafana url %s: %w", baseURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid grafana url %s: expected an http or https url", baseURL)
+	}
+	return &Client{
+		baseURL:    u,
+		auth:       auth,
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		folders:    make(map[string][]string),
+	}, nil
+}
+
+// Search lists the dashboards matching the filters, following the pages of
+// /api/search.
+//
+// Returns an error if a request fails.
+func (c *Client) Search(opts SearchOptions) ([]SearchHit, error) {
+	var hits []SearchHit
+	for page := 1; ; page++ {
+		query := url.Values{}
+		query.Set("type", "dash-db")
+		query.Set("limit", strconv.Itoa(searchPageSize))
+		query.Set("page", strconv.Itoa(page))
+		if opts.Query != "" {
+			query.Set("query", opts.Query)
+		}
+		for _, tag := range opts.Tags {
+			query.Add("tag", tag)
+		}
+		for _, uid := range opts.FolderUIDs {
+			query.Add("folderUIDs", uid)
+		}
+
+		var pageHits []SearchHit
+		if err := c.get("/api/search", query, &pageHits); err != nil {
+			return nil, err
+		}
+		hits = append(hits, pageHits...)
+		if len(pageHits) < searchPageSize {
+			return hits, nil
+		}
+	}
+}
+
+// Dashboard downloads a dashboard and resolves the path of its folder.
+//
+// Parameters:
+//   - uid: the dashboard UID
+//
+// Returns an error if a request fails or the response has no dashboard.
+func (c *Client) Dashboard(uid string) (*Dashboard, error) {
+	var resp dashboardResponse
+	if err := c.get("/api/dashboards/uid/"+url.PathEscape(uid), nil, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Dashboard) == 0 || string(resp.Dashboard) == "null" {
+		return nil, fmt.Errorf("grafana returned no dashboard for uid %s", uid)
+	}
+	var model struct {
+		Title string `json:"title"`
+	}
+	if err := json.Unmarshal(resp.Dashboard, &model); err != nil {
+		return nil, fmt.Errorf("error decoding dashboard %s: %w", uid, err)
+	}
+
+	folderPath, err := c.folderPath(resp.Meta.FolderUID, resp.Meta.FolderTitle)
+	if err != nil {
+		return nil, err
+	}
+	return &Dashboard{
+		UID:        uid,
+		Title:      model.Title,
+		Slug:       resp.Meta.Slug,
+		FolderPath: folderPath,
+		Model:      resp.Dashboard,
+	}, nil
+}
+
+// Dashboards lists the dashboards matching the filters and downloads them
+// concurrently. The dashboards are returned in the order of the search.
+//
+// Returns an error if the search or any download fails.
+func (c *Client) Dashboards(opts SearchOptions) ([]*Dashboard, error) {
+	hits, err := c.Search(opts)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("Found dashboards in grafana", slog.Int("count", len(hits)))
+
+	dashboards := make([]*Dashboard, len(hits))
+	limit := make(chan struct{}, maxConcurrentRequests)
+	var g multierror.Group
+	for i, hit := range hits {
+		g.Go(func() error {
+			limit <- struct{}{}
+			defer func() { <-limit }()
+			dashboard, err := c.Dashboard(hit.UID)
+			if err != nil {
+				slog.Error("error downloading dashboard", slog.Any("error", err), slog.String("uid", hit.UID))
+				return err
+			}
+			dashboards[i] = dashboard
+			return nil
+		})
+	}
+	if err := utils.SafeMultierrorWait(&g); err != nil {
+		return nil, err
+	}
+	return dashboards, nil
+}
+
+// folderPath returns the titles of a folder and of its parents, from the
+// top-level folder down. Folders are looked up once. The title from the
+// dashboard metadata is used if the folder cannot be looked up, e.g. with a
+// token that can read dashboards but not folders.
+func (c *Client) folderPath(uid, title string) ([]string, error) {
+	if uid == "" {
+		return nil, nil
+	}
+	c.mu.Lock()
+	folderPath, ok := c.folders[uid]
+	c.mu.Unlock()
+	if ok {
+		return folderPath, nil
+	}
+
+	var folder folderResponse
+	if err := c.get("/api/folders/"+url.PathEscape(uid), nil, &folder); err != nil {
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || (statusErr.StatusCode != http.StatusForbidden && statusErr.StatusCode != http.StatusNotFound) {
+			return nil, err
+		}
+		slog.Warn("cannot look up folder, using its title only", slog.Any("error", err), slog.String("folder-uid", uid))
+		folderPath = []string{title}
+	} else {
+		for _, parent := range folder.Parents {
+			folderPath = append(folderPath, parent.Title)
+		}
+		folderPath = append(folderPath, folder.Title)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.folders[uid] = folderPath
+	return folderPath, nil
+}
+
+// StatusError is returned when the Grafana API answers with a non-2xx status.
+type StatusError struct {
+	// StatusCode is the HTTP status code of the response
+	StatusCode int
+	// Path is the path of the request
+	Path string
+	// Message is the error message returned by Grafana, if any
+	Message string
+}
+
+// Error implements the error interface.
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("grafana returned %d %s for %s", e.StatusCode, http.StatusText(e.StatusCode), e.Path)
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// get sends an authenticated GET request to an API path and decodes the JSON
+// response into v.
+func (c *Client) get(apiPath string, query url.Values, v any) error {
+	u := *c.baseURL
+	u.Path = path.Join("/", strings.TrimSuffix(u.Path, "/"), apiPath)
+	u.RawPath = ""
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("error creating grafana request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	switch {
+	case c.auth.Token != "":
+		req.Header.Set("Authorization", "Bearer "+c.auth.Token)
+	case c.auth.Username != "":
+		req.SetBasicAuth(c.auth.Username, c.auth.Password)
+	}
+
+	slog.Debug("sending grafana request", slog.String("path", apiPath))
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending grafana request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading grafana response for %s: %w", apiPath, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(body, &apiErr)
+		return &StatusError{StatusCode: resp.StatusCode, Path: apiPath, Message: apiErr.Message}
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("error decoding grafana response for %s: %w", apiPath, err)
+	}
+	return nil
+}
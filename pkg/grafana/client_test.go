@@ -0,0 +1,198 @@
+package grafana
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestServer starts a stand-in of the Grafana API serving two dashboards:
+// one in a nested folder and one in a folder the token cannot look up. It
+// requires the given Authorization header.
+func newTestServer(t *testing.T, authorization string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/search", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		hits := []SearchHit{
+			{UID: "api", Title: "API Overview", FolderUID: "payments", FolderTitle: "Payments", Tags: []string{"prod"}},
+			{UID: "legacy", Title: "Legacy", FolderUID: "secret", FolderTitle: "Secret Team"},
+		}
+		var filtered []SearchHit
+		for _, hit := range hits {
+			if tag := query.Get("tag"); tag != "" && !strings.Contains(strings.Join(hit.Tags, ","), tag) {
+				continue
+			}
+			if folders := query["folderUIDs"]; len(folders) > 0 && !strings.Contains(strings.Join(folders, ","), hit.FolderUID) {
+				continue
+			}
+			if q := query.Get("query"); q != "" && !strings.Contains(strings.ToLower(hit.Title), strings.ToLower(q)) {
+				continue
+			}
+			filtered = append(filtered, hit)
+		}
+		json.NewEncoder(w).Encode(filtered)
+	})
+	mux.HandleFunc("GET /api/dashboards/uid/{uid}", func(w http.ResponseWriter, r *http.Request) {
+		switch uid := r.PathValue("uid"); uid {
+		case "api":
+			fmt.Fprint(w, `{"dashboard": {"uid": "api", "title": "API Overview", "panels": []}, "meta": {"slug": "api-overview", "folderUid": "payments", "folderTitle": "Payments"}}`)
+		case "legacy":
+			fmt.Fprint(w, `{"dashboard": {"uid": "legacy", "title": "Legacy", "panels": []}, "meta": {"slug": "legacy", "folderUid": "secret", "folderTitle": "Secret Team"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Dashboard not found"}`)
+		}
+	})
+	mux.HandleFunc("GET /api/folders/payments", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"uid": "payments", "title": "Payments", "parents": [{"uid": "teams", "title": "Teams"}]}`)
+	})
+	mux.HandleFunc("GET /api/folders/secret", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"message": "Access denied"}`)
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != authorization {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"message": "invalid API key"}`)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		expectError bool
+		errorMsg    string
+	}{
+		{name: "https url should create a client", url: "https://grafana.example.com"},
+		{name: "url with a sub path should create a client", url: "http://example.com/grafana/"},
+		{name: "url without scheme should return error", url: "grafana.example.com", expectError: true, errorMsg: "expected an http or https url"},
+		{name: "malformed url should return error", url: "http://[::1", expectError: true, errorMsg: "invalid grafana url"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewClient(tc.url, Auth{})
+			if tc.expectError {
+				assert.ErrorContains(t, err, tc.errorMsg)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NotNil(t, client)
+		})
+	}
+}
+
+func TestDashboards(t *testing.T) {
+	t.Run("token auth should download every dashboard with its folder path", func(t *testing.T) {
+		server := newTestServer(t, "Bearer glsa_test")
+		client, err := NewClient(server.URL, Auth{Token: "glsa_test"})
+		assert.NoError(t, err)
+
+		dashboards, err := client.Dashboards(SearchOptions{})
+		assert.NoError(t, err)
+		assert.Len(t, dashboards, 2)
+		assert.Equal(t, "API Overview", dashboards[0].Title)
+		assert.Equal(t, "api-overview", dashboards[0].Slug)
+		assert.Equal(t, []string{"Teams", "Payments"}, dashboards[0].FolderPath)
+		assert.JSONEq(t, `{"uid": "api", "title": "API Overview", "panels": []}`, string(dashboards[0].Model))
+		assert.Equal(t, []string{"Secret Team"}, dashboards[1].FolderPath, "folder title should be used when the folder cannot be looked up")
+	})
+
+	t.Run("basic auth should authenticate with the user name and password", func(t *testing.T) {
+		server := newTestServer(t, "Basic YWRtaW46c2VjcmV0")
+		client, err := NewClient(server.URL, Auth{Username: "admin", Password: "secret"})
+		assert.NoError(t, err)
+
+		dashboards, err := client.Dashboards(SearchOptions{})
+		assert.NoError(t, err)
+		assert.Len(t, dashboards, 2)
+	})
+
+	t.Run("filters should be sent to the search api", func(t *testing.T) {
+		server := newTestServer(t, "Bearer glsa_test")
+		client, err := NewClient(server.URL, Auth{Token: "glsa_test"})
+		assert.NoError(t, err)
+
+		hits, err := client.Search(SearchOptions{Tags: []string{"prod"}})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"api"}, searchUIDs(hits))
+
+		hits, err = client.Search(SearchOptions{FolderUIDs: []string{"secret"}})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"legacy"}, searchUIDs(hits))
+
+		hits, err = client.Search(SearchOptions{Query: "overview"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"api"}, searchUIDs(hits))
+	})
+
+	t.Run("invalid credentials should return the grafana error", func(t *testing.T) {
+		server := newTestServer(t, "Bearer glsa_test")
+		client, err := NewClient(server.URL, Auth{Token: "wrong"})
+		assert.NoError(t, err)
+
+		_, err = client.Dashboards(SearchOptions{})
+		assert.EqualError(t, err, "grafana returned 401 Unauthorized for /api/search: invalid API key")
+		var statusErr *StatusError
+		assert.ErrorAs(t, err, &statusErr)
+		assert.Equal(t, http.StatusUnauthorized, statusErr.StatusCode)
+	})
+
+	t.Run("missing dashboard should return error", func(t *testing.T) {
+		server := newTestServer(t, "")
+		client, err := NewClient(server.URL, Auth{})
+		assert.NoError(t, err)
+
+		_, err = client.Dashboard("missing")
+		assert.EqualError(t, err, "grafana returned 404 Not Found for /api/dashboards/uid/missing: Dashboard not found")
+	})
+}
+
+func TestSearchPagination(t *testing.T) {
+	var pages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/grafana/api/search", r.URL.Path)
+		assert.Equal(t, "dash-db", r.URL.Query().Get("type"))
+		page := r.URL.Query().Get("page")
+		pages = append(pages, page)
+		count := searchPageSize
+		if page == "2" {
+			count = 1
+		}
+		hits := make([]SearchHit, count)
+		for i := range hits {
+			hits[i].UID = page + "-" + strconv.Itoa(i)
+		}
+		json.NewEncoder(w).Encode(hits)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(server.URL+"/grafana/", Auth{})
+	assert.NoError(t, err)
+	hits, err := client.Search(SearchOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, hits, searchPageSize+1)
+	assert.Equal(t, []string{"1", "2"}, pages)
+}
+
+// searchUIDs returns the UIDs of search hits.
+func searchUIDs(hits []SearchHit) []string {
+	var uids []string
+	for _, hit := range hits {
+		uids = append(uids, hit.UID)
+	}
+	return uids
+}
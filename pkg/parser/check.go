@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// StaleFile describes a documentation file whose content doesn't match the
+// generated documentation.
+type StaleFile struct {
+	// Path is the path of the documentation file
+	Path string
+	// Missing indicates whether the file doesn't exist
+	Missing bool
+	// Diff is the unified diff from the file to the generated documentation
+	Diff string
+}
+
+// Check compares the generated documentation with the existing documentation
+// files instead of writing them, e.g. to verify in CI that committed docs are
+// up to date. It is safe for concurrent use so that dashboards can be
+// documented in parallel.
+type Check struct {
+	mu    sync.Mutex
+	stale []StaleFile
+}
+
+// compare records the file as stale if its content differs from data.
+func (c *Check) compare(path string, data []byte) error {
+	current, err := os.ReadFile(path)
+	missing := errors.Is(err, os.ErrNotExist)
+	if err != nil && !missing {
+		return fmt.Errorf("error reading %s: %w", path, err)
+	}
+	if !missing && bytes.Equal(current, data) {
+		return nil
+	}
+
+	from := path
+	if missing {
+		from = "/dev/null"
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(current)),
+		B:        difflib.SplitLines(string(data)),
+		FromFile: from,
+		ToFile:   path + " (generated)",
+		Context:  3,
+	})
+	if err != nil {
+		return fmt.Errorf("error comparing %s: %w", path, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stale = append(c.stale, StaleFile{Path: path, Missing: missing, Diff: diff})
+	return nil
+}
+
+// Stale returns the stale or missing documentation files ordered by path.
+func (c *Check) Stale() []StaleFile {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stale := slices.Clone(c.stale)
+	slices.SortFunc(stale, func(a, b StaleFile) int {
+		return cmp.Compare(a.Path, b.Path)
+	})
+	return stale
+}
+
+// WriteDiff writes the unified diff of every stale or missing documentation file.
+//
+// Parameters:
+//   - w: the writer the diffs are written to
+//
+// Returns an error if the diffs cannot be written.
+func (c *Check) WriteDiff(w io.Writer) error {
+	for _, file := range c.Stale() {
+		if _, err := io.WriteString(w, file.Diff); err != nil {
+			return fmt.Errorf("error writing diff: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeOutput writes a documentation file, or compares it with the existing
+// file when opts.Check is set.
+func writeOutput(path string, data []byte, opts Options) error {
+	if opts.Check != nil {
+		return opts.Check.compare(path, data)
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,71 @@
+package parser
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	current := filepath.Join(dir, "current.md")
+	stale := filepath.Join(dir, "stale.md")
+	missing := filepath.Join(dir, "missing.md")
+	assert.NoError(t, os.WriteFile(current, []byte("# Title\n"), 0644))
+	assert.NoError(t, os.WriteFile(stale, []byte("# Title\nold\n"), 0644))
+
+	check := &Check{}
+	opts := Options{Check: check}
+	assert.NoError(t, writeOutput(current, []byte("# Title\n"), opts))
+	assert.NoError(t, writeOutput(stale, []byte("# Title\nnew\n"), opts))
+	assert.NoError(t, writeOutput(missing, []byte("# Title\n"), opts))
+
+	files := check.Stale()
+	assert.Len(t, files, 2)
+	assert.Equal(t, missing, files[0].Path)
+	assert.True(t, files[0].Missing)
+	assert.Contains(t, files[0].Diff, "--- /dev/null\n+++ "+missing+" (generated)\n")
+	assert.Equal(t, stale, files[1].Path)
+	assert.False(t, files[1].Missing)
+	assert.Contains(t, files[1].Diff, "-old\n+new\n")
+
+	var buf bytes.Buffer
+	assert.NoError(t, check.WriteDiff(&buf))
+	assert.Equal(t, files[0].Diff+files[1].Diff, buf.String())
+
+	bs, err := os.ReadFile(stale)
+	assert.NoError(t, err)
+	assert.Equal(t, "# Title\nold\n", string(bs), "checked files should not be written")
+	assert.NoFileExists(t, missing)
+
+	t.Run("trailing newline differences should be reported", func(t *testing.T) {
+		check := &Check{}
+		assert.NoError(t, writeOutput(current, []byte("# Title"), Options{Check: check}))
+		assert.Len(t, check.Stale(), 1)
+		assert.NotEmpty(t, check.Stale()[0].Diff)
+	})
+}
+
+func TestCreateDocumentationFromFileCheck(t *testing.T) {
+	outputDir := t.TempDir()
+	check := &Check{}
+	assert.NoError(t, CreateDocumentationFromFile("testdata/rows_dashboard.json", outputDir, Options{Check: check}))
+	assert.Len(t, check.Stale(), 1)
+	assert.NoFileExists(t, filepath.Join(outputDir, "rows_dashboard.md"))
+
+	assert.NoError(t, CreateDocumentationFromFile("testdata/rows_dashboard.json", outputDir, Options{}))
+	check = &Check{}
+	assert.NoError(t, CreateDocumentationFromFile("testdata/rows_dashboard.json", outputDir, Options{Check: check}))
+	assert.Empty(t, check.Stale())
+
+	check = &Check{}
+	siteDir := filepath.Join(t.TempDir(), "site")
+	doc, err := BuildDocumentation("testdata/rows_dashboard.json", Options{})
+	assert.NoError(t, err)
+	assert.NoError(t, CreateSite([]*DashboardDoc{doc}, siteDir, Options{Check: check}))
+	assert.Len(t, check.Stale(), 4)
+	assert.NoDirExists(t, siteDir)
+}
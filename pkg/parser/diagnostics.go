@@ -0,0 +1,390 @@
+package parser
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Severity is the severity of a diagnostic. Its values match the SARIF result levels.
+type Severity string
+
+const (
+	// SeverityError is used for problems preventing part of a dashboard from
+	// being documented, e.g. queries that cannot be parsed
+	SeverityError Severity = "error"
+	// SeverityWarning is used for problems making the documentation incomplete
+	SeverityWarning Severity = "warning"
+	// SeverityNote is used for suggestions improving the documentation
+	SeverityNote Severity = "note"
+)
+
+// Diagnostic codes identify the kind of problem reported by a diagnostic.
+const (
+	// CodeInvalidDashboard is reported for dashboard files that are not valid dashboard JSON
+	CodeInvalidDashboard = "invalid-dashboard"
+	// CodeQueryParseError is reported for panel or variable queries that cannot be parsed
+	CodeQueryParseError = "query-parse-error"
+	// CodeUnknownDatasource is reported for targets whose datasource type has no
+	// registered extractor, so that only their raw query is documented
+	CodeUnknownDatasource = "unknown-datasource"
+	// CodeMissingDescription is reported for panels without a description
+	CodeMissingDescription = "missing-description"
+	// CodeUnresolvedLibraryPanel is reported for library panel references that
+	// cannot be resolved
+	CodeUnresolvedLibraryPanel = "unresolved-library-panel"
+	// CodeDeprecatedPanel is reported for panels of a deprecated type, which
+	// are documented as the panel type Grafana migrates them to
+	CodeDeprecatedPanel = "deprecated-panel"
+)
+
+// diagnosticRules describes each diagnostic code for report consumers.
+var diagnosticRules = map[string]string{
+	CodeInvalidDashboard:       "The file is not a valid Grafana dashboard JSON model",
+	CodeQueryParseError:        "The query cannot be parsed, so the entities it reads are not documented",
+	CodeUnknownDatasource:      "The datasource type has no query extractor, so only the raw query is documented",
+	CodeMissingDescription:     "The panel has no description",
+	CodeUnresolvedLibraryPanel: "The library panel reference cannot be resolved, so the panel content is not documented",
+	CodeDeprecatedPanel:        "The panel type is deprecated, so the panel is documented as the panel type Grafana migrates it to",
+}
+
+// Diagnostic describes a problem found while documenting a dashboard.
+type Diagnostic struct {
+	// File is the path of the dashboard file, or of the library panel file
+	// for problems found in the content of a resolved library panel
+	File string `json:"file"`
+	// Pointer is the JSON pointer (RFC 6901) of the offending value in the
+	// file, e.g. /panels/3/panels/1/targets/0/expr
+	Pointer string `json:"pointer"`
+	// Line is the line of the offending value in the file, 0 if unknown
+	Line int `json:"line,omitempty"`
+	// PanelID is the ID of the panel the problem was found in, if any
+	PanelID int `json:"panelId,omitempty"`
+	// PanelTitle is the title of the panel the problem was found in, if any
+	PanelTitle string `json:"panelTitle,omitempty"`
+	// Location describes where the problem was found in human terms, e.g.
+	// panel "CPU", target A
+	Location string `json:"location"`
+	// Severity is the severity of the problem
+	Severity Severity `json:"severity"`
+	// Code identifies the kind of problem
+	Code string `json:"code"`
+	// Message describes the problem
+	Message string `json:"message"`
+
+	// err is the error behind the diagnostic, if any
+	err error
+}
+
+// newPanelDiagnostic creates a diagnostic for a problem found in a panel, or
+// in the panel's value at the given JSON pointer suffix. Diagnostics of panels
+// read from another file than the dashboard, such as resolved library panels,
+// are located in that file.
+func newPanelDiagnostic(panel Panel, suffix, location string, severity Severity, code, message string) Diagnostic {
+	if location == "" {
+		location = fmt.Sprintf("panel %q", panel.Title)
+	}
+	var file string
+	var line int
+	if panel.source != nil {
+		file = panel.source.file
+		line = panel.source.lines.line(panel.pointer + suffix)
+	}
+	return Diagnostic{
+		File:       file,
+		Pointer:    panel.pointer + suffix,
+		Line:       line,
+		PanelID:    panel.ID,
+		PanelTitle: panel.Title,
+		Location:   location,
+		Severity:   severity,
+		Code:       code,
+		Message:    message,
+	}
+}
+
+// Diagnostics collects the diagnostics of a run. It is safe for concurrent use
+// so that dashboards can be documented in parallel.
+type Diagnostics struct {
+	mu          sync.Mutex
+	diagnostics []Diagnostic
+}
+
+// Add records diagnostics.
+func (d *Diagnostics) Add(diagnostics ...Diagnostic) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.diagnostics = append(d.diagnostics, diagnostics...)
+}
+
+// List returns the recorded diagnostics ordered by file and location in the file.
+func (d *Diagnostics) List() []Diagnostic {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	list := slices.Clone(d.diagnostics)
+	slices.SortStableFunc(list, func(a, b Diagnostic) int {
+		return cmp.Or(
+			cmp.Compare(a.File, b.File),
+			cmp.Compare(a.Line, b.Line),
+			cmp.Compare(a.Pointer, b.Pointer),
+		)
+	})
+	return list
+}
+
+// WriteJSON writes the recorded diagnostics as a JSON array.
+//
+// Parameters:
+//   - w: the writer the report is written to
+//
+// Returns an error if the report cannot be written.
+func (d *Diagnostics) WriteJSON(w io.Writer) error {
+	list := d.List()
+	if list == nil {
+		list = []Diagnostic{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(list); err != nil {
+		return fmt.Errorf("error writing json report: %w", err)
+	}
+	return nil
+}
+
+// sarifLog is the subset of the SARIF 2.1.0 log format written by WriteSARIF.
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string          `json:"ruleId"`
+	Level      Severity        `json:"level"`
+	Message    sarifMessage    `json:"message"`
+	Locations  []sarifLocation `json:"locations"`
+	Properties map[string]any  `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// WriteSARIF writes the recorded diagnostics as a SARIF 2.1.0 log, which CI
+// tools such as GitHub code scanning use to annotate the dashboard files.
+//
+// Parameters:
+//   - w: the writer the report is written to
+//   - toolVersion: the version of the tool reported in the log
+//
+// Returns an error if the report cannot be written.
+func (d *Diagnostics) WriteSARIF(w io.Writer, toolVersion string) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "grafana-autodoc",
+			Version:        toolVersion,
+			InformationURI: "https://github.com/rastogiji/grafana-autodoc",
+		}},
+		Results: []sarifResult{},
+	}
+	codes := make([]string, 0, len(diagnosticRules))
+	for code := range diagnosticRules {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	for _, code := range codes {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+			ID:               code,
+			ShortDescription: sarifMessage{Text: diagnosticRules[code]},
+		})
+	}
+
+	for _, diag := range d.List() {
+		location := sarifLocation{
+			PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: strings.ReplaceAll(diag.File, "\\", "/")},
+			},
+		}
+		if diag.Line > 0 {
+			location.PhysicalLocation.Region = &sarifRegion{StartLine: diag.Line}
+		}
+		if diag.Pointer != "" {
+			location.LogicalLocations = []sarifLogicalLocation{{FullyQualifiedName: diag.Pointer, Kind: "member"}}
+		}
+		result := sarifResult{
+			RuleID:    diag.Code,
+			Level:     diag.Severity,
+			Message:   sarifMessage{Text: diag.Location + ": " + diag.Message},
+			Locations: []sarifLocation{location},
+			Properties: map[string]any{
+				"jsonPointer": diag.Pointer,
+			},
+		}
+		if diag.PanelTitle != "" {
+			result.Properties["panelId"] = diag.PanelID
+			result.Properties["panelTitle"] = diag.PanelTitle
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	})
+	if err != nil {
+		return fmt.Errorf("error writing sarif report: %w", err)
+	}
+	return nil
+}
+
+// pointerLines maps the JSON pointer of every value of a JSON document to
+// the line the value starts on. It is built with a single walk of the
+// document, so that every diagnostic of a dashboard can be located without
+// decoding the document again.
+type pointerLines map[string]int
+
+// newPointerLines walks a JSON document once and returns the line of each of
+// its values. It returns nil if the document cannot be decoded.
+func newPointerLines(data []byte) pointerLines {
+	lines := pointerLines{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// offsets only grow during the walk, so newlines are counted incrementally
+	var offset int64
+	line := 1
+	var walk func(path string) error
+	walk = func(path string) error {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		start := dec.InputOffset() - 1
+		line += bytes.Count(data[offset:start], []byte("\n"))
+		offset = start
+		lines[path] = line
+
+		delim, ok := tok.(json.Delim)
+		if !ok {
+			return nil
+		}
+		switch delim {
+		case '{':
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				name, _ := key.(string)
+				name = strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+				if err := walk(path + "/" + name); err != nil {
+					return err
+				}
+			}
+		case '[':
+			for i := 0; dec.More(); i++ {
+				if err := walk(path + "/" + strconv.Itoa(i)); err != nil {
+					return err
+				}
+			}
+		}
+		_, err = dec.Token()
+		return err
+	}
+	if err := walk(""); err != nil {
+		return nil
+	}
+	return lines
+}
+
+// line returns the line of the value a JSON pointer refers to, falling back
+// to the closest existing parent value. It returns 0 if the document could
+// not be decoded.
+func (l pointerLines) line(pointer string) int {
+	for {
+		if line, ok := l[pointer]; ok {
+			return line
+		}
+		i := strings.LastIndex(pointer, "/")
+		if i < 0 {
+			return 0
+		}
+		pointer = pointer[:i]
+	}
+}
+
+// lineOfError returns the line of the offending value of a JSON decoding
+// error, or 0 if the error doesn't carry an offset.
+func lineOfError(data []byte, err error) int {
+	var offset int64 = -1
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	}
+	if offset < 0 || offset > int64(len(data)) {
+		return 0
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
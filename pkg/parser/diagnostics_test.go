@@ -0,0 +1,197 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateDocumentationFromFileDiagnostics(t *testing.T) {
+	diagnostics := &Diagnostics{}
+	err := CreateDocumentationFromFile("testdata/bad_query.json", t.TempDir(), Options{Diagnostics: diagnostics})
+	assert.NoError(t, err)
+
+	var parseErrors []Diagnostic
+	for _, diag := range diagnostics.List() {
+		if diag.Code == CodeQueryParseError {
+			parseErrors = append(parseErrors, diag)
+		}
+	}
+	assert.Len(t, parseErrors, 2)
+
+	diag := parseErrors[0]
+	assert.Equal(t, "testdata/bad_query.json", diag.File)
+	assert.Equal(t, "/panels/1/targets/2/expr", diag.Pointer)
+	assert.Equal(t, 54, diag.Line)
+	assert.Equal(t, 2, diag.PanelID)
+	assert.Equal(t, "Pod Memory Usage", diag.PanelTitle)
+	assert.Equal(t, `panel "Pod Memory Usage", target #3`, diag.Location)
+	assert.Equal(t, SeverityError, diag.Severity)
+	assert.Contains(t, diag.Message, "error parsing promql expression")
+
+	assert.Equal(t, "/panels/4/targets/0/expr", parseErrors[1].Pointer)
+	assert.Equal(t, 125, parseErrors[1].Line)
+
+	t.Run("invalid dashboard should be reported", func(t *testing.T) {
+		diagnostics := &Diagnostics{}
+		err := CreateDocumentationFromFile("testdata/bad_schema.json", t.TempDir(), Options{Diagnostics: diagnostics})
+		assert.Error(t, err)
+
+		list := diagnostics.List()
+		assert.Len(t, list, 1)
+		assert.Equal(t, CodeInvalidDashboard, list[0].Code)
+		assert.Equal(t, SeverityError, list[0].Severity)
+	})
+
+	t.Run("unresolved library panels and unknown datasources should be reported", func(t *testing.T) {
+		diagnostics := &Diagnostics{}
+		err := CreateDocumentationFromFile("testdata/library_dashboard.json", t.TempDir(), Options{Diagnostics: diagnostics})
+		assert.NoError(t, err)
+
+		codes := make(map[string]int)
+		for _, diag := range diagnostics.List() {
+			codes[diag.Code]++
+		}
+		assert.Equal(t, 3, codes[CodeUnresolvedLibraryPanel])
+
+		diagnostics = &Diagnostics{}
+		err = CreateDocumentationFromFile("testdata/mixed_datasources_dashboard.json", t.TempDir(), Options{Diagnostics: diagnostics})
+		assert.NoError(t, err)
+
+		var unknown []Diagnostic
+		for _, diag := range diagnostics.List() {
+			if diag.Code == CodeUnknownDatasource {
+				unknown = append(unknown, diag)
+			}
+		}
+		assert.NotEmpty(t, unknown)
+		assert.Equal(t, SeverityNote, unknown[0].Severity)
+	})
+
+	t.Run("problems in resolved library panels should be located in the library panel file", func(t *testing.T) {
+		library, err := LoadLibraryPanels("testdata/library_panels")
+		assert.NoError(t, err)
+
+		diagnostics := &Diagnostics{}
+		err = CreateDocumentationFromFile("testdata/library_dashboard.json", t.TempDir(), Options{Diagnostics: diagnostics, LibraryPanels: library})
+		assert.NoError(t, err)
+
+		byPanel := make(map[string]Diagnostic)
+		for _, diag := range diagnostics.List() {
+			byPanel[diag.PanelTitle+" "+diag.Code] = diag
+		}
+		missing := byPanel["Memory Usage "+CodeMissingDescription]
+		assert.Equal(t, filepath.Join("testdata", "library_panels", "memory.json"), missing.File)
+		assert.Equal(t, "/result/model", missing.Pointer)
+		assert.Equal(t, 6, missing.Line)
+
+		unresolved := byPanel["Missing "+CodeUnresolvedLibraryPanel]
+		assert.Equal(t, "testdata/library_dashboard.json", unresolved.File)
+		assert.Equal(t, "/panels/1/panels/1/libraryPanel", unresolved.Pointer)
+	})
+}
+
+func TestPointerLines(t *testing.T) {
+	data := []byte(`{
+  "title": "Test",
+  "panels": [
+    {
+      "id": 1,
+      "targets": [
+        {"expr": "up"},
+        {"expr": "sum(up"}
+      ]
+    }
+  ],
+  "a/b": {"c~d": 1}
+}`)
+
+	tests := []struct {
+		name     string
+		pointer  string
+		expected int
+	}{
+		{
+			name:     "root pointer should return the first line",
+			pointer:  "",
+			expected: 1,
+		}, {
+			name:     "nested value should return its line",
+			pointer:  "/panels/0/targets/1/expr",
+			expected: 8,
+		}, {
+			name:     "missing member should fall back to its closest parent",
+			pointer:  "/panels/0/description",
+			expected: 4,
+		}, {
+			name:     "escaped member names should be matched",
+			pointer:  "/a~1b/c~0d",
+			expected: 12,
+		},
+	}
+
+	lines := newPointerLines(data)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, lines.line(tc.pointer))
+		})
+	}
+
+	t.Run("invalid document should return line 0", func(t *testing.T) {
+		assert.Equal(t, 0, newPointerLines([]byte(`{"panels": [`)).line("/panels/0"))
+	})
+}
+
+func TestDiagnosticsReports(t *testing.T) {
+	diagnostics := &Diagnostics{}
+	diagnostics.Add(
+		Diagnostic{File: "b.json", Pointer: "/panels/0", Line: 3, Location: `panel "B"`, PanelID: 1, PanelTitle: "B",
+			Severity: SeverityNote, Code: CodeMissingDescription, Message: "panel has no description"},
+		Diagnostic{File: "a.json", Pointer: "/panels/1/targets/0/expr", Line: 10, Location: `panel "A", target A`, PanelID: 2, PanelTitle: "A",
+			Severity: SeverityError, Code: CodeQueryParseError, Message: "error parsing promql expression"},
+	)
+
+	t.Run("json report should list the diagnostics ordered by file", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, diagnostics.WriteJSON(&buf))
+
+		var report []Diagnostic
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &report))
+		assert.Len(t, report, 2)
+		assert.Equal(t, "a.json", report[0].File)
+		assert.Equal(t, "/panels/1/targets/0/expr", report[0].Pointer)
+		assert.Contains(t, buf.String(), `"panelTitle": "A"`)
+	})
+
+	t.Run("empty json report should be an empty array", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, (&Diagnostics{}).WriteJSON(&buf))
+		assert.Equal(t, "[]\n", buf.String())
+	})
+
+	t.Run("sarif report should locate results in the dashboard files", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, diagnostics.WriteSARIF(&buf, "1.2.3"))
+
+		var report sarifLog
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &report))
+		assert.Equal(t, "2.1.0", report.Version)
+		assert.Len(t, report.Runs, 1)
+
+		run := report.Runs[0]
+		assert.Equal(t, "1.2.3", run.Tool.Driver.Version)
+		assert.Len(t, run.Tool.Driver.Rules, len(diagnosticRules))
+		assert.Len(t, run.Results, 2)
+
+		result := run.Results[0]
+		assert.Equal(t, CodeQueryParseError, result.RuleID)
+		assert.Equal(t, SeverityError, result.Level)
+		assert.Equal(t, `panel "A", target A: error parsing promql expression`, result.Message.Text)
+		assert.Equal(t, "a.json", result.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+		assert.Equal(t, 10, result.Locations[0].PhysicalLocation.Region.StartLine)
+		assert.Equal(t, "/panels/1/targets/0/expr", result.Locations[0].LogicalLocations[0].FullyQualifiedName)
+	})
+}
@@ -0,0 +1,377 @@
+package parser
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+
+	"github.com/rastogiji/autodoc-grafana/pkg/templates"
+)
+
+// DashboardDiff describes the semantic changes between two versions of a
+// dashboard, ignoring layout-only changes such as panel positions.
+type DashboardDiff struct {
+	// From is the name of the old version, e.g. its file or git ref
+	From string `json:"from"`
+	// To is the name of the new version
+	To string `json:"to"`
+	// Title is the title of the new version of the dashboard
+	Title string `json:"title"`
+	// TitleChange is set if the dashboard was renamed
+	TitleChange *Change `json:"titleChange,omitempty"`
+	// AddedPanels contains the panels only found in the new version
+	AddedPanels []PanelRef `json:"addedPanels,omitempty"`
+	// RemovedPanels contains the panels only found in the old version
+	RemovedPanels []PanelRef `json:"removedPanels,omitempty"`
+	// RenamedPanels contains the panels whose title changed
+	RenamedPanels []PanelRename `json:"renamedPanels,omitempty"`
+	// ChangedQueries contains the queries added, removed or changed in
+	// panels found in both versions
+	ChangedQueries []QueryChange `json:"changedQueries,omitempty"`
+	// AddedMetrics contains the metrics only used by the new version
+	AddedMetrics []string `json:"addedMetrics,omitempty"`
+	// RemovedMetrics contains the metrics only used by the old version
+	RemovedMetrics []string `json:"removedMetrics,omitempty"`
+	// ChangedVariables contains the template variables added, removed or changed
+	ChangedVariables []VariableChange `json:"changedVariables,omitempty"`
+	// ChangedThresholds contains the panels whose thresholds changed
+	ChangedThresholds []ThresholdChange `json:"changedThresholds,omitempty"`
+}
+
+// Change is a value that changed between two versions of a dashboard.
+type Change struct {
+	// From is the old value, empty if the value was added
+	From string `json:"from"`
+	// To is the new value, empty if the value was removed
+	To string `json:"to"`
+}
+
+// PanelRef identifies a panel in a dashboard diff.
+type PanelRef struct {
+	// ID is the panel ID
+	ID int `json:"id"`
+	// Title is the panel title
+	Title string `json:"title"`
+	// Type is the panel type
+	Type string `json:"type"`
+}
+
+// PanelRename is a panel whose title changed.
+type PanelRename struct {
+	// ID is the panel ID
+	ID int `json:"id"`
+	Change
+}
+
+// QueryChange is a query target added, removed or changed in a panel.
+type QueryChange struct {
+	// Panel is the panel of the query, in the new version if it still exists
+	Panel PanelRef `json:"panel"`
+	// RefID is the reference ID of the target, or its position if it has none
+	RefID string `json:"refId"`
+	Change
+}
+
+// VariableChange is a template variable added, removed or changed.
+type VariableChange struct {
+	// Name is the variable name
+	Name string `json:"name"`
+	// Kind is added, removed or changed
+	Kind string `json:"kind"`
+	// Fields contains the changed fields of a changed variable, by field name
+	Fields map[string]Change `json:"fields,omitempty"`
+}
+
+// ThresholdChange is a change of the thresholds of a panel.
+type ThresholdChange struct {
+	// Panel is the panel whose thresholds changed
+	Panel PanelRef `json:"panel"`
+	Change
+}
+
+// IsEmpty reports whether the diff contains no change.
+func (d *DashboardDiff) IsEmpty() bool {
+	return d.TitleChange == nil && len(d.AddedPanels) == 0 && len(d.RemovedPanels) == 0 &&
+		len(d.RenamedPanels) == 0 && len(d.ChangedQueries) == 0 && len(d.AddedMetrics) == 0 &&
+		len(d.RemovedMetrics) == 0 && len(d.ChangedVariables) == 0 && len(d.ChangedThresholds) == 0
+}
+
+// DiffDocumentation compares the documentation models of two versions of a
+// dashboard. Panels are matched by ID, then panels left unmatched are
+// matched by title, so that panels keep their identity when they are
+// renamed or when their ID changes. Query targets are matched by reference ID.
+//
+// Parameters:
+//   - from: the documentation model of the old version
+//   - to: the documentation model of the new version
+//
+// Returns the semantic changes between the two versions.
+func DiffDocumentation(from, to *DashboardDoc) *DashboardDiff {
+	diff := &DashboardDiff{From: from.File, To: to.File, Title: to.Title}
+	if from.Title != to.Title {
+		diff.TitleChange = &Change{From: from.Title, To: to.Title}
+	}
+
+	for _, pair := range matchPanels(from.Panels(), to.Panels()) {
+		switch {
+		case pair.from == nil:
+			diff.AddedPanels = append(diff.AddedPanels, newPanelRef(*pair.to))
+		case pair.to == nil:
+			diff.RemovedPanels = append(diff.RemovedPanels, newPanelRef(*pair.from))
+		default:
+			diffPanel(diff, *pair.from, *pair.to)
+		}
+	}
+
+	fromMetrics, toMetrics := dashboardMetrics(from), dashboardMetrics(to)
+	for _, metric := range toMetrics {
+		if !slices.Contains(fromMetrics, metric) {
+			diff.AddedMetrics = append(diff.AddedMetrics, metric)
+		}
+	}
+	for _, metric := range fromMetrics {
+		if !slices.Contains(toMetrics, metric) {
+			diff.RemovedMetrics = append(diff.RemovedMetrics, metric)
+		}
+	}
+
+	diff.ChangedVariables = diffVariables(from.Variables, to.Variables)
+	return diff
+}
+
+// panelPair is a panel matched between two versions of a dashboard. One side
+// is nil for added and removed panels.
+type panelPair struct {
+	from, to *PanelDoc
+}
+
+// matchPanels pairs the panels of two versions of a dashboard, in the
+// on-screen order of the new version followed by the removed panels.
+func matchPanels(from, to []PanelDoc) []panelPair {
+	matched := make([]bool, len(from))
+	pairs := make([]panelPair, len(to))
+	match := func(same func(a, b PanelDoc) bool) {
+		for i := range to {
+			if pairs[i].from != nil {
+				continue
+			}
+			for j := range from {
+				if !matched[j] && same(from[j], to[i]) {
+					matched[j] = true
+					pairs[i].from = &from[j]
+					break
+				}
+			}
+		}
+	}
+	match(func(a, b PanelDoc) bool { return a.ID != 0 && a.ID == b.ID })
+	match(func(a, b PanelDoc) bool { return a.Title == b.Title })
+
+	for i := range to {
+		pairs[i].to = &to[i]
+	}
+	for j := range from {
+		if !matched[j] {
+			pairs = append(pairs, panelPair{from: &from[j]})
+		}
+	}
+	return pairs
+}
+
+// diffPanel records the changes of a panel found in both versions.
+func diffPanel(diff *DashboardDiff, from, to PanelDoc) {
+	ref := newPanelRef(to)
+	if from.Title != to.Title {
+		diff.RenamedPanels = append(diff.RenamedPanels, PanelRename{
+			ID:     to.ID,
+			Change: Change{From: from.Title, To: to.Title},
+		})
+	}
+
+	queries := func(targets []TargetDoc) ([]string, map[string]string) {
+		var refs []string
+		byRef := make(map[string]string)
+		for i, target := range targets {
+			ref := target.RefID
+			if ref == "" {
+				ref = "#" + strconv.Itoa(i+1)
+			}
+			refs = append(refs, ref)
+			byRef[ref] = target.Query
+		}
+		return refs, byRef
+	}
+	fromRefs, fromQueries := queries(from.Targets)
+	toRefs, toQueries := queries(to.Targets)
+	for _, refID := range toRefs {
+		if query, ok := fromQueries[refID]; !ok || query != toQueries[refID] {
+			diff.ChangedQueries = append(diff.ChangedQueries, QueryChange{
+				Panel:  ref,
+				RefID:  refID,
+				Change: Change{From: query, To: toQueries[refID]},
+			})
+		}
+	}
+	for _, refID := range fromRefs {
+		if _, ok := toQueries[refID]; !ok {
+			diff.ChangedQueries = append(diff.ChangedQueries, QueryChange{
+				Panel:  ref,
+				RefID:  refID,
+				Change: Change{From: fromQueries[refID]},
+			})
+		}
+	}
+
+	if fromThresholds, toThresholds := from.thresholds().String(), to.thresholds().String(); fromThresholds != toThresholds {
+		diff.ChangedThresholds = append(diff.ChangedThresholds, ThresholdChange{
+			Panel:  ref,
+			Change: Change{From: fromThresholds, To: toThresholds},
+		})
+	}
+}
+
+// diffVariables compares the template variables of two versions of a
+// dashboard, matched by name, in the order of the new version followed by
+// the removed variables.
+func diffVariables(from, to []VariableDoc) []VariableChange {
+	fields := func(v VariableDoc) map[string]string {
+		return map[string]string{
+			"label":      v.Label,
+			"type":       v.Type,
+			"datasource": v.Datasource.String(),
+			"query":      v.Query,
+			"current":    v.Current,
+			"multi":      strconv.FormatBool(v.Multi),
+			"includeAll": strconv.FormatBool(v.IncludeAll),
+			"regex":      v.Regex,
+		}
+	}
+	old := make(map[string]VariableDoc)
+	for _, v := range from {
+		old[v.Name] = v
+	}
+
+	var changes []VariableChange
+	seen := make(map[string]bool)
+	for _, v := range to {
+		seen[v.Name] = true
+		previous, ok := old[v.Name]
+		if !ok {
+			changes = append(changes, VariableChange{Name: v.Name, Kind: "added"})
+			continue
+		}
+		fromFields, toFields := fields(previous), fields(v)
+		changed := make(map[string]Change)
+		for name, value := range toFields {
+			if fromFields[name] != value {
+				changed[name] = Change{From: fromFields[name], To: value}
+			}
+		}
+		if len(changed) > 0 {
+			changes = append(changes, VariableChange{Name: v.Name, Kind: "changed", Fields: changed})
+		}
+	}
+	for _, v := range from {
+		if !seen[v.Name] {
+			changes = append(changes, VariableChange{Name: v.Name, Kind: "removed"})
+		}
+	}
+	return changes
+}
+
+// dashboardMetrics returns the sorted metrics used by the panels and the
+// template variables of a dashboard.
+func dashboardMetrics(doc *DashboardDoc) []string {
+	var metrics []string
+	for _, panel := range doc.Panels() {
+		metrics = append(metrics, panel.Metrics...)
+	}
+	for _, v := range doc.Variables {
+		metrics = append(metrics, v.Metrics...)
+	}
+	slices.Sort(metrics)
+	return slices.Compact(metrics)
+}
+
+// newPanelRef identifies a documented panel.
+func newPanelRef(panel PanelDoc) PanelRef {
+	return PanelRef{ID: panel.ID, Title: panel.Title, Type: panel.Type}
+}
+
+// RenderDiff writes a dashboard diff as markdown, e.g. for a pull request
+// comment, or as JSON.
+//
+// Parameters:
+//   - w: the writer the diff is written to
+//   - diff: the dashboard diff
+//   - format: FormatMarkdown or FormatJSON
+//
+// Returns an error if the format is not supported or the diff cannot be rendered.
+func RenderDiff(w io.Writer, diff *DashboardDiff, format Format) error {
+	switch format {
+	case FormatMarkdown:
+		tmpl, err := templates.GetDiffTemplate()
+		if err != nil {
+			return err
+		}
+		if err := tmpl.Execute(w, newDiffData(diff)); err != nil {
+			slog.Error("error executing diff template", slog.Any("error", err))
+			return fmt.Errorf("error executing diff template: %w", err)
+		}
+		return nil
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(diff); err != nil {
+			return fmt.Errorf("error encoding json diff: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported diff format: %s", format)
+	}
+}
+
+// diffData is the data of the markdown diff template. Variable field changes
+// are flattened and sorted so that the output is stable.
+type diffData struct {
+	*DashboardDiff
+	// Empty indicates whether the diff contains no change
+	Empty bool
+	// VariableFields contains the changed fields of every changed variable
+	VariableFields []variableFieldChange
+}
+
+// variableFieldChange is a changed field of a template variable.
+type variableFieldChange struct {
+	// Name is the variable name
+	Name string
+	// Kind is added, removed or changed
+	Kind string
+	// Field is the changed field, empty for added and removed variables
+	Field string
+	Change
+}
+
+// newDiffData prepares a dashboard diff for the markdown diff template.
+func newDiffData(diff *DashboardDiff) diffData {
+	data := diffData{DashboardDiff: diff, Empty: diff.IsEmpty()}
+	for _, v := range diff.ChangedVariables {
+		if len(v.Fields) == 0 {
+			data.VariableFields = append(data.VariableFields, variableFieldChange{Name: v.Name, Kind: v.Kind})
+			continue
+		}
+		var fields []variableFieldChange
+		for field, change := range v.Fields {
+			fields = append(fields, variableFieldChange{Name: v.Name, Kind: v.Kind, Field: field, Change: change})
+		}
+		slices.SortFunc(fields, func(a, b variableFieldChange) int {
+			return cmp.Compare(a.Field, b.Field)
+		})
+		data.VariableFields = append(data.VariableFields, fields...)
+	}
+	return data
+}
@@ -0,0 +1,144 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	diffOldDashboard = `{
+		"title": "API",
+		"panels": [
+			{"id": 1, "type": "timeseries", "title": "Requests", "gridPos": {"x": 0, "y": 0},
+			 "targets": [{"refId": "A", "expr": "sum(rate(http_requests_total[5m]))"}]},
+			{"id": 2, "type": "stat", "title": "Errors", "gridPos": {"x": 12, "y": 0},
+			 "targets": [{"refId": "A", "expr": "sum(http_errors_total)"}],
+			 "fieldConfig": {"defaults": {"thresholds": {"mode": "absolute", "steps": [{"color": "green", "value": null}, {"color": "red", "value": 80}]}}}},
+			{"id": 3, "type": "gauge", "title": "Saturation", "gridPos": {"x": 0, "y": 8},
+			 "targets": [{"refId": "A", "expr": "node_load1"}]}
+		],
+		"templating": {"list": [
+			{"name": "job", "type": "query", "query": "label_values(up, job)"},
+			{"name": "old", "type": "custom", "query": "a,b"}
+		]}
+	}`
+	diffNewDashboard = `{
+		"title": "API",
+		"panels": [
+			{"id": 1, "type": "timeseries", "title": "Request rate", "gridPos": {"x": 0, "y": 20},
+			 "targets": [{"refId": "A", "expr": "sum by (code) (rate(http_requests_total[5m]))"}, {"refId": "B", "expr": "up"}]},
+			{"id": 2, "type": "stat", "title": "Errors", "gridPos": {"x": 12, "y": 20},
+			 "targets": [{"refId": "A", "expr": "sum(http_errors_total)"}],
+			 "fieldConfig": {"defaults": {"thresholds": {"mode": "absolute", "steps": [{"color": "green", "value": null}, {"color": "red", "value": 50}]}}}},
+			{"id": 4, "type": "table", "title": "Slow endpoints", "gridPos": {"x": 0, "y": 28},
+			 "targets": [{"refId": "A", "expr": "topk(5, http_request_duration_seconds_sum)"}]}
+		],
+		"templating": {"list": [
+			{"name": "job", "type": "query", "query": "label_values(up, job)", "multi": true},
+			{"name": "instance", "type": "query", "query": "label_values(up, instance)"}
+		]}
+	}`
+)
+
+func TestDiffDocumentation(t *testing.T) {
+	from, err := BuildDocumentationFromBytes("old.json", []byte(diffOldDashboard), Options{})
+	assert.NoError(t, err)
+	to, err := BuildDocumentationFromBytes("new.json", []byte(diffNewDashboard), Options{})
+	assert.NoError(t, err)
+
+	diff := DiffDocumentation(from, to)
+	assert.Equal(t, "old.json", diff.From)
+	assert.Equal(t, "new.json", diff.To)
+	assert.Nil(t, diff.TitleChange)
+	assert.Equal(t, []PanelRef{{ID: 4, Title: "Slow endpoints", Type: "table"}}, diff.AddedPanels)
+	assert.Equal(t, []PanelRef{{ID: 3, Title: "Saturation", Type: "gauge"}}, diff.RemovedPanels)
+	assert.Equal(t, []PanelRename{{ID: 1, Change: Change{From: "Requests", To: "Request rate"}}}, diff.RenamedPanels)
+	assert.Equal(t, []QueryChange{
+		{
+			Panel:  PanelRef{ID: 1, Title: "Request rate", Type: "timeseries"},
+			RefID:  "A",
+			Change: Change{From: "sum(rate(http_requests_total[5m]))", To: "sum by (code) (rate(http_requests_total[5m]))"},
+		}, {
+			Panel:  PanelRef{ID: 1, Title: "Request rate", Type: "timeseries"},
+			RefID:  "B",
+			Change: Change{To: "up"},
+		},
+	}, diff.ChangedQueries)
+	assert.Equal(t, []string{"http_request_duration_seconds_sum"}, diff.AddedMetrics)
+	assert.Equal(t, []string{"node_load1"}, diff.RemovedMetrics)
+	assert.Equal(t, []VariableChange{
+		{Name: "job", Kind: "changed", Fields: map[string]Change{"multi": {From: "false", To: "true"}}},
+		{Name: "instance", Kind: "added"},
+		{Name: "old", Kind: "removed"},
+	}, diff.ChangedVariables)
+	assert.Equal(t, []ThresholdChange{{
+		Panel:  PanelRef{ID: 2, Title: "Errors", Type: "stat"},
+		Change: Change{From: "green, red above 80", To: "green, red above 50"},
+	}}, diff.ChangedThresholds)
+	assert.False(t, diff.IsEmpty())
+
+	t.Run("layout only changes should produce an empty diff", func(t *testing.T) {
+		moved, err := BuildDocumentationFromBytes("moved.json", []byte(diffOldDashboard), Options{})
+		assert.NoError(t, err)
+		assert.True(t, DiffDocumentation(from, moved).IsEmpty())
+	})
+
+	t.Run("panels with a new ID should be matched by title", func(t *testing.T) {
+		renumbered := *from
+		renumbered.Rows = []RowDoc{{Panels: []PanelDoc{from.Panels()[0]}}}
+		renumbered.Rows[0].Panels[0].ID = 42
+		diff := DiffDocumentation(&DashboardDoc{Rows: []RowDoc{{Panels: from.Panels()[:1]}}}, &renumbered)
+		assert.Empty(t, diff.AddedPanels)
+		assert.Empty(t, diff.RemovedPanels)
+	})
+}
+
+func TestRenderDiff(t *testing.T) {
+	from, err := BuildDocumentationFromBytes("old.json", []byte(diffOldDashboard), Options{})
+	assert.NoError(t, err)
+	to, err := BuildDocumentationFromBytes("new.json", []byte(diffNewDashboard), Options{})
+	assert.NoError(t, err)
+	diff := DiffDocumentation(from, to)
+
+	t.Run("markdown should report every kind of change", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, RenderDiff(&buf, diff, FormatMarkdown))
+		output := buf.String()
+		for _, expected := range []string{
+			"## Dashboard changes: API\n\nComparing `old.json` with `new.json`.\n",
+			"| Added | Slow endpoints | Table |\n",
+			"| Removed | Saturation | Gauge |\n",
+			"| Renamed | Requests → Request rate | |\n",
+			"**Request rate**, query A changed:\n\n```diff\n- sum(rate(http_requests_total[5m]))\n+ sum by (code) (rate(http_requests_total[5m]))\n```\n",
+			"**Request rate**, query B added:\n\n```diff\n+ up\n```\n",
+			"- Added `http_request_duration_seconds_sum`\n- Removed `node_load1`\n",
+			"| `$job` | changed | multi | false | true |\n",
+			"| `$instance` | added |  |  |  |\n",
+			"| Errors | green, red above 80 | green, red above 50 |\n",
+		} {
+			assert.Contains(t, output, expected)
+		}
+	})
+
+	t.Run("json should serialize the diff", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, RenderDiff(&buf, diff, FormatJSON))
+		var decoded DashboardDiff
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+		assert.Equal(t, *diff, decoded)
+	})
+
+	t.Run("empty diff should say so", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, RenderDiff(&buf, DiffDocumentation(from, from), FormatMarkdown))
+		assert.Contains(t, buf.String(), "No semantic changes.")
+		assert.NotContains(t, buf.String(), "###")
+	})
+
+	t.Run("unsupported format should return error", func(t *testing.T) {
+		assert.ErrorContains(t, RenderDiff(&bytes.Buffer{}, diff, FormatYAML), "unsupported diff format: yaml")
+	})
+}
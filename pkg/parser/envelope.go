@@ -0,0 +1,139 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// dashboardFormat is a shape a dashboard file can have.
+type dashboardFormat int
+
+const (
+	// formatModel is a dashboard JSON model, as exported from the UI
+	formatModel dashboardFormat = iota
+	// formatEnvelope is a dashboard saved from the Grafana HTTP API, wrapped
+	// as {"dashboard": {...}, "meta": {...}}
+	formatEnvelope
+	// formatResource is a dashboard resource of the Kubernetes-style
+	// dashboard API whose spec is a schema v1 dashboard model
+	formatResource
+	// formatV2 is a schema v2 dashboard resource, with a map of elements and
+	// a layout tree in its spec
+	formatV2
+)
+
+const (
+	// envelopePointer is the JSON pointer of the dashboard model in a
+	// dashboard saved from the Grafana HTTP API
+	envelopePointer = "/dashboard"
+	// resourcePointer is the JSON pointer of the dashboard model in a
+	// dashboard resource
+	resourcePointer = "/spec"
+)
+
+// unmarshalDashboard decodes a dashboard file of any supported format into a
+// dashboard. Dashboards saved from the Grafana HTTP API, wrapped as
+// {"dashboard": {...}, "meta": {...}}, and dashboard resources are unwrapped
+// and schema v2 dashboards are converted automatically. Dashboards exported
+// with "Export for sharing externally" need no unwrapping: their __inputs and
+// __requires sections are decoded with the model. Older schema v1 dashboards
+// are migrated to the current schema, see Dashboard.migrate.
+//
+// Parameters:
+//   - bs: the content of the dashboard file
+//
+// Returns the dashboard, the JSON pointer of the dashboard model in the file,
+// which is empty unless the model was unwrapped, and an error if the content
+// is not a valid dashboard.
+func unmarshalDashboard(bs []byte) (Dashboard, string, error) {
+	var dash Dashboard
+	model, root := json.RawMessage(bs), ""
+	switch detectFormat(bs) {
+	case formatEnvelope:
+		var envelope struct {
+			Dashboard Dashboard `json:"dashboard"`
+		}
+		if err := json.Unmarshal(bs, &envelope); err != nil {
+			return dash, "", err
+		}
+		dash, model, root = envelope.Dashboard, rawField(bs, "dashboard"), envelopePointer
+	case formatResource:
+		var resource struct {
+			Metadata resourceMetadata `json:"metadata"`
+			Spec     Dashboard        `json:"spec"`
+		}
+		if err := json.Unmarshal(bs, &resource); err != nil {
+			return dash, "", err
+		}
+		if resource.Spec.UID == "" {
+			resource.Spec.UID = resource.Metadata.Name
+		}
+		dash, model, root = resource.Spec, rawField(bs, "spec"), resourcePointer
+	case formatV2:
+		var v2 dashboardV2
+		if err := json.Unmarshal(bs, &v2); err != nil {
+			return dash, "", err
+		}
+		return v2.toDashboard(), "", nil
+	default:
+		if err := json.Unmarshal(bs, &dash); err != nil {
+			return dash, "", err
+		}
+	}
+	dash.migrate(model)
+	return dash, root, nil
+}
+
+// rawField returns the raw JSON of a top level field of a JSON object.
+func rawField(bs []byte, key string) json.RawMessage {
+	var fields map[string]json.RawMessage
+	_ = json.Unmarshal(bs, &fields)
+	return fields[key]
+}
+
+// resourceMetadata is the metadata of a dashboard resource.
+type resourceMetadata struct {
+	// Name is the dashboard UID
+	Name string `json:"name"`
+}
+
+// detectFormat returns the format of a dashboard file from its top level
+// keys. Files that are not JSON objects are reported as dashboard models, so
+// that decoding them returns the usual error.
+func detectFormat(bs []byte) dashboardFormat {
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(bs, &top); err != nil {
+		return formatModel
+	}
+	for _, key := range []string{"panels", "rows", "title", "uid"} {
+		if _, ok := top[key]; ok {
+			return formatModel
+		}
+	}
+	if dashboard, ok := top["dashboard"]; ok && isObject(dashboard) {
+		return formatEnvelope
+	}
+
+	spec, ok := top["spec"]
+	if !ok || !isObject(spec) {
+		return formatModel
+	}
+	var resource struct {
+		APIVersion string `json:"apiVersion"`
+	}
+	var fields map[string]json.RawMessage
+	_ = json.Unmarshal(bs, &resource)
+	_ = json.Unmarshal(spec, &fields)
+	_, hasElements := fields["elements"]
+	_, hasLayout := fields["layout"]
+	if hasElements || hasLayout || strings.HasPrefix(resource.APIVersion, "dashboard.grafana.app/v2") {
+		return formatV2
+	}
+	return formatResource
+}
+
+// isObject reports whether a raw JSON value is an object.
+func isObject(raw json.RawMessage) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{"))
+}
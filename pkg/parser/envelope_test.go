@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected dashboardFormat
+	}{
+		{name: "api response should be an envelope", content: `{"dashboard": {"title": "A"}, "meta": {}}`, expected: formatEnvelope},
+		{name: "dashboard model should be a model", content: `{"title": "A", "panels": []}`, expected: formatModel},
+		{name: "model with a dashboard key should be a model", content: `{"title": "A", "dashboard": {}}`, expected: formatModel},
+		{name: "non object dashboard key should be a model", content: `{"dashboard": "A"}`, expected: formatModel},
+		{name: "invalid json should be a model", content: `{"dashboard": `, expected: formatModel},
+		{name: "v1 resource should be a resource", content: `{"apiVersion": "dashboard.grafana.app/v1beta1", "kind": "Dashboard", "spec": {"title": "A", "panels": []}}`, expected: formatResource},
+		{name: "v2 resource should be schema v2", content: `{"apiVersion": "dashboard.grafana.app/v2beta1", "kind": "Dashboard", "spec": {"title": "A"}}`, expected: formatV2},
+		{name: "spec with elements should be schema v2", content: `{"spec": {"title": "A", "elements": {}, "layout": {}}}`, expected: formatV2},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, detectFormat([]byte(tc.content)))
+		})
+	}
+}
+
+func TestBuildDocumentationFromEnvelope(t *testing.T) {
+	doc, err := BuildDocumentation("testdata/api_envelope_dashboard.json", Options{})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "api-envelope", doc.UID)
+	assert.Equal(t, "API Envelope Dashboard", doc.Title)
+	assert.Equal(t, "Dashboard saved from the Grafana HTTP API", doc.Description)
+	assert.Len(t, doc.Panels(), 2)
+	assert.Equal(t, []string{"http_requests_total"}, doc.Panels()[0].Metrics)
+
+	assert.Len(t, doc.Diagnostics, 3)
+	assert.Equal(t, "/dashboard/panels/0", doc.Diagnostics[0].Pointer)
+	assert.Equal(t, 8, doc.Diagnostics[0].Line, "diagnostics should be located in the envelope")
+	assert.Equal(t, "/dashboard/panels/1/targets/0/expr", doc.Diagnostics[2].Pointer)
+	assert.Equal(t, 25, doc.Diagnostics[2].Line)
+}
+
+func TestBuildDocumentationFromResource(t *testing.T) {
+	bs := []byte(`{
+  "apiVersion": "dashboard.grafana.app/v1beta1",
+  "kind": "Dashboard",
+  "metadata": { "name": "resource" },
+  "spec": {
+    "title": "Resource",
+    "panels": [{ "id": 1, "type": "stat", "title": "Up", "targets": [{ "expr": "sum(up" }] }]
+  }
+}`)
+	doc, err := BuildDocumentationFromBytes("resource.json", bs, Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, "resource", doc.UID, "the uid should default to the resource name")
+	assert.Equal(t, "Resource", doc.Title)
+	assert.Equal(t, "/spec/panels/0/targets/0/expr", doc.Diagnostics[1].Pointer)
+	assert.Equal(t, 7, doc.Diagnostics[1].Line)
+}
+
+func TestBuildDocumentationFromSharedExport(t *testing.T) {
+	doc, err := BuildDocumentation("testdata/shared_dashboard.json", Options{Strict: true})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []DashboardInput{
+		{Name: "DS_PROMETHEUS", Label: "Prometheus", Type: "datasource", PluginID: "prometheus", PluginName: "Prometheus"},
+		{Name: "DS_LOKI", Label: "Loki", Description: "Logs of the API", Type: "datasource", PluginID: "loki", PluginName: "Loki"},
+		{Name: "VAR_JOB", Label: "Job", Description: "Scrape job of the API", Type: "constant", Value: "api"},
+	}, doc.Inputs)
+	assert.Len(t, doc.Requires, 5)
+	assert.Equal(t, DashboardRequirement{Type: "grafana", ID: "grafana", Name: "Grafana", Version: "10.4.0"}, doc.Requires[0])
+
+	panels := doc.Panels()
+	assert.Equal(t, []string{"http_requests_total"}, panels[0].Metrics)
+	assert.Equal(t, "loki", panels[1].Targets[0].DatasourceType, "datasource placeholders should resolve to the input plugin")
+	assert.Len(t, panels[1].LogQueries, 1)
+	assert.Equal(t, []string{"up"}, doc.Variables[0].Metrics)
+	assert.Len(t, doc.Variables, 1, "inputs should not be documented as variables")
+
+	var buf bytes.Buffer
+	assert.NoError(t, Render(&buf, doc, FormatMarkdown))
+	output := buf.String()
+	assert.Contains(t, output, "Dashboard exported for sharing externally\n\n## Prerequisites\n\nInputs to provide when importing the dashboard:")
+	assert.Contains(t, output, "| `${DS_LOKI}` | Loki | datasource | Loki (loki) |  | Logs of the API |")
+	assert.Contains(t, output, "| `${VAR_JOB}` | Job | constant |  | `api` | Scrape job of the API |")
+	assert.Contains(t, output, "| grafana | grafana | Grafana | 10.4.0 |")
+	assert.Contains(t, output, "| panel | timeseries | Time series |  |")
+}
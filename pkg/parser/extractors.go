@@ -0,0 +1,314 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// QueryEntities describes the entities read by a single query target.
+type QueryEntities struct {
+	// Metrics contains metric names, e.g. from PromQL expressions
+	Metrics []string `json:"metrics,omitempty"`
+	// MetricUsages describes each metric selection with its label matchers
+	// and grouping labels
+	MetricUsages []MetricUsage `json:"metricUsages,omitempty"`
+	// LogStreams contains log stream selectors, e.g. from LogQL expressions
+	LogStreams []string `json:"logStreams,omitempty"`
+	// Tables contains the tables or measurements read by SQL-like queries
+	Tables []string `json:"tables,omitempty"`
+	// Indices contains the indices read by search queries
+	Indices []string `json:"indices,omitempty"`
+	// Queries contains raw queries documented verbatim because their
+	// entities could not be extracted
+	Queries []string `json:"queries,omitempty"`
+	// LogQueries contains the description of each LogQL query
+	LogQueries []LogQuery `json:"logQueries,omitempty"`
+}
+
+// merge appends the entities of other to e.
+func (e *QueryEntities) merge(other QueryEntities) {
+	e.Metrics = append(e.Metrics, other.Metrics...)
+	e.MetricUsages = append(e.MetricUsages, other.MetricUsages...)
+	e.LogStreams = append(e.LogStreams, other.LogStreams...)
+	e.Tables = append(e.Tables, other.Tables...)
+	e.Indices = append(e.Indices, other.Indices...)
+	e.Queries = append(e.Queries, other.Queries...)
+	e.LogQueries = append(e.LogQueries, other.LogQueries...)
+}
+
+// QueryError is returned by extractors for a query that cannot be parsed.
+type QueryError struct {
+	// Field is the target field holding the query, e.g. "expr"
+	Field string
+	// Err is the parse error
+	Err error
+}
+
+// Error returns the parse error message.
+func (e *QueryError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the parse error.
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// Extractor extracts the queried entities from a raw query target. The
+// interpolator resolves Grafana variables referenced by the query.
+type Extractor interface {
+	Extract(target json.RawMessage, interpolator *Interpolator) (QueryEntities, error)
+}
+
+// ExtractorFunc is an adapter allowing ordinary functions to be used as Extractors.
+type ExtractorFunc func(target json.RawMessage, interpolator *Interpolator) (QueryEntities, error)
+
+// Extract calls f(target, interpolator).
+func (f ExtractorFunc) Extract(target json.RawMessage, interpolator *Interpolator) (QueryEntities, error) {
+	return f(target, interpolator)
+}
+
+var (
+	// extractorsMu guards extractors
+	extractorsMu sync.RWMutex
+	// extractors maps datasource plugin types to the extractor for their queries
+	extractors = map[string]Extractor{
+		"prometheus":                    ExtractorFunc(extractPromQLTarget),
+		"loki":                          ExtractorFunc(extractLogQLTarget),
+		"elasticsearch":                 ExtractorFunc(extractElasticsearchTarget),
+		"mysql":                         ExtractorFunc(extractSQLTarget),
+		"postgres":                      ExtractorFunc(extractSQLTarget),
+		"grafana-postgresql-datasource": ExtractorFunc(extractSQLTarget),
+		"mssql":                         ExtractorFunc(extractSQLTarget),
+		"influxdb":                      ExtractorFunc(extractInfluxDBTarget),
+		"testdata":                      ExtractorFunc(extractTestDataTarget),
+		"grafana-testdata-datasource":   ExtractorFunc(extractTestDataTarget),
+	}
+	// rawQueryExtractor is used for datasource types without a registered extractor
+	rawQueryExtractor Extractor = ExtractorFunc(extractRawQuery)
+)
+
+// defaultDatasourceType is assumed for targets whose datasource type cannot be
+// determined, matching the tool's historical PromQL-only behaviour.
+const defaultDatasourceType = "prometheus"
+
+// RegisterExtractor registers the extractor used for the queries of the given
+// datasource plugin type, replacing any extractor previously registered for it.
+//
+// Parameters:
+//   - datasourceType: the datasource plugin type (e.g. "prometheus", "loki")
+//   - extractor: the extractor handling queries for that datasource type
+func RegisterExtractor(datasourceType string, extractor Extractor) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+	extractors[datasourceType] = extractor
+}
+
+// GetExtractor returns the extractor registered for a datasource plugin type.
+// Unknown datasource types get an extractor that lists the raw query instead
+// of failing.
+func GetExtractor(datasourceType string) Extractor {
+	extractorsMu.RLock()
+	defer extractorsMu.RUnlock()
+	if extractor, ok := extractors[datasourceType]; ok {
+		return extractor
+	}
+	return rawQueryExtractor
+}
+
+// hasExtractor reports whether an extractor is registered for a datasource plugin type.
+func hasExtractor(datasourceType string) bool {
+	extractorsMu.RLock()
+	defer extractorsMu.RUnlock()
+	_, ok := extractors[datasourceType]
+	return ok
+}
+
+// resolveDatasourceType determines the datasource plugin type queried by a
+// target. The target's own datasource takes precedence over the panel's,
+// except for the special "datasource" type used by mixed panels. Datasource
+// references pointing at a datasource variable are resolved through the
+// variable's plugin type.
+func resolveDatasourceType(target Target, panel Panel, interpolator *Interpolator) string {
+	candidates := []*Datasource{&target.Datasource, panel.Datasource}
+	for _, ds := range candidates {
+		if ds == nil {
+			continue
+		}
+		if ds.Type != "" && !isVariableReference(ds.Type) {
+			if *ds == mixedDatasource {
+				continue
+			}
+			return ds.Type
+		}
+		ref := ds.UID
+		if isVariableReference(ds.Type) {
+			ref = ds.Type
+		}
+		if name, _, ok := matchVariable(ref); ok {
+			if v, ok := interpolator.variables[name]; ok && v.Type == "datasource" && v.Query != "" {
+				return string(v.Query)
+			}
+		}
+	}
+	return defaultDatasourceType
+}
+
+// isVariableReference reports whether s is made of a single Grafana variable reference.
+func isVariableReference(s string) bool {
+	_, n, ok := matchVariable(s)
+	return ok && n == len(s)
+}
+
+// extractPromQLTarget extracts the metric names read by a Prometheus query target.
+func extractPromQLTarget(target json.RawMessage, interpolator *Interpolator) (QueryEntities, error) {
+	var t struct {
+		Expr string `json:"expr"`
+	}
+	if err := json.Unmarshal(target, &t); err != nil {
+		return QueryEntities{}, fmt.Errorf("error unmarshalling prometheus target: %w", err)
+	}
+	if strings.TrimSpace(t.Expr) == "" {
+		return QueryEntities{}, nil
+	}
+	usages, err := extractMetricUsagesFromExpression(interpolator.Interpolate(t.Expr))
+	if err != nil {
+		return QueryEntities{}, &QueryError{Field: "expr", Err: err}
+	}
+	entities := QueryEntities{MetricUsages: usages}
+	for _, usage := range usages {
+		switch {
+		case usage.Name != "":
+			entities.Metrics = append(entities.Metrics, usage.Name)
+		case usage.Pattern != "":
+			entities.Metrics = append(entities.Metrics, usage.Pattern)
+		}
+	}
+	return entities, nil
+}
+
+// sqlTables matches the table referenced after FROM, JOIN, INTO and UPDATE clauses.
+var sqlTables = regexp.MustCompile("(?i)\\b(?:from|join|into|update)\\s+([\\w.$\\x60\"\\[\\]{}-]+)")
+
+// extractTables returns the tables referenced by a SQL-like query, ignoring
+// subqueries and Grafana macros.
+func extractTables(query string) []string {
+	var tables []string
+	for _, m := range sqlTables.FindAllStringSubmatch(query, -1) {
+		table := strings.Trim(m[1], "`\"[]")
+		if table == "" || strings.HasPrefix(table, "$__") {
+			continue
+		}
+		tables = append(tables, table)
+	}
+	return tables
+}
+
+// extractSQLTarget extracts the tables read by a SQL datasource query target.
+func extractSQLTarget(target json.RawMessage, _ *Interpolator) (QueryEntities, error) {
+	var t struct {
+		RawSQL string `json:"rawSql"`
+		Table  string `json:"table"`
+	}
+	if err := json.Unmarshal(target, &t); err != nil {
+		return QueryEntities{}, fmt.Errorf("error unmarshalling sql target: %w", err)
+	}
+	tables := extractTables(t.RawSQL)
+	if len(tables) == 0 && t.Table != "" {
+		tables = []string{t.Table}
+	}
+	if len(tables) == 0 && strings.TrimSpace(t.RawSQL) != "" {
+		return QueryEntities{Queries: []string{t.RawSQL}}, nil
+	}
+	return QueryEntities{Tables: tables}, nil
+}
+
+// extractInfluxDBTarget extracts the measurements read by an InfluxDB query
+// target, either from the raw InfluxQL query or the query builder's measurement.
+func extractInfluxDBTarget(target json.RawMessage, _ *Interpolator) (QueryEntities, error) {
+	var t struct {
+		Query       string `json:"query"`
+		RawQuery    bool   `json:"rawQuery"`
+		Measurement string `json:"measurement"`
+	}
+	if err := json.Unmarshal(target, &t); err != nil {
+		return QueryEntities{}, fmt.Errorf("error unmarshalling influxdb target: %w", err)
+	}
+	if t.Measurement != "" && !t.RawQuery {
+		return QueryEntities{Tables: []string{t.Measurement}}, nil
+	}
+	if tables := extractTables(t.Query); len(tables) > 0 {
+		return QueryEntities{Tables: tables}, nil
+	}
+	if strings.TrimSpace(t.Query) != "" {
+		return QueryEntities{Queries: []string{t.Query}}, nil
+	}
+	return QueryEntities{}, nil
+}
+
+// extractElasticsearchTarget extracts the index and the Lucene query of an
+// Elasticsearch query target. The index is usually configured on the
+// datasource and is only reported when the target overrides it.
+func extractElasticsearchTarget(target json.RawMessage, _ *Interpolator) (QueryEntities, error) {
+	var t struct {
+		Query string `json:"query"`
+		Index string `json:"index"`
+	}
+	if err := json.Unmarshal(target, &t); err != nil {
+		return QueryEntities{}, fmt.Errorf("error unmarshalling elasticsearch target: %w", err)
+	}
+	var entities QueryEntities
+	if t.Index != "" {
+		entities.Indices = []string{t.Index}
+	}
+	if query := strings.TrimSpace(t.Query); query != "" && query != "*" {
+		entities.Queries = []string{query}
+	}
+	return entities, nil
+}
+
+// extractTestDataTarget documents the scenario generating a TestData query target.
+func extractTestDataTarget(target json.RawMessage, _ *Interpolator) (QueryEntities, error) {
+	var t struct {
+		ScenarioID string `json:"scenarioId"`
+	}
+	if err := json.Unmarshal(target, &t); err != nil {
+		return QueryEntities{}, fmt.Errorf("error unmarshalling testdata target: %w", err)
+	}
+	if t.ScenarioID == "" {
+		return QueryEntities{}, nil
+	}
+	return QueryEntities{Queries: []string{"scenario: " + t.ScenarioID}}, nil
+}
+
+// rawQueryFields lists the target fields commonly holding the query text,
+// in order of preference.
+var rawQueryFields = []string{"expr", "query", "rawSql", "rawQuery", "target", "queryText", "expression", "sql"}
+
+// extractRawQuery lists the raw query of a target whose datasource type has
+// no registered extractor.
+func extractRawQuery(target json.RawMessage, _ *Interpolator) (QueryEntities, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(target, &fields); err != nil {
+		return QueryEntities{}, fmt.Errorf("error unmarshalling target: %w", err)
+	}
+	if query := rawQuery(fields); query != "" {
+		return QueryEntities{Queries: []string{query}}, nil
+	}
+	return QueryEntities{}, nil
+}
+
+// rawQuery returns the query text held by the first non-empty raw query field
+// of a target, or an empty string if there is none.
+func rawQuery(fields map[string]json.RawMessage) string {
+	for _, field := range rawQueryFields {
+		var query string
+		if err := json.Unmarshal(fields[field], &query); err == nil && strings.TrimSpace(query) != "" {
+			return query
+		}
+	}
+	return ""
+}
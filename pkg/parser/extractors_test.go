@@ -0,0 +1,167 @@
+package parser
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveDatasourceType(t *testing.T) {
+	interpolator := NewInterpolator([]Variable{
+		{Name: "ds", Type: "datasource", Query: "loki"},
+	})
+
+	tests := []struct {
+		name     string
+		target   Target
+		panel    Panel
+		expected string
+	}{
+		{
+			name:     "target datasource type should take precedence",
+			target:   Target{Datasource: Datasource{Type: "mysql"}},
+			panel:    Panel{Datasource: &Datasource{Type: "prometheus"}},
+			expected: "mysql",
+		}, {
+			name:     "panel datasource type should be used when the target has none",
+			target:   Target{},
+			panel:    Panel{Datasource: &Datasource{Type: "elasticsearch"}},
+			expected: "elasticsearch",
+		}, {
+			name:     "mixed panel datasource should be ignored",
+			target:   Target{},
+			panel:    Panel{Datasource: &Datasource{Type: "datasource", UID: "-- Mixed --"}},
+			expected: "prometheus",
+		}, {
+			name:     "datasource variable reference should resolve to the variable plugin type",
+			target:   Target{},
+			panel:    Panel{Datasource: &Datasource{UID: "${ds}"}},
+			expected: "loki",
+		}, {
+			name:     "unknown datasource should default to prometheus",
+			target:   Target{Datasource: Datasource{UID: "Some Name"}},
+			panel:    Panel{},
+			expected: "prometheus",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, resolveDatasourceType(tc.target, tc.panel, interpolator))
+		})
+	}
+}
+
+func TestExtractors(t *testing.T) {
+	tests := []struct {
+		name           string
+		datasourceType string
+		target         string
+		expected       QueryEntities
+		expectError    bool
+	}{
+		{
+			name:           "prometheus target should return metrics",
+			datasourceType: "prometheus",
+			target:         `{"expr": "sum by (code) (rate(http_requests_total{job=\"api\"}[$__rate_interval]))"}`,
+			expected: QueryEntities{
+				Metrics: []string{"http_requests_total"},
+				MetricUsages: []MetricUsage{
+					{Name: "http_requests_total", Matchers: []string{`job="api"`}, Groupings: []string{"by (code)"}},
+				},
+			},
+		}, {
+			name:           "prometheus target with empty expression should return nothing",
+			datasourceType: "prometheus",
+			target:         `{"expr": ""}`,
+			expected:       QueryEntities{},
+		}, {
+			name:           "prometheus target with bad expression should return error",
+			datasourceType: "prometheus",
+			target:         `{"expr": "sum(up"}`,
+			expectError:    true,
+		}, {
+			name:           "loki target should return log streams",
+			datasourceType: "loki",
+			target:         `{"expr": "{app=\"api\"} |= \"error\""}`,
+			expected: QueryEntities{
+				LogStreams: []string{`{app="api"}`},
+				LogQueries: []LogQuery{{
+					Streams:     []string{`{app="api"}`},
+					Matchers:    []string{`app="api"`},
+					LineFilters: []string{`|= "error"`},
+				}},
+			},
+		}, {
+			name:           "loki target with bad expression should return error",
+			datasourceType: "loki",
+			target:         `{"expr": "{app=\"api\""}`,
+			expectError:    true,
+		}, {
+			name:           "sql target should return tables",
+			datasourceType: "postgres",
+			target:         `{"rawSql": "SELECT * FROM public.orders o JOIN \"customers\" c ON o.cid = c.id WHERE $__timeFilter(o.created_at)"}`,
+			expected:       QueryEntities{Tables: []string{"public.orders", "customers"}},
+		}, {
+			name:           "sql target without tables should return the raw query",
+			datasourceType: "mysql",
+			target:         `{"rawSql": "SELECT 1"}`,
+			expected:       QueryEntities{Queries: []string{"SELECT 1"}},
+		}, {
+			name:           "influxdb builder target should return the measurement",
+			datasourceType: "influxdb",
+			target:         `{"measurement": "cpu", "rawQuery": false}`,
+			expected:       QueryEntities{Tables: []string{"cpu"}},
+		}, {
+			name:           "influxdb raw target should return the measurements",
+			datasourceType: "influxdb",
+			target:         `{"query": "SELECT mean(\"value\") FROM \"disk\" WHERE $timeFilter", "rawQuery": true}`,
+			expected:       QueryEntities{Tables: []string{"disk"}},
+		}, {
+			name:           "elasticsearch target should return the index and query",
+			datasourceType: "elasticsearch",
+			target:         `{"query": "level:error", "index": "logs-*"}`,
+			expected:       QueryEntities{Indices: []string{"logs-*"}, Queries: []string{"level:error"}},
+		}, {
+			name:           "testdata target should return the scenario",
+			datasourceType: "grafana-testdata-datasource",
+			target:         `{"scenarioId": "random_walk"}`,
+			expected:       QueryEntities{Queries: []string{"scenario: random_walk"}},
+		}, {
+			name:           "unknown datasource should fall back to the raw query",
+			datasourceType: "graphite",
+			target:         `{"refId": "A", "target": "aliasByNode(servers.*.cpu, 1)"}`,
+			expected:       QueryEntities{Queries: []string{"aliasByNode(servers.*.cpu, 1)"}},
+		}, {
+			name:           "unknown datasource without query should return nothing",
+			datasourceType: "unknown",
+			target:         `{"refId": "A"}`,
+			expected:       QueryEntities{},
+		},
+	}
+
+	interpolator := NewInterpolator(nil)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			entities, err := GetExtractor(tc.datasourceType).Extract(json.RawMessage(tc.target), interpolator)
+			if tc.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, entities)
+			}
+		})
+	}
+}
+
+func TestRegisterExtractor(t *testing.T) {
+	custom := ExtractorFunc(func(target json.RawMessage, _ *Interpolator) (QueryEntities, error) {
+		return QueryEntities{Tables: []string{"custom"}}, nil
+	})
+	RegisterExtractor("custom-datasource", custom)
+
+	entities, err := GetExtractor("custom-datasource").Extract(json.RawMessage(`{}`), NewInterpolator(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, QueryEntities{Tables: []string{"custom"}}, entities)
+}
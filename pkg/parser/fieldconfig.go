@@ -0,0 +1,468 @@
+package parser
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// unitNames maps the IDs of the most common Grafana units to a human readable
+// name. Units missing from the map are documented by their ID.
+var unitNames = map[string]string{
+	"none":            "",
+	"short":           "",
+	"percent":         "percent (0-100)",
+	"percentunit":     "percent (0.0-1.0)",
+	"ns":              "nanoseconds",
+	"µs":              "microseconds",
+	"us":              "microseconds",
+	"ms":              "milliseconds",
+	"s":               "seconds",
+	"m":               "minutes",
+	"h":               "hours",
+	"d":               "days",
+	"dtdurationms":    "duration (milliseconds)",
+	"dtdurations":     "duration (seconds)",
+	"dateTimeAsIso":   "date and time",
+	"dateTimeFromNow": "time from now",
+	"bits":            "bits",
+	"bytes":           "bytes",
+	"decbytes":        "bytes (SI)",
+	"kbytes":          "kibibytes",
+	"mbytes":          "mebibytes",
+	"gbytes":          "gibibytes",
+	"tbytes":          "tebibytes",
+	"bps":             "bits/sec",
+	"Bps":             "bytes/sec",
+	"binbps":          "bits/sec (IEC)",
+	"binBps":          "bytes/sec (IEC)",
+	"KBs":             "kilobytes/sec",
+	"MBs":             "megabytes/sec",
+	"GBs":             "gigabytes/sec",
+	"ops":             "operations/sec",
+	"reqps":           "requests/sec",
+	"rps":             "reads/sec",
+	"wps":             "writes/sec",
+	"iops":            "I/O operations/sec",
+	"cps":             "counts/sec",
+	"opm":             "operations/min",
+	"reqpm":           "requests/min",
+	"hertz":           "hertz",
+	"celsius":         "degrees Celsius",
+	"fahrenheit":      "degrees Fahrenheit",
+	"watt":            "watts",
+	"kwatt":           "kilowatts",
+	"volt":            "volts",
+	"amp":             "amperes",
+	"currencyUSD":     "US dollars",
+	"currencyEUR":     "euros",
+	"bool":            "true/false",
+	"bool_yes_no":     "yes/no",
+	"bool_on_off":     "on/off",
+}
+
+// colorSchemeNames maps the color modes of a field to a human readable name.
+var colorSchemeNames = map[string]string{
+	"thresholds":              "colored by thresholds",
+	"palette-classic-by-name": "classic palette by series name",
+	"continuous-GrYlRd":       "green-yellow-red gradient",
+	"continuous-RdYlGr":       "red-yellow-green gradient",
+	"continuous-BlYlRd":       "blue-yellow-red gradient",
+	"continuous-YlRd":         "yellow-red gradient",
+	"continuous-BlPu":         "blue-purple gradient",
+	"continuous-YlBl":         "yellow-blue gradient",
+	"continuous-blues":        "blue gradient",
+	"continuous-reds":         "red gradient",
+	"continuous-greens":       "green gradient",
+	"continuous-purples":      "purple gradient",
+}
+
+// defaultColorScheme is the color mode Grafana uses when a panel does not
+// set one; it says nothing about the values and is not documented.
+const defaultColorScheme = "palette-classic"
+
+// FieldColor represents the color scheme of the fields of a panel.
+type FieldColor struct {
+	// Mode is the color scheme, e.g. "thresholds", "fixed" or "palette-classic"
+	Mode string `json:"mode"`
+	// FixedColor is the color of the fixed and shades modes
+	FixedColor string `json:"fixedColor,omitempty"`
+}
+
+// String returns a human readable representation of the color scheme, e.g.
+// "colored by thresholds" or "fixed color red".
+func (c *FieldColor) String() string {
+	switch {
+	case c == nil || c.Mode == "" || c.Mode == defaultColorScheme:
+		return ""
+	case c.Mode == "fixed":
+		return "fixed color " + c.FixedColor
+	case c.Mode == "shades":
+		return "shades of " + c.FixedColor
+	}
+	if name, ok := colorSchemeNames[c.Mode]; ok {
+		return name
+	}
+	return "color scheme " + c.Mode
+}
+
+// FieldOverride represents an override of the field configuration of a panel,
+// applying properties to the fields matched by its matcher.
+type FieldOverride struct {
+	Matcher    FieldMatcher    `json:"matcher"`
+	Properties []FieldProperty `json:"properties"`
+}
+
+// FieldProperty represents a property of a field override, e.g. the "unit"
+// property with the value "percent".
+type FieldProperty struct {
+	ID    string          `json:"id"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Config returns the field configuration set by the override's properties.
+// Properties that are not field defaults, such as the options of a
+// visualization (custom.*), are ignored, as are properties with an invalid value.
+func (o *FieldOverride) Config() FieldDefaults {
+	var config FieldDefaults
+	for _, property := range o.Properties {
+		if strings.HasPrefix(property.ID, "custom.") || len(property.Value) == 0 {
+			continue
+		}
+		bs, err := json.Marshal(map[string]json.RawMessage{property.ID: property.Value})
+		if err != nil {
+			continue
+		}
+		_ = json.Unmarshal(bs, &config)
+	}
+	return config
+}
+
+// FieldMatcher represents the matcher selecting the fields of a field override.
+type FieldMatcher struct {
+	// ID is the matcher type, e.g. "byName", "byRegexp" or "byType"
+	ID string `json:"id"`
+	// Options is the matcher argument: a field name, a pattern, a type or,
+	// for some matchers, an object
+	Options json.RawMessage `json:"options"`
+}
+
+// String returns a human readable representation of the fields matched, e.g.
+// `field "errors"` or "fields matching /5../".
+func (m *FieldMatcher) String() string {
+	var option string
+	_ = json.Unmarshal(m.Options, &option)
+	switch m.ID {
+	case "byName":
+		return fmt.Sprintf("field %q", option)
+	case "byRegexp":
+		return fmt.Sprintf("fields matching /%s/", option)
+	case "byType":
+		return option + " fields"
+	case "byFrameRefID":
+		return "fields of query " + option
+	case "byNames":
+		var names struct {
+			Mode  string   `json:"mode"`
+			Names []string `json:"names"`
+		}
+		_ = json.Unmarshal(m.Options, &names)
+		if names.Mode == "exclude" {
+			return "fields other than " + quoteList(names.Names)
+		}
+		return "fields " + quoteList(names.Names)
+	case "byValue":
+		return "fields matching a value condition"
+	}
+	if option != "" {
+		return fmt.Sprintf("fields %s %q", m.ID, option)
+	}
+	return "fields " + m.ID
+}
+
+// ValueMappings is the list of value mappings of a field. It decodes both the
+// current format, in which a value mapping holds several values, and the
+// format of dashboards older than schema version 28, with one mapping per value.
+type ValueMappings []ValueMapping
+
+// ValueMapping represents a value mapping, which displays a text, a color or
+// both instead of the values it matches.
+type ValueMapping struct {
+	// Type is value, range, regex or special
+	Type string `json:"type"`
+	// Match is the value matched by a value mapping, the pattern of a regex
+	// mapping or the special value (null, nan, null+nan, true, false or
+	// empty) matched by a special mapping
+	Match string `json:"match,omitempty"`
+	// From is the start of the range matched by a range mapping
+	From *float64 `json:"from,omitempty"`
+	// To is the end of the range matched by a range mapping
+	To *float64 `json:"to,omitempty"`
+	// Text is the text displayed instead of the matched values
+	Text string `json:"text,omitempty"`
+	// Color is the color the matched values are displayed with
+	Color string `json:"color,omitempty"`
+}
+
+// valueMappingResult is the result of a value mapping in the current format.
+type valueMappingResult struct {
+	Text  string `json:"text"`
+	Color string `json:"color"`
+	Index int    `json:"index"`
+}
+
+// UnmarshalJSON decodes a list of value mappings in either format. Mappings
+// of an unknown type are skipped.
+func (v *ValueMappings) UnmarshalJSON(b []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	*v = nil
+	for _, item := range raw {
+		var mapping struct {
+			Type    json.RawMessage `json:"type"`
+			Options json.RawMessage `json:"options"`
+			// Value, From, To and Text are the fields of the older format
+			Value string `json:"value"`
+			From  string `json:"from"`
+			To    string `json:"to"`
+			Text  string `json:"text"`
+		}
+		if json.Unmarshal(item, &mapping) != nil {
+			continue
+		}
+		var typ string
+		if json.Unmarshal(mapping.Type, &typ) != nil {
+			// the older format numbers its types: 1 for values and 2 for ranges
+			switch strings.TrimSpace(string(mapping.Type)) {
+			case "1":
+				*v = append(*v, ValueMapping{Type: "value", Match: mapping.Value, Text: mapping.Text})
+			case "2":
+				*v = append(*v, ValueMapping{Type: "range", From: parseFloat(mapping.From), To: parseFloat(mapping.To), Text: mapping.Text})
+			}
+			continue
+		}
+		*v = append(*v, decodeValueMapping(typ, mapping.Options)...)
+	}
+	return nil
+}
+
+// decodeValueMapping decodes the options of a value mapping in the current
+// format. A mapping of type value holds several values, which are returned in
+// the order of their index.
+func decodeValueMapping(typ string, options json.RawMessage) []ValueMapping {
+	switch typ {
+	case "value":
+		var values map[string]valueMappingResult
+		if json.Unmarshal(options, &values) != nil {
+			return nil
+		}
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		slices.SortFunc(keys, func(a, b string) int {
+			return cmp.Or(cmp.Compare(values[a].Index, values[b].Index), cmp.Compare(a, b))
+		})
+		mappings := make([]ValueMapping, 0, len(keys))
+		for _, key := range keys {
+			mappings = append(mappings, ValueMapping{Type: typ, Match: key, Text: values[key].Text, Color: values[key].Color})
+		}
+		return mappings
+	case "range", "regex", "special":
+		var opts struct {
+			From    *float64           `json:"from"`
+			To      *float64           `json:"to"`
+			Pattern string             `json:"pattern"`
+			Match   string             `json:"match"`
+			Result  valueMappingResult `json:"result"`
+		}
+		if json.Unmarshal(options, &opts) != nil {
+			return nil
+		}
+		return []ValueMapping{{
+			Type:  typ,
+			Match: cmp.Or(opts.Pattern, opts.Match),
+			From:  opts.From,
+			To:    opts.To,
+			Text:  opts.Result.Text,
+			Color: opts.Result.Color,
+		}}
+	}
+	return nil
+}
+
+// String returns a human readable representation of the value mapping, e.g.
+// "0 → Down (red)" or "0 to 10 → Low".
+func (m ValueMapping) String() string {
+	var match string
+	switch m.Type {
+	case "range":
+		switch {
+		case m.From != nil && m.To != nil:
+			match = formatFloat(*m.From) + " to " + formatFloat(*m.To)
+		case m.From != nil:
+			match = formatFloat(*m.From) + " and above"
+		case m.To != nil:
+			match = "up to " + formatFloat(*m.To)
+		default:
+			match = "any value"
+		}
+	case "regex":
+		match = "/" + m.Match + "/"
+	case "special":
+		match = strings.ReplaceAll(m.Match, "+", " or ")
+	default:
+		match = m.Match
+	}
+
+	result := m.Text
+	switch {
+	case result == "":
+		result = m.Color
+	case m.Color != "":
+		result += " (" + m.Color + ")"
+	}
+	return match + " → " + result
+}
+
+// String returns a human readable representation of the field configuration
+// in the terms a reader of the dashboard needs, e.g. "seconds; red above 0.5".
+// Options left to their default are omitted.
+func (d *FieldDefaults) String() string {
+	var parts []string
+	if d.DisplayName != "" {
+		parts = append(parts, fmt.Sprintf("shown as %q", d.DisplayName))
+	}
+	if name := unitName(d.Unit); name != "" {
+		parts = append(parts, name)
+	}
+	if d.Decimals != nil {
+		parts = append(parts, fmt.Sprintf("%d decimal%s", *d.Decimals, plural(*d.Decimals)))
+	}
+	switch {
+	case d.Min != nil && d.Max != nil:
+		parts = append(parts, "range "+formatFloat(*d.Min)+" to "+formatFloat(*d.Max))
+	case d.Min != nil:
+		parts = append(parts, "min "+formatFloat(*d.Min))
+	case d.Max != nil:
+		parts = append(parts, "max "+formatFloat(*d.Max))
+	}
+	if thresholds := d.Thresholds.summary(); thresholds != "" {
+		parts = append(parts, thresholds)
+	}
+	if len(d.Mappings) > 0 {
+		mappings := make([]string, 0, len(d.Mappings))
+		for _, m := range d.Mappings {
+			mappings = append(mappings, m.String())
+		}
+		parts = append(parts, "mapped "+strings.Join(mappings, ", "))
+	}
+	if color := d.Color.String(); color != "" {
+		parts = append(parts, color)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// summary returns the thresholds in the terms of the values they flag, e.g.
+// "red above 0.5". Unlike String it leaves out what needs no attention: the
+// base color when it is green and the transparent steps, which are not
+// colored. A base color that is not green is documented as the color of the
+// values below the first threshold.
+func (t *Thresholds) summary() string {
+	if t == nil {
+		return ""
+	}
+	unit := ""
+	if t.Mode == "percentage" {
+		unit = "%"
+	}
+	var parts []string
+	for i, step := range t.Steps {
+		if step.Color == "transparent" || step.Color == "green" && i == 0 {
+			continue
+		}
+		if step.Value != nil {
+			parts = append(parts, fmt.Sprintf("%s above %s%s", step.Color, formatFloat(*step.Value), unit))
+			continue
+		}
+		if i+1 < len(t.Steps) && t.Steps[i+1].Value != nil {
+			parts = append(parts, fmt.Sprintf("%s below %s%s", step.Color, formatFloat(*t.Steps[i+1].Value), unit))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// unitName returns the human readable name of a unit. Custom units with a
+// prefix or suffix are documented by their symbol.
+func unitName(unit string) string {
+	if name, ok := unitNames[unit]; ok {
+		return name
+	}
+	for _, custom := range []string{"suffix:", "prefix:"} {
+		if symbol, ok := strings.CutPrefix(unit, custom); ok {
+			return fmt.Sprintf("%s %q", strings.TrimSuffix(custom, ":"), symbol)
+		}
+	}
+	return unit
+}
+
+// formatFloat formats a number with the fewest digits needed.
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// parseFloat parses a number written as a string, returning nil if the
+// string is not a number.
+func parseFloat(s string) *float64 {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return nil
+	}
+	return &f
+}
+
+// plural returns the plural suffix of a noun counted n times.
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
+
+// newFieldConfigDoc converts the field configuration of a panel into its
+// documentation model, describing the defaults and every override in human
+// readable terms.
+//
+// Parameters:
+//   - config: the field configuration of the panel
+//
+// Returns the documentation model, or nil if the panel's field configuration
+// documents nothing: its options are left to their default and its overrides,
+// if any, only set visualization options.
+func newFieldConfigDoc(config FieldConfig) *FieldConfigDoc {
+	doc := &FieldConfigDoc{
+		Summary:  config.Defaults.String(),
+		Defaults: config.Defaults,
+	}
+	for _, override := range config.Overrides {
+		properties := override.Config()
+		summary := properties.String()
+		if summary == "" {
+			continue
+		}
+		doc.Overrides = append(doc.Overrides, FieldOverrideDoc{
+			Matcher:    override.Matcher.String(),
+			Summary:    summary,
+			Properties: properties,
+		})
+	}
+	if doc.Summary == "" && len(doc.Overrides) == 0 {
+		return nil
+	}
+	return doc
+}
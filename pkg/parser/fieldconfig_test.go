@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFieldDefaultsString(t *testing.T) {
+	tests := []struct {
+		name     string
+		defaults string
+		expected string
+	}{
+		{
+			name:     "unit and thresholds should be described in human terms",
+			defaults: `{"unit": "s", "thresholds": {"mode": "absolute", "steps": [{"color": "green", "value": null}, {"color": "red", "value": 0.5}]}}`,
+			expected: "seconds; red above 0.5",
+		},
+		{
+			name:     "base color that is not green should be described below the first threshold",
+			defaults: `{"unit": "percentunit", "thresholds": {"mode": "absolute", "steps": [{"color": "red", "value": null}, {"color": "green", "value": 0.99}]}}`,
+			expected: "percent (0.0-1.0); red below 0.99, green above 0.99",
+		},
+		{
+			name:     "decimals, range and color scheme should be described",
+			defaults: `{"unit": "bytes", "decimals": 1, "min": 0, "max": 1024, "color": {"mode": "fixed", "fixedColor": "blue"}}`,
+			expected: "bytes; 1 decimal; range 0 to 1024; fixed color blue",
+		},
+		{
+			name:     "custom and unknown units should be documented by their symbol or ID",
+			defaults: `{"unit": "suffix:rpm"}`,
+			expected: `suffix "rpm"`,
+		},
+		{
+			name:     "default options should be omitted",
+			defaults: `{"unit": "short", "color": {"mode": "palette-classic"}, "thresholds": {"mode": "absolute", "steps": [{"color": "green", "value": null}]}}`,
+			expected: "",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var defaults FieldDefaults
+			assert.NoError(t, json.Unmarshal([]byte(tc.defaults), &defaults))
+			assert.Equal(t, tc.expected, defaults.String())
+		})
+	}
+}
+
+func TestValueMappingsUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		mappings string
+		expected []string
+	}{
+		{
+			name:     "value mappings should be listed in the order of their index",
+			mappings: `[{"type": "value", "options": {"1": {"text": "Up", "index": 1}, "0": {"text": "Down", "color": "red", "index": 0}}}]`,
+			expected: []string{"0 → Down (red)", "1 → Up"},
+		},
+		{
+			name:     "range, regex and special mappings should describe what they match",
+			mappings: `[{"type": "range", "options": {"from": 0, "to": 10, "result": {"text": "Low"}}}, {"type": "regex", "options": {"pattern": "5..", "result": {"color": "red"}}}, {"type": "special", "options": {"match": "null+nan", "result": {"text": "N/A"}}}]`,
+			expected: []string{"0 to 10 → Low", "/5../ → red", "null or nan → N/A"},
+		},
+		{
+			name:     "mappings of dashboards older than schema version 28 should be decoded",
+			mappings: `[{"id": 0, "type": 1, "value": "0", "text": "Down"}, {"id": 1, "type": 2, "from": "1", "to": "", "text": "Up"}]`,
+			expected: []string{"0 → Down", "1 and above → Up"},
+		},
+		{
+			name:     "mappings of unknown types should be skipped",
+			mappings: `[{"type": "unknown", "options": {}}]`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var mappings ValueMappings
+			assert.NoError(t, json.Unmarshal([]byte(tc.mappings), &mappings))
+			var described []string
+			for _, m := range mappings {
+				described = append(described, m.String())
+			}
+			assert.Equal(t, tc.expected, described)
+		})
+	}
+}
+
+func TestBuildDocumentationFieldConfig(t *testing.T) {
+	doc, err := BuildDocumentation("testdata/field_config_dashboard.json", Options{})
+	assert.NoError(t, err)
+
+	panels := doc.Panels()
+	latency := panels[0].FieldConfig
+	assert.Equal(t, "seconds; 2 decimals; orange above 0.3, red above 0.5", latency.Summary)
+	assert.Equal(t, []FieldOverrideDoc{
+		{
+			Matcher:    `field "errors"`,
+			Summary:    "percent (0.0-1.0); fixed color red",
+			Properties: FieldDefaults{Unit: "percentunit", Color: &FieldColor{Mode: "fixed", FixedColor: "red"}},
+		},
+		{
+			Matcher:    `fields other than "p50", "p90"`,
+			Summary:    `shown as "tail"`,
+			Properties: FieldDefaults{DisplayName: "tail"},
+		},
+	}, latency.Overrides, "overrides setting only visualization options should be skipped")
+	assert.Equal(t, "percent (0-100); range 0 to 100; red below 99.9%, green above 99.9%; colored by thresholds", panels[1].FieldConfig.Summary)
+	assert.Equal(t, "mapped 0 → Down (red), 1 → Up (green), 2 and above → Degraded, null or nan → No data (gray)", panels[2].FieldConfig.Summary)
+
+	var buf bytes.Buffer
+	assert.NoError(t, Render(&buf, doc, FormatMarkdown))
+	assert.Contains(t, buf.String(), "| p99 Latency | 99th percentile of the checkout latency | timeseries | seconds; 2 decimals; orange above 0.3, red above 0.5<br> field \"errors\": percent (0.0-1.0); fixed color red<br> fields other than \"p50\", \"p90\": shown as \"tail\"<br> |")
+}
+
+func TestMigrateLegacyFieldConfig(t *testing.T) {
+	doc, err := BuildDocumentation("testdata/legacy_dashboard.json", Options{})
+	assert.NoError(t, err)
+
+	panels := doc.Panels()
+	assert.Equal(t, "percent (0.0-1.0); min 0; red above 0.5", panels[0].FieldConfig.Summary, "graph panels should keep the unit and range of their left axis")
+	assert.Equal(t, "percent (0.0-1.0); 3 decimals; range 0 to 1; red below 0.99, orange above 0.99, green above 0.999; mapped null → No data", panels[1].FieldConfig.Summary, "singlestat panels should keep their unit, decimals, gauge range and value maps")
+	assert.Nil(t, panels[2].FieldConfig)
+}
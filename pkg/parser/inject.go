@@ -0,0 +1,186 @@
+package parser
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+)
+
+const (
+	// BeginMarker opens the section of a markdown file replaced by the
+	// generated documentation
+	BeginMarker = "<!-- BEGIN_AUTODOC -->"
+	// EndMarker closes the section of a markdown file replaced by the
+	// generated documentation
+	EndMarker = "<!-- END_AUTODOC -->"
+)
+
+// markerPattern matches the injection markers. A marker may name a dashboard
+// file, e.g. <!-- BEGIN_AUTODOC api.json -->, to place the documentation of
+// that dashboard in its own section.
+var markerPattern = regexp.MustCompile(`<!--\s*(BEGIN|END)_AUTODOC(?:\s+(\S+?))?\s*-->`)
+
+// markerSection is a section of a markdown file delimited by injection markers.
+type markerSection struct {
+	// name is the dashboard file named by the markers, empty for the shared section
+	name string
+	// start and end are the offsets of the section content, between the markers
+	start, end int
+}
+
+// InjectDocumentation renders the markdown documentation of dashboards and
+// injects it into a markdown file, leaving the content outside of the
+// injection markers untouched.
+//
+// The documentation of a dashboard replaces the content between the markers
+// naming its file, e.g. <!-- BEGIN_AUTODOC api.json --> and
+// <!-- END_AUTODOC api.json -->. The documentation of the other dashboards
+// replaces, ordered by file, the content between BeginMarker and EndMarker.
+// These markers are appended to the file, which is created if needed, when
+// they are missing. Sections of dashboards that are not documented by the run
+// are left untouched, so that several runs can share a file.
+//
+// Parameters:
+//   - docs: the documentation models of the dashboards
+//   - file: the markdown file the documentation is injected into
+//   - opts: options controlling how the documentation is rendered and written
+//
+// Returns an error if the file cannot be read or written, its markers are
+// unbalanced or the documentation cannot be rendered.
+func InjectDocumentation(docs []*DashboardDoc, file string, opts Options) error {
+	logger := slog.With(slog.String("inject-file", file))
+
+	content, err := os.ReadFile(file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Error("error reading the file to inject documentation into", slog.Any("error", err))
+		return fmt.Errorf("error reading %s: %w", file, err)
+	}
+
+	injected, err := Inject(content, docs, opts)
+	if err != nil {
+		logger.Error("error injecting documentation", slog.Any("error", err))
+		return fmt.Errorf("error injecting documentation into %s: %w", file, err)
+	}
+
+	if err := writeOutput(file, injected, opts); err != nil {
+		logger.Error("error writing the file to inject documentation into", slog.Any("error", err))
+		return fmt.Errorf("error writing %s: %w", file, err)
+	}
+	return nil
+}
+
+// Inject returns the content of a markdown file with the markdown
+// documentation of dashboards injected between its markers, as described by
+// InjectDocumentation.
+//
+// Parameters:
+//   - content: the content of the markdown file, empty for a new file
+//   - docs: the documentation models of the dashboards
+//   - opts: options controlling how the documentation is rendered
+//
+// Returns an error if the markers are unbalanced or the documentation cannot
+// be rendered.
+func Inject(content []byte, docs []*DashboardDoc, opts Options) ([]byte, error) {
+	sections, err := findMarkerSections(content)
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := slices.Clone(docs)
+	slices.SortFunc(sorted, func(a, b *DashboardDoc) int {
+		return cmp.Compare(a.File, b.File)
+	})
+	named := make(map[string]bool)
+	for _, section := range sections {
+		named[section.name] = true
+	}
+	rendered := make(map[string][]byte)
+	for _, doc := range sorted {
+		name := filepath.Base(doc.File)
+		if !named[name] {
+			name = ""
+		}
+		var buf bytes.Buffer
+		if opts.Template != nil {
+			err = RenderTemplate(&buf, doc, opts.Template)
+		} else {
+			err = Render(&buf, doc, FormatMarkdown)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if rendered[name] != nil {
+			rendered[name] = append(rendered[name], "\n\n"...)
+		}
+		rendered[name] = append(rendered[name], bytes.TrimSpace(buf.Bytes())...)
+	}
+
+	if rendered[""] != nil && !named[""] {
+		content = slices.Clip(content)
+		if len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+			content = append(content, '\n')
+		}
+		if len(content) > 0 {
+			content = append(content, '\n')
+		}
+		content = append(content, BeginMarker+"\n"+EndMarker+"\n"...)
+		if sections, err = findMarkerSections(content); err != nil {
+			return nil, err
+		}
+	}
+
+	var out bytes.Buffer
+	last := 0
+	for _, section := range sections {
+		if rendered[section.name] == nil {
+			continue
+		}
+		out.Write(content[last:section.start])
+		out.WriteString("\n")
+		out.Write(rendered[section.name])
+		out.WriteString("\n")
+		last = section.end
+	}
+	out.Write(content[last:])
+	return out.Bytes(), nil
+}
+
+// findMarkerSections returns the sections delimited by injection markers in
+// the order they appear in the content.
+func findMarkerSections(content []byte) ([]markerSection, error) {
+	var sections []markerSection
+	var open *markerSection
+	seen := make(map[string]bool)
+	for _, match := range markerPattern.FindAllSubmatchIndex(content, -1) {
+		kind := string(content[match[2]:match[3]])
+		var name string
+		if match[4] >= 0 {
+			name = string(content[match[4]:match[5]])
+		}
+		switch {
+		case kind == "BEGIN" && open != nil:
+			return nil, fmt.Errorf("BEGIN_AUTODOC marker %q opened before the end of section %q", name, open.name)
+		case kind == "BEGIN" && seen[name]:
+			return nil, fmt.Errorf("duplicate BEGIN_AUTODOC marker %q", name)
+		case kind == "BEGIN":
+			seen[name] = true
+			open = &markerSection{name: name, start: match[1]}
+		case open == nil || open.name != name:
+			return nil, fmt.Errorf("END_AUTODOC marker %q without a matching BEGIN_AUTODOC marker", name)
+		default:
+			open.end = match[0]
+			sections = append(sections, *open)
+			open = nil
+		}
+	}
+	if open != nil {
+		return nil, fmt.Errorf("BEGIN_AUTODOC marker %q without a matching END_AUTODOC marker", open.name)
+	}
+	return sections, nil
+}
@@ -0,0 +1,110 @@
+package parser
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInject(t *testing.T) {
+	rows, err := BuildDocumentation("testdata/rows_dashboard.json", Options{})
+	assert.NoError(t, err)
+	loki, err := BuildDocumentation("testdata/loki_dashboard.json", Options{})
+	assert.NoError(t, err)
+
+	render := func(doc *DashboardDoc) string {
+		var buf bytes.Buffer
+		assert.NoError(t, Render(&buf, doc, FormatMarkdown))
+		return strings.TrimSpace(buf.String())
+	}
+
+	tests := []struct {
+		name     string
+		content  string
+		docs     []*DashboardDoc
+		expected string
+		errorMsg string
+	}{
+		{
+			name:     "content between the markers should be replaced",
+			content:  "# Ownership\n\nTeam A\n\n<!-- BEGIN_AUTODOC -->\nstale\n<!-- END_AUTODOC -->\n\n## Runbook\n",
+			docs:     []*DashboardDoc{rows},
+			expected: "# Ownership\n\nTeam A\n\n<!-- BEGIN_AUTODOC -->\n" + render(rows) + "\n<!-- END_AUTODOC -->\n\n## Runbook\n",
+		}, {
+			name:     "missing markers should be appended",
+			content:  "# Ownership",
+			docs:     []*DashboardDoc{rows},
+			expected: "# Ownership\n\n<!-- BEGIN_AUTODOC -->\n" + render(rows) + "\n<!-- END_AUTODOC -->\n",
+		}, {
+			name:     "empty file should only contain the markers",
+			docs:     []*DashboardDoc{rows},
+			expected: "<!-- BEGIN_AUTODOC -->\n" + render(rows) + "\n<!-- END_AUTODOC -->\n",
+		}, {
+			name:     "several dashboards should be injected ordered by file",
+			content:  "<!-- BEGIN_AUTODOC --><!-- END_AUTODOC -->",
+			docs:     []*DashboardDoc{rows, loki},
+			expected: "<!-- BEGIN_AUTODOC -->\n" + render(loki) + "\n\n" + render(rows) + "\n<!-- END_AUTODOC -->",
+		}, {
+			name:    "named markers should hold the documentation of their dashboard",
+			content: "<!-- BEGIN_AUTODOC rows_dashboard.json -->\n<!-- END_AUTODOC rows_dashboard.json -->\nnotes\n<!-- BEGIN_AUTODOC -->\n<!-- END_AUTODOC -->\n",
+			docs:    []*DashboardDoc{rows, loki},
+			expected: "<!-- BEGIN_AUTODOC rows_dashboard.json -->\n" + render(rows) + "\n<!-- END_AUTODOC rows_dashboard.json -->\nnotes\n" +
+				"<!-- BEGIN_AUTODOC -->\n" + render(loki) + "\n<!-- END_AUTODOC -->\n",
+		}, {
+			name:     "sections of other dashboards should be left untouched",
+			content:  "<!-- BEGIN_AUTODOC other.json -->\nkept\n<!-- END_AUTODOC other.json -->\n",
+			docs:     []*DashboardDoc{rows},
+			expected: "<!-- BEGIN_AUTODOC other.json -->\nkept\n<!-- END_AUTODOC other.json -->\n\n<!-- BEGIN_AUTODOC -->\n" + render(rows) + "\n<!-- END_AUTODOC -->\n",
+		}, {
+			name:     "begin marker without end marker should return error",
+			content:  "<!-- BEGIN_AUTODOC -->\n",
+			docs:     []*DashboardDoc{rows},
+			errorMsg: "without a matching END_AUTODOC marker",
+		}, {
+			name:     "end marker without begin marker should return error",
+			content:  "<!-- END_AUTODOC -->\n",
+			docs:     []*DashboardDoc{rows},
+			errorMsg: "without a matching BEGIN_AUTODOC marker",
+		}, {
+			name:     "duplicate markers should return error",
+			content:  "<!-- BEGIN_AUTODOC -->\n<!-- END_AUTODOC -->\n<!-- BEGIN_AUTODOC -->\n<!-- END_AUTODOC -->\n",
+			docs:     []*DashboardDoc{rows},
+			errorMsg: "duplicate BEGIN_AUTODOC marker",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			injected, err := Inject([]byte(tc.content), tc.docs, Options{})
+			if tc.errorMsg != "" {
+				assert.ErrorContains(t, err, tc.errorMsg)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, string(injected))
+
+			again, err := Inject(injected, tc.docs, Options{})
+			assert.NoError(t, err)
+			assert.Equal(t, string(injected), string(again), "injecting twice should not change the file")
+		})
+	}
+}
+
+func TestInjectDocumentation(t *testing.T) {
+	doc, err := BuildDocumentation("testdata/rows_dashboard.json", Options{})
+	assert.NoError(t, err)
+
+	file := filepath.Join(t.TempDir(), "README.md")
+	assert.NoError(t, InjectDocumentation([]*DashboardDoc{doc}, file, Options{}))
+	bs, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(bs), BeginMarker+"\n# Rows Dashboard\n"))
+	assert.True(t, strings.HasSuffix(string(bs), EndMarker+"\n"))
+
+	err = InjectDocumentation([]*DashboardDoc{doc}, filepath.Join(t.TempDir(), "missing", "README.md"), Options{})
+	assert.ErrorContains(t, err, "error writing")
+}
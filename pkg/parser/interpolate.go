@@ -0,0 +1,454 @@
+package parser
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// variableSyntax matches a single Grafana variable reference at the start of a
+// string. It supports the $var, [[var]], [[var:format]], ${var}, ${var.field}
+// and ${var:format} syntaxes, mirroring the expression used by Grafana itself.
+var variableSyntax = regexp.MustCompile(`^(?:\$(\w+)|\[\[(\w+?)(?::(\w+))?\]\]|\$\{(\w+)(?:\.([^:^\}]+))?(?::([^\}]+))?\})`)
+
+// variableContext describes the syntactic position a variable reference
+// occupies within a PromQL expression, which determines the kind of
+// placeholder required for the expression to remain parsable.
+type variableContext int
+
+const (
+	// bareContext is a position outside strings, ranges, grouping clauses
+	// and binary operations, e.g. a metric name or a function argument.
+	bareContext variableContext = iota
+	// stringContext is a position inside a quoted string, e.g. a label matcher value.
+	stringContext
+	// durationContext is a position inside a range or subquery selector or
+	// after the offset modifier.
+	durationContext
+	// labelContext is a label name in a grouping clause, e.g. by ($label).
+	labelContext
+	// operandContext is an operand of a binary arithmetic or comparison
+	// operator, e.g. x * $factor.
+	operandContext
+	// scalarContext is a scalar parameter of a function or aggregation, e.g.
+	// histogram_quantile($quantile, ...) or topk($k, ...).
+	scalarContext
+)
+
+// groupingKeywords are the keywords followed by a parenthesized list of label names.
+var groupingKeywords = []string{"by", "without", "on", "ignoring", "group_left", "group_right"}
+
+// scalarParameters maps the functions and aggregations taking scalar
+// parameters to the indexes of these parameters.
+var scalarParameters = map[string][]int{
+	"bottomk":                      {0},
+	"clamp":                        {1, 2},
+	"clamp_max":                    {1},
+	"clamp_min":                    {1},
+	"double_exponential_smoothing": {1, 2},
+	"histogram_fraction":           {0, 1},
+	"histogram_quantile":           {0},
+	"holt_winters":                 {1, 2},
+	"limit_ratio":                  {0},
+	"limitk":                       {0},
+	"predict_linear":               {1},
+	"quantile":                     {0},
+	"quantile_over_time":           {0},
+	"round":                        {1},
+	"topk":                         {0},
+	"vector":                       {0},
+}
+
+// paren is an open parenthesis of an expression being interpolated.
+type paren struct {
+	// grouping reports whether the parenthesis opens a grouping clause, e.g. by (
+	grouping bool
+	// function is the function or aggregation whose arguments or grouping
+	// clause the parenthesis opens, if any
+	function string
+	// arg is the index of the argument being read
+	arg int
+}
+
+// identifierSyntax matches a valid PromQL metric or label name.
+var identifierSyntax = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+const (
+	// defaultDurationPlaceholder is used in duration positions when the
+	// variable's value is not a valid duration.
+	defaultDurationPlaceholder = "5m"
+)
+
+// globalDurations maps Grafana global variables whose value is a duration to
+// the placeholder used in their place and its value in seconds.
+var globalDurations = map[string][2]string{
+	"__interval":      {"1m", "60"},
+	"__rate_interval": {"1m", "60"},
+	"__range":         {"1h", "3600"},
+	"interval":        {"1m", "60"},
+}
+
+// globalNumbers maps Grafana global variables whose value is numeric to the
+// placeholder used in their place.
+var globalNumbers = map[string]string{
+	"__interval_ms":      "60000",
+	"__rate_interval_ms": "60000",
+	"__range_s":          "3600",
+	"__range_ms":         "3600000",
+	"__from":             "1700000000000",
+	"__to":               "1700003600000",
+}
+
+// Interpolator substitutes Grafana variable references in a query with
+// placeholders that keep the query parsable. Placeholders are type correct for
+// their position: durations inside ranges and after offset, strings inside
+// label matchers, label names in grouping clauses, numbers around binary
+// operators and in scalar parameters such as the quantile of
+// histogram_quantile, and metric selectors elsewhere. User variables are resolved using
+// the dashboard's own templating list: their current or first option value is
+// used whenever it fits the position. A variable whose value is unknown is
+// never turned into a metric name; in a metric position it becomes a
+// {__name__=~"$var"} pattern, documented as such.
+type Interpolator struct {
+	// variables holds the dashboard template variables indexed by name
+	variables map[string]Variable
+}
+
+// NewInterpolator creates an Interpolator that resolves user variables
+// against the given dashboard template variables.
+//
+// Parameters:
+//   - variables: the template variables from the dashboard's templating list
+//
+// Returns an Interpolator ready to interpolate query expressions.
+func NewInterpolator(variables []Variable) *Interpolator {
+	in := &Interpolator{variables: make(map[string]Variable, len(variables))}
+	for _, v := range variables {
+		in.variables[v.Name] = v
+	}
+	return in
+}
+
+// Interpolate replaces every Grafana variable reference in expr with a
+// placeholder suitable for its position so that the result can be handed to
+// the PromQL parser. Inside quoted strings all reference syntaxes are
+// normalized to $var, which is both a valid string and a valid regex, so label
+// matcher values keep naming the variable they depend on.
+//
+// Parameters:
+//   - expr: the query expression containing Grafana variable references
+//
+// Returns the interpolated expression.
+func (in *Interpolator) Interpolate(expr string) string {
+	var b strings.Builder
+	var quote byte
+	depth := 0
+	var parens []paren
+	// grouping is the last closed grouping clause and end its position, so
+	// that the arguments following topk by (job) are known to be topk's
+	var grouping paren
+	end := -1
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		if c == '$' || strings.HasPrefix(expr[i:], "[[") {
+			if name, n, ok := matchVariable(expr[i:]); ok {
+				ctx := bareContext
+				switch {
+				case quote != 0:
+					ctx = stringContext
+				case depth > 0 || followsOffset(expr[:i]):
+					ctx = durationContext
+				case len(parens) > 0 && parens[len(parens)-1].grouping:
+					ctx = labelContext
+				case len(parens) > 0 && parens[len(parens)-1].isScalarParameter():
+					ctx = scalarContext
+				case isOperand(expr[:i], expr[i+n:]):
+					ctx = operandContext
+				}
+				placeholder := in.placeholder(name, ctx)
+				if rest := strings.TrimLeft(expr[i+n:], " \t\r\n"); strings.HasPrefix(placeholder, "{") && strings.HasPrefix(rest, "{") {
+					// merge the name pattern with the label matchers that follow it
+					placeholder = strings.TrimSuffix(placeholder, "}") + ","
+					n = len(expr) - i - len(rest) + 1
+				}
+				b.WriteString(placeholder)
+				i += n
+				continue
+			}
+		}
+
+		switch {
+		case quote != 0 && quote != '`' && c == '\\' && i+1 < len(expr):
+			b.WriteString(expr[i : i+2])
+			i += 2
+			continue
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']' && depth > 0:
+			depth--
+		case c == '(':
+			prefix := strings.TrimRight(expr[:i], " \t\r\n")
+			p := paren{grouping: followsGroupingKeyword(prefix), function: lastIdentifier(prefix)}
+			switch {
+			case p.grouping:
+				p.function = lastIdentifier(strings.TrimRight(strings.TrimSuffix(prefix, lastIdentifier(prefix)), " \t\r\n"))
+			case len(prefix) == end+1 && end >= 0:
+				p.function = grouping.function
+			}
+			parens = append(parens, p)
+		case c == ')' && len(parens) > 0:
+			if top := parens[len(parens)-1]; top.grouping {
+				grouping, end = top, i
+			}
+			parens = parens[:len(parens)-1]
+		case c == ',' && len(parens) > 0:
+			parens[len(parens)-1].arg++
+		}
+		b.WriteByte(c)
+		i++
+	}
+	return b.String()
+}
+
+// placeholder returns the replacement for the variable called name in the given context.
+func (in *Interpolator) placeholder(name string, ctx variableContext) string {
+	if ctx == stringContext {
+		return "$" + name
+	}
+
+	if v, ok := in.variables[name]; ok {
+		value := firstValue(v)
+		switch {
+		case ctx == durationContext:
+			if isDuration(value) || isNumber(value) {
+				return value
+			}
+			return defaultDurationPlaceholder
+		case ctx == labelContext:
+			if isIdentifier(value) {
+				return value
+			}
+			return toIdentifier(name)
+		case isNumber(value):
+			return value
+		case (ctx == operandContext || ctx == scalarContext) && isDuration(value):
+			return durationSeconds(value)
+		case ctx == scalarContext:
+		case isIdentifier(value):
+			return value
+		}
+		return unknownPlaceholder(name, ctx)
+	}
+
+	if d, ok := globalDurations[name]; ok {
+		if ctx == durationContext {
+			return d[0]
+		}
+		return d[1]
+	}
+	if n, ok := globalNumbers[name]; ok {
+		return n
+	}
+	if strings.HasPrefix(name, "__auto_interval") {
+		if ctx == durationContext {
+			return "1m"
+		}
+		return "60"
+	}
+	return unknownPlaceholder(name, ctx)
+}
+
+// unknownPlaceholder returns the replacement for a variable whose value is
+// unknown or does not fit its position: the default duration in a duration
+// position, the variable name as a label name, the number 1 as an operand or
+// a scalar parameter, and a {__name__=~"$name"} pattern in a metric position,
+// so that the variable is documented as a pattern rather than as a metric.
+func unknownPlaceholder(name string, ctx variableContext) string {
+	switch ctx {
+	case durationContext:
+		return defaultDurationPlaceholder
+	case labelContext:
+		return toIdentifier(name)
+	case operandContext, scalarContext:
+		return "1"
+	}
+	return `{__name__=~"$` + name + `"}`
+}
+
+// matchVariable matches a variable reference at the start of s. It returns
+// the referenced variable name and the length of the reference. Purely
+// numeric names such as $1 are regex capture group references and are not
+// treated as variables.
+func matchVariable(s string) (name string, length int, ok bool) {
+	m := variableSyntax.FindStringSubmatchIndex(s)
+	if m == nil {
+		return "", 0, false
+	}
+	for _, group := range []int{1, 2, 4} {
+		if m[2*group] >= 0 {
+			name = s[m[2*group]:m[2*group+1]]
+			break
+		}
+	}
+	if isNumber(name) {
+		return "", 0, false
+	}
+	return name, m[1], true
+}
+
+// followsOffset reports whether the text preceding a variable reference ends
+// with the offset modifier keyword.
+func followsOffset(prefix string) bool {
+	trimmed := strings.ToLower(strings.TrimRight(prefix, " \t\r\n"))
+	if !strings.HasSuffix(trimmed, "offset") {
+		return false
+	}
+	before := strings.TrimSuffix(trimmed, "offset")
+	return before == "" || !isIdentifierChar(before[len(before)-1])
+}
+
+// isOperand reports whether a variable reference is an operand of a binary
+// arithmetic or comparison operator, given the text before and after it.
+func isOperand(prefix, suffix string) bool {
+	before := strings.TrimRight(prefix, " \t\r\n")
+	after := strings.TrimLeft(suffix, " \t\r\n")
+	const operators = "+-*/%^<>=!"
+	if before != "" && strings.IndexByte(operators, before[len(before)-1]) >= 0 {
+		return true
+	}
+	if strings.HasSuffix(strings.ToLower(before), "bool") && !isIdentifierChar(lastByte(strings.TrimSuffix(strings.ToLower(before), "bool"))) {
+		return true
+	}
+	return after != "" && strings.IndexByte(operators, after[0]) >= 0
+}
+
+// isScalarParameter reports whether the argument being read is a scalar
+// parameter of the function or aggregation, e.g. the first argument of topk.
+func (p paren) isScalarParameter() bool {
+	return !p.grouping && slices.Contains(scalarParameters[strings.ToLower(p.function)], p.arg)
+}
+
+// lastIdentifier returns the identifier s ends with, e.g. the name of the
+// function whose arguments follow, or "" if s does not end with one.
+func lastIdentifier(s string) string {
+	start := len(s)
+	for start > 0 && isIdentifierChar(s[start-1]) {
+		start--
+	}
+	return s[start:]
+}
+
+// followsGroupingKeyword reports whether the text preceding an opening
+// parenthesis ends with a keyword followed by a list of label names, e.g. by.
+func followsGroupingKeyword(prefix string) bool {
+	trimmed := strings.ToLower(strings.TrimRight(prefix, " \t\r\n"))
+	for _, keyword := range groupingKeywords {
+		if before, ok := strings.CutSuffix(trimmed, keyword); ok && !isIdentifierChar(lastByte(before)) {
+			return true
+		}
+	}
+	return false
+}
+
+// lastByte returns the last byte of s, or 0 if s is empty.
+func lastByte(s string) byte {
+	if s == "" {
+		return 0
+	}
+	return s[len(s)-1]
+}
+
+// firstValue returns the first current value of a variable, ignoring the
+// special "$__all" value, or its first option if it has no current value, read
+// from the query of custom variables without options.
+func firstValue(v Variable) string {
+	for _, values := range []StringList{v.Current.Value, v.Current.Text} {
+		for _, value := range values {
+			if isSelectableValue(value) {
+				return value
+			}
+		}
+	}
+	for _, option := range v.Options {
+		for _, value := range option.Value {
+			if isSelectableValue(value) {
+				return value
+			}
+		}
+	}
+	if v.Type == "custom" {
+		// custom options are comma separated values, or text : value pairs
+		for _, option := range strings.Split(string(v.Query), ",") {
+			if _, value, ok := strings.Cut(option, " : "); ok {
+				option = value
+			}
+			if option = strings.TrimSpace(option); isSelectableValue(option) {
+				return option
+			}
+		}
+	}
+	if v.Type == "constant" || v.Type == "textbox" {
+		return string(v.Query)
+	}
+	return ""
+}
+
+// isSelectableValue reports whether a variable value is an actual value
+// rather than empty or the special "All" value.
+func isSelectableValue(value string) bool {
+	return value != "" && value != "$__all" && !strings.EqualFold(value, "all")
+}
+
+// isIdentifier reports whether s is a valid PromQL metric or label name.
+func isIdentifier(s string) bool {
+	return identifierSyntax.MatchString(s)
+}
+
+// durationSeconds returns a PromQL duration such as 10m in seconds.
+func durationSeconds(s string) string {
+	d, _ := model.ParseDuration(s)
+	return strconv.FormatFloat(time.Duration(d).Seconds(), 'f', -1, 64)
+}
+
+// isDuration reports whether s is a valid PromQL duration such as 5m or 1h30m.
+func isDuration(s string) bool {
+	_, err := model.ParseDuration(s)
+	return s != "" && err == nil
+}
+
+// isNumber reports whether s is a valid numeric literal.
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+// toIdentifier converts a variable name into a valid PromQL identifier.
+func toIdentifier(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if isIdentifierChar(name[i]) {
+			b.WriteByte(name[i])
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	id := b.String()
+	if id == "" || (id[0] >= '0' && id[0] <= '9') {
+		id = "_" + id
+	}
+	return id
+}
+
+// isIdentifierChar reports whether c may appear in a PromQL identifier.
+func isIdentifierChar(c byte) bool {
+	return c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,180 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterpolate(t *testing.T) {
+	variables := []Variable{
+		{Name: "namespace", Type: "query", Multi: true, Current: VariableCurrent{Value: StringList{"default", "kube-system"}}},
+		{Name: "window", Type: "interval", Current: VariableCurrent{Value: StringList{"10m"}}},
+		{Name: "step", Type: "custom", Current: VariableCurrent{Value: StringList{"auto"}}},
+		{Name: "seconds", Type: "custom", Current: VariableCurrent{Value: StringList{"300"}}},
+		{Name: "threshold", Type: "constant", Query: "0.5"},
+		{Name: "metric", Type: "query", Current: VariableCurrent{Value: StringList{"up"}}},
+		{Name: "job", Type: "query", Options: []VariableOption{{Value: StringList{"$__all"}}, {Value: StringList{"api"}}}},
+		{Name: "label", Type: "custom", Current: VariableCurrent{Value: StringList{"pod"}}},
+		{Name: "selector", Type: "textbox", Query: `x{a="b"}`},
+		{Name: "q", Type: "custom", Query: "0.5,0.9"},
+		{Name: "level", Type: "custom", Query: "Low : 0.1, High : 0.9"},
+	}
+
+	tests := []struct {
+		name     string
+		expr     string
+		expected string
+		metrics  []string
+	}{
+		{
+			name:     "global range variables in range positions should become durations",
+			expr:     "rate(x[$__rate_interval]) + rate(y[$__interval]) + increase(z[$__range])",
+			expected: "rate(x[1m]) + rate(y[1m]) + increase(z[1h])",
+		}, {
+			name:     "numeric global variables followed by a unit should stay valid durations",
+			expr:     "increase(x[${__range_s}s])",
+			expected: "increase(x[3600s])",
+		}, {
+			name:     "numeric global variables in scalar positions should become numbers",
+			expr:     "sum(x) / $__range_s * ${__interval_ms}",
+			expected: "sum(x) / 3600 * 60000",
+		}, {
+			name:     "user interval variable in range position should use its value",
+			expr:     "rate(x[$window])",
+			expected: "rate(x[10m])",
+		}, {
+			name:     "user variable without duration value in range position should use default duration",
+			expr:     "rate(x[${step}])",
+			expected: "rate(x[5m])",
+		}, {
+			name:     "numeric user variable in range position should keep its value",
+			expr:     "rate(x[${seconds}s])",
+			expected: "rate(x[300s])",
+		}, {
+			name:     "variables in matchers should be normalized to the $var syntax",
+			expr:     `x{namespace=~"${namespace:regex}", pod="[[pod]]", job="$job"}`,
+			expected: `x{namespace=~"$namespace", pod="$pod", job="$job"}`,
+		}, {
+			name:     "bracket syntax in range position should become a duration",
+			expr:     "rate(x[[[window]]])",
+			expected: "rate(x[10m])",
+		}, {
+			name:     "offset modifier should take a duration",
+			expr:     "x offset $window",
+			expected: "x offset 10m",
+		}, {
+			name:     "constant variable in scalar position should use its value",
+			expr:     "x > $threshold",
+			expected: "x > 0.5",
+		}, {
+			name:     "variable as metric name should use its current value",
+			expr:     `${metric}{job="a"}`,
+			expected: `up{job="a"}`,
+			metrics:  []string{"up"},
+		}, {
+			name:     "variable without current value should use its first option",
+			expr:     `rate($job[5m])`,
+			expected: `rate(api[5m])`,
+			metrics:  []string{"api"},
+		}, {
+			name:     "interval variable as operand should become a number of seconds",
+			expr:     "x * $window",
+			expected: "x * 600",
+			metrics:  []string{"x"},
+		}, {
+			name:     "non numeric variable as operand should become a number",
+			expr:     "$selector / x > bool $unknown",
+			expected: "1 / x > bool 1",
+			metrics:  []string{"x"},
+		}, {
+			name:     "unknown variable as metric name should become a marked pattern",
+			expr:     `rate($unknown[5m])`,
+			expected: `rate({__name__=~"$unknown"}[5m])`,
+			metrics:  []string{"$unknown"},
+		}, {
+			name:     "marked pattern should be merged with the label matchers that follow it",
+			expr:     `sum(${unknown} {job="a"})`,
+			expected: `sum({__name__=~"$unknown",job="a"})`,
+			metrics:  []string{"$unknown"},
+		}, {
+			name:     "variable whose value is not a metric name should become a marked pattern",
+			expr:     `sum($selector)`,
+			expected: `sum({__name__=~"$selector"})`,
+			metrics:  []string{"$selector"},
+		}, {
+			name:     "variables in grouping clauses should become label names",
+			expr:     `sum by ($label, $unknown) (x) / on($step) group_left() y`,
+			expected: `sum by (pod, unknown) (x) / on(auto) group_left() y`,
+			metrics:  []string{"x", "y"},
+		}, {
+			name:     "custom variable without current value should use the first option of its query",
+			expr:     "x > $level",
+			expected: "x > 0.1",
+			metrics:  []string{"x"},
+		}, {
+			name:     "quantile variable in histogram_quantile should become a number",
+			expr:     "histogram_quantile($q, sum by (le) (rate(x_bucket[5m])))",
+			expected: "histogram_quantile(0.5, sum by (le) (rate(x_bucket[5m])))",
+			metrics:  []string{"x_bucket"},
+		}, {
+			name:     "unknown variable in quantile_over_time should become a number",
+			expr:     "quantile_over_time(${unknown}, x[5m])",
+			expected: "quantile_over_time(1, x[5m])",
+			metrics:  []string{"x"},
+		}, {
+			name:     "unknown variable as topk parameter should become a number",
+			expr:     "topk($unknown, x)",
+			expected: "topk(1, x)",
+			metrics:  []string{"x"},
+		}, {
+			name:     "aggregation parameter after a grouping clause should become a number",
+			expr:     "bottomk by (job) ($unknown, sum by (job, $label) (x))",
+			expected: "bottomk by (job) (1, sum by (job, pod) (x))",
+			metrics:  []string{"x"},
+		}, {
+			name:     "non numeric variable in quantile aggregation should become a number",
+			expr:     "quantile without (pod) ($selector, x)",
+			expected: "quantile without (pod) (1, x)",
+			metrics:  []string{"x"},
+		}, {
+			name:     "duration variable as scalar parameter should become a number of seconds",
+			expr:     "predict_linear(x[1h], $window) < clamp_min(y, $unknown)",
+			expected: "predict_linear(x[1h], 600) < clamp_min(y, 1)",
+			metrics:  []string{"x", "y"},
+		}, {
+			name:     "non scalar argument should stay a metric selector",
+			expr:     "topk(5, $unknown)",
+			expected: `topk(5, {__name__=~"$unknown"})`,
+			metrics:  []string{"$unknown"},
+		}, {
+			name:     "regex capture group references should be left untouched",
+			expr:     `label_replace(x, "dst", "$1", "src", "(.*)")`,
+			expected: `label_replace(x, "dst", "$1", "src", "(.*)")`,
+		}, {
+			name:     "unknown variables in range positions should use default duration",
+			expr:     "rate(x[$unknown])",
+			expected: "rate(x[5m])",
+		}, {
+			name:     "escaped quotes inside strings should not end the string",
+			expr:     `x{a="\"$namespace"} offset $window`,
+			expected: `x{a="\"$namespace"} offset 10m`,
+		},
+	}
+
+	interpolator := NewInterpolator(variables)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			interpolated := interpolator.Interpolate(tc.expr)
+			assert.Equal(t, tc.expected, interpolated)
+			metrics, err := extractMetricFromExpression(interpolated)
+			assert.NoError(t, err, "interpolated expression should be valid promql")
+			if tc.metrics != nil {
+				assert.ElementsMatch(t, tc.metrics, metrics)
+			}
+			for _, v := range append(variables, Variable{Name: "unknown"}) {
+				assert.NotContains(t, metrics, v.Name, "a variable name should never be listed as a metric")
+			}
+		})
+	}
+}
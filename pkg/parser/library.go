@@ -0,0 +1,195 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rastogiji/autodoc-grafana/pkg/utils"
+)
+
+// LibraryPanelRef represents a dashboard panel's reference to a library panel.
+type LibraryPanelRef struct {
+	UID  string `json:"uid"`
+	Name string `json:"name"`
+}
+
+// String returns a human readable representation of the reference.
+func (r LibraryPanelRef) String() string {
+	return fmt.Sprintf("%q (uid: %s)", r.Name, r.UID)
+}
+
+// libraryPanelElement represents an exported library panel as returned by the
+// Grafana library elements API, optionally wrapped in a {"result": ...} envelope.
+type libraryPanelElement struct {
+	UID    string               `json:"uid"`
+	Name   string               `json:"name"`
+	Model  json.RawMessage      `json:"model"`
+	Result *libraryPanelElement `json:"result"`
+}
+
+// panelSource is a file other than the dashboard file that a panel's content
+// was read from, along with the line of each of its JSON values, so that
+// diagnostics about that content point at the file it can be fixed in.
+type panelSource struct {
+	// file is the path of the file
+	file string
+	// lines maps the JSON pointers of the file to their line
+	lines pointerLines
+}
+
+// LibraryPanels holds library panel models indexed by UID and by name so that
+// dashboard library panel references can be resolved.
+type LibraryPanels struct {
+	// byUID maps library panel UIDs to their panel model
+	byUID map[string]Panel
+	// byName maps library panel names to their panel model
+	byName map[string]Panel
+}
+
+// LoadLibraryPanels reads every JSON file in a directory of exported library
+// panels. Each file may contain a library element ({uid, name, model}), the
+// same element wrapped in a {"result": ...} envelope, or a bare panel model
+// carrying its own uid and title.
+//
+// Parameters:
+//   - dir: the directory containing the exported library panel JSON files
+//
+// Returns the loaded library panels and an error if the directory or any of
+// its files cannot be read or decoded.
+func LoadLibraryPanels(dir string) (*LibraryPanels, error) {
+	files, err := utils.RetrieveJSONFilesFromDirectory(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading library panels directory: %w", err)
+	}
+
+	library := &LibraryPanels{
+		byUID:  make(map[string]Panel),
+		byName: make(map[string]Panel),
+	}
+	for _, file := range files {
+		path := filepath.Join(dir, file)
+		bs, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading library panel file %s: %w", path, err)
+		}
+		if err := library.add(path, bs); err != nil {
+			return nil, fmt.Errorf("error unmarshalling library panel %s: %w", path, err)
+		}
+	}
+	return library, nil
+}
+
+// add decodes a single exported library panel read from path and indexes it.
+// The panel keeps its path and the JSON pointer of its model in the file, so
+// that diagnostics about its content point at the library panel file.
+func (l *LibraryPanels) add(path string, bs []byte) error {
+	var element libraryPanelElement
+	if err := json.Unmarshal(bs, &element); err != nil {
+		return err
+	}
+	pointer := ""
+	if element.Result != nil {
+		element = *element.Result
+		pointer = "/result"
+	}
+
+	model := element.Model
+	if len(model) == 0 {
+		model = bs
+		pointer = ""
+	} else {
+		pointer += "/model"
+	}
+	var panel Panel
+	if err := json.Unmarshal(model, &panel); err != nil {
+		return err
+	}
+	panel.pointer = pointer
+	panel.source = &panelSource{file: path, lines: newPointerLines(bs)}
+
+	uid, name := element.UID, element.Name
+	if name == "" {
+		name = panel.Title
+	}
+	if uid != "" {
+		l.byUID[uid] = panel
+	}
+	if name != "" {
+		l.byName[name] = panel
+	}
+	return nil
+}
+
+// Resolve looks up the library panel model for a reference, by UID first and
+// falling back to the library panel name.
+func (l *LibraryPanels) Resolve(ref LibraryPanelRef) (Panel, bool) {
+	if l == nil {
+		return Panel{}, false
+	}
+	if panel, ok := l.byUID[ref.UID]; ok && ref.UID != "" {
+		return panel, true
+	}
+	panel, ok := l.byName[ref.Name]
+	return panel, ok && ref.Name != ""
+}
+
+// mergeLibraryPanel returns the panel resulting from applying the library model to a
+// dashboard panel. The library model is the source of truth for the panel's
+// content, while the dashboard keeps the panel's identity and layout. The
+// merged panel keeps the library model's pointer and source, since its
+// content, and hence any problem with it, lives in the library panel file.
+func mergeLibraryPanel(panel Panel, model Panel) Panel {
+	merged := model
+	merged.ID = panel.ID
+	merged.GridPos = panel.GridPos
+	merged.LibraryPanel = panel.LibraryPanel
+	if merged.Title == "" {
+		merged.Title = panel.Title
+	}
+	return merged
+}
+
+// ResolveLibraryPanels replaces every library panel reference in the dashboard,
+// including references nested in collapsed rows, with the matching library
+// panel model.
+//
+// Parameters:
+//   - library: the library panels to resolve references against; may be nil
+//
+// Returns the references that could not be resolved.
+func (d *Dashboard) ResolveLibraryPanels(library *LibraryPanels) []LibraryPanelRef {
+	var unresolved []LibraryPanelRef
+	for _, panel := range d.resolveLibraryPanels(library) {
+		unresolved = append(unresolved, *panel.LibraryPanel)
+	}
+	return unresolved
+}
+
+// resolveLibraryPanels resolves the dashboard's library panel references and
+// returns the panels whose reference could not be resolved.
+func (d *Dashboard) resolveLibraryPanels(library *LibraryPanels) []Panel {
+	var unresolved []Panel
+	resolve := func(panel Panel) Panel {
+		if panel.LibraryPanel == nil {
+			return panel
+		}
+		model, ok := library.Resolve(*panel.LibraryPanel)
+		if !ok {
+			unresolved = append(unresolved, panel)
+			return panel
+		}
+		return mergeLibraryPanel(panel, model)
+	}
+
+	for i := range d.Panels {
+		if d.Panels[i].Type != "row" {
+			d.Panels[i].Panel = resolve(d.Panels[i].Panel)
+		}
+		for j := range d.Panels[i].Panels {
+			d.Panels[i].Panels[j] = resolve(d.Panels[i].Panels[j])
+		}
+	}
+	return unresolved
+}
@@ -0,0 +1,114 @@
+package parser
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadLibraryPanels(t *testing.T) {
+	tests := []struct {
+		name         string
+		dir          string
+		ref          LibraryPanelRef
+		expectError  bool
+		errorMessage string
+		expectFound  bool
+		expectTitle  string
+	}{
+		{
+			name:        "library element should be resolved by uid",
+			dir:         "testdata/library_panels",
+			ref:         LibraryPanelRef{UID: "lib-cpu", Name: "CPU Usage"},
+			expectFound: true,
+			expectTitle: "CPU Usage",
+		}, {
+			name:        "library element wrapped in result should be resolved by uid",
+			dir:         "testdata/library_panels",
+			ref:         LibraryPanelRef{UID: "lib-memory"},
+			expectFound: true,
+			expectTitle: "Memory Usage",
+		}, {
+			name:        "unknown uid should fall back to the name",
+			dir:         "testdata/library_panels",
+			ref:         LibraryPanelRef{UID: "unknown", Name: "Memory Usage"},
+			expectFound: true,
+			expectTitle: "Memory Usage",
+		}, {
+			name:        "bare panel model should be resolved by its uid",
+			dir:         "testdata/library_panels",
+			ref:         LibraryPanelRef{UID: "lib-errors"},
+			expectFound: true,
+			expectTitle: "Error Rate",
+		}, {
+			name:        "unknown reference should not be resolved",
+			dir:         "testdata/library_panels",
+			ref:         LibraryPanelRef{UID: "unknown", Name: "Unknown"},
+			expectFound: false,
+		}, {
+			name:         "directory doesnt exist should return error",
+			dir:          "/directory/that/does/not/exist",
+			expectError:  true,
+			errorMessage: "error reading library panels directory",
+		}, {
+			name:         "invalid library panel json should return error",
+			dir:          "testdata",
+			expectError:  true,
+			errorMessage: "error unmarshalling library panel",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			library, err := LoadLibraryPanels(tc.dir)
+			if tc.expectError {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tc.errorMessage)
+				return
+			}
+			assert.NoError(t, err)
+
+			panel, found := library.Resolve(tc.ref)
+			assert.Equal(t, tc.expectFound, found)
+			assert.Equal(t, tc.expectTitle, panel.Title)
+		})
+	}
+}
+
+func TestResolveLibraryPanels(t *testing.T) {
+	library, err := LoadLibraryPanels("testdata/library_panels")
+	assert.NoError(t, err)
+
+	bs, err := os.ReadFile("testdata/library_dashboard.json")
+	assert.NoError(t, err)
+
+	var dash Dashboard
+	assert.NoError(t, json.Unmarshal(bs, &dash))
+
+	unresolved := dash.ResolveLibraryPanels(library)
+	assert.Equal(t, []LibraryPanelRef{{UID: "lib-missing", Name: "Missing Panel"}}, unresolved)
+
+	panels := dash.GetPanels()
+	assert.Len(t, panels, 3)
+
+	assert.Equal(t, "CPU Usage", panels[0].Title)
+	assert.Equal(t, "timeseries", panels[0].Type)
+	assert.Equal(t, 1, panels[0].ID)
+	assert.Equal(t, GridPos{H: 8, W: 12, X: 0, Y: 0}, panels[0].GridPos)
+	assert.Len(t, panels[0].Targets, 1)
+
+	assert.Equal(t, "Memory Usage", panels[1].Title)
+	assert.Equal(t, 3, panels[1].ID)
+	assert.Len(t, panels[1].Targets, 1)
+
+	assert.Equal(t, "Missing", panels[2].Title)
+	assert.Empty(t, panels[2].Targets)
+
+	t.Run("nil library should report every reference as unresolved", func(t *testing.T) {
+		var dash Dashboard
+		assert.NoError(t, json.Unmarshal(bs, &dash))
+		assert.Len(t, dash.ResolveLibraryPanels(nil), 3)
+	})
+}
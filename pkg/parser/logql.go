@@ -0,0 +1,417 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// LogQuery describes what a LogQL query reads and how it processes the log lines.
+type LogQuery struct {
+	// Streams contains the stream selectors, e.g. {app="api", env=~"prod|staging"}
+	Streams []string `json:"streams,omitempty"`
+	// Matchers contains the label matchers used by the stream selectors, e.g. app="api"
+	Matchers []string `json:"matchers,omitempty"`
+	// LineFilters contains the line filter expressions, e.g. |= "error"
+	LineFilters []string `json:"lineFilters,omitempty"`
+	// Parsers contains the parser stages, e.g. json, logfmt or regexp "<re>"
+	Parsers []string `json:"parsers,omitempty"`
+	// LabelFilters contains the label filter expressions applied after parsing, e.g. status >= 500
+	LabelFilters []string `json:"labelFilters,omitempty"`
+	// Aggregations contains the metric query aggregations, e.g. sum by (app) or rate [5m]
+	Aggregations []string `json:"aggregations,omitempty"`
+}
+
+// logQueryData represents a LogQL query formatted for documentation purposes.
+type logQueryData struct {
+	// Streams contains the stream selectors
+	Streams []string
+	// LineFilters contains the line filter expressions
+	LineFilters []string
+	// Parsers contains the parser stages
+	Parsers []string
+	// LabelFilters contains the label filter expressions
+	LabelFilters []string
+	// Aggregations contains the metric query aggregations
+	Aggregations []string
+}
+
+// newLogQueryData converts a LogQuery into its documentation representation,
+// escaping every value for use inside a markdown table cell.
+func newLogQueryData(q LogQuery) logQueryData {
+	escape := func(values []string) []string {
+		escaped := make([]string, 0, len(values))
+		for _, value := range values {
+			escaped = append(escaped, escapeTableCell(value))
+		}
+		return escaped
+	}
+	return logQueryData{
+		Streams:      escape(q.Streams),
+		LineFilters:  escape(q.LineFilters),
+		Parsers:      escape(q.Parsers),
+		LabelFilters: escape(q.LabelFilters),
+		Aggregations: escape(q.Aggregations),
+	}
+}
+
+var (
+	// logqlParsers lists the parser stages that extract labels from log lines.
+	logqlParsers = []string{"json", "logfmt", "regexp", "pattern", "unpack"}
+	// logqlFormatStages lists the pipeline stages that only reshape log lines
+	// or labels and therefore don't change which logs are selected.
+	logqlFormatStages = []string{"line_format", "label_format", "drop", "keep", "decolorize", "distinct"}
+	// logqlRangeAggregations lists the functions aggregating log streams over a range.
+	logqlRangeAggregations = []string{
+		"rate", "rate_counter", "count_over_time", "bytes_rate", "bytes_over_time",
+		"sum_over_time", "avg_over_time", "max_over_time", "min_over_time",
+		"first_over_time", "last_over_time", "stdvar_over_time", "stddev_over_time",
+		"quantile_over_time", "absent_over_time",
+	}
+	// logqlVectorAggregations lists the functions aggregating over label dimensions.
+	logqlVectorAggregations = []string{
+		"sum", "avg", "min", "max", "count", "stddev", "stdvar",
+		"topk", "bottomk", "sort", "sort_desc", "approx_topk",
+	}
+	// logqlLineFilterOps lists the operators introducing a line filter.
+	logqlLineFilterOps = []string{"|=", "!=", "|~", "!~", "|>", "!>"}
+)
+
+// extractLogQLTarget extracts the log streams read by a Loki query target.
+func extractLogQLTarget(target json.RawMessage, _ *Interpolator) (QueryEntities, error) {
+	var t struct {
+		Expr string `json:"expr"`
+	}
+	if err := json.Unmarshal(target, &t); err != nil {
+		return QueryEntities{}, fmt.Errorf("error unmarshalling loki target: %w", err)
+	}
+	if strings.TrimSpace(t.Expr) == "" {
+		return QueryEntities{}, nil
+	}
+	q, err := ParseLogQL(t.Expr)
+	if err != nil {
+		return QueryEntities{}, &QueryError{Field: "expr", Err: fmt.Errorf("error parsing logql expression: %w", err)}
+	}
+	return QueryEntities{LogStreams: q.Streams, LogQueries: []LogQuery{q}}, nil
+}
+
+// ParseLogQL analyses a LogQL expression and describes its stream selectors,
+// label matchers, line filters, parser stages, label filters and metric
+// aggregations. Grafana variables are tolerated anywhere in the expression.
+//
+// Parameters:
+//   - expr: the LogQL expression to analyse
+//
+// Returns the description of the query and an error if the expression is
+// malformed or doesn't contain a stream selector.
+func ParseLogQL(expr string) (LogQuery, error) {
+	tokens, err := lexLogQL(expr)
+	if err != nil {
+		return LogQuery{}, err
+	}
+
+	var q LogQuery
+	for i := 0; i < len(tokens); {
+		tok := tokens[i]
+		switch {
+		case tok.is(logqlPunct, "{"):
+			end, matchers, err := parseLogQLSelector(tokens, i)
+			if err != nil {
+				return LogQuery{}, err
+			}
+			q.Streams = append(q.Streams, "{"+strings.Join(matchers, ", ")+"}")
+			q.Matchers = append(q.Matchers, matchers...)
+			i = end + 1
+		case tok.kind == logqlOp && slices.Contains(logqlLineFilterOps, tok.text):
+			end := parseLogQLLineFilter(tokens, i)
+			q.LineFilters = append(q.LineFilters, joinLogQLTokens(tokens[i:end]))
+			i = end
+		case tok.is(logqlOp, "|") && i+1 < len(tokens):
+			name := tokens[i+1].text
+			isLabelFilter := tokens[i+1].kind != logqlIdent ||
+				!(slices.Contains(logqlParsers, name) || slices.Contains(logqlFormatStages, name) || name == "unwrap")
+			end := logQLStageEnd(tokens, i+1, isLabelFilter)
+			stage := joinLogQLTokens(tokens[i+1 : end])
+			switch {
+			case isLabelFilter:
+				q.LabelFilters = append(q.LabelFilters, stage)
+			case slices.Contains(logqlParsers, name):
+				q.Parsers = append(q.Parsers, stage)
+			case name == "unwrap":
+				q.Aggregations = append(q.Aggregations, stage)
+			}
+			i = end
+		case tok.kind == logqlIdent && i+1 < len(tokens) &&
+			(slices.Contains(logqlRangeAggregations, tok.text) || slices.Contains(logqlVectorAggregations, tok.text)):
+			if aggregation, ok := describeLogQLAggregation(tokens, i); ok {
+				q.Aggregations = append(q.Aggregations, aggregation)
+			}
+			i++
+		default:
+			i++
+		}
+	}
+
+	if len(q.Streams) == 0 {
+		return LogQuery{}, errors.New("no stream selector found")
+	}
+	return q, nil
+}
+
+// parseLogQLSelector parses the stream selector starting at tokens[start] and
+// returns the index of its closing brace together with its label matchers.
+func parseLogQLSelector(tokens []logqlToken, start int) (int, []string, error) {
+	var matchers []string
+	i := start + 1
+	for i < len(tokens) && !tokens[i].is(logqlPunct, "}") {
+		if tokens[i].is(logqlPunct, ",") {
+			i++
+			continue
+		}
+		if i+2 >= len(tokens) || tokens[i+1].kind != logqlOp || tokens[i+2].kind != logqlString {
+			return 0, nil, fmt.Errorf("invalid label matcher in stream selector at position %d", tokens[i].pos)
+		}
+		matchers = append(matchers, tokens[i].text+tokens[i+1].text+tokens[i+2].text)
+		i += 3
+	}
+	if i >= len(tokens) {
+		return 0, nil, errors.New("unclosed stream selector")
+	}
+	return i, matchers, nil
+}
+
+// parseLogQLLineFilter returns the index following the line filter starting
+// at tokens[start], including any chained "or" alternatives and ip() filters.
+func parseLogQLLineFilter(tokens []logqlToken, start int) int {
+	i := start + 1
+	for i < len(tokens) {
+		switch {
+		case tokens[i].kind == logqlString:
+			i++
+		case tokens[i].kind == logqlIdent && i+1 < len(tokens) && tokens[i+1].is(logqlPunct, "("):
+			i = matchingLogQLParen(tokens, i+1) + 1
+		default:
+			return i
+		}
+		if i < len(tokens) && tokens[i].is(logqlIdent, "or") {
+			i++
+			continue
+		}
+		return i
+	}
+	return i
+}
+
+// logQLStageEnd returns the index following the pipeline stage starting at
+// tokens[start]. Label filters may compare with != and !~, which otherwise
+// start a new line filter.
+func logQLStageEnd(tokens []logqlToken, start int, isLabelFilter bool) int {
+	depth := 0
+	for i := start; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch {
+		case tok.is(logqlPunct, "("):
+			depth++
+		case tok.is(logqlPunct, ")"):
+			if depth == 0 {
+				return i
+			}
+			depth--
+		case depth > 0:
+		case tok.is(logqlPunct, "["), tok.is(logqlOp, "|"):
+			return i
+		case i > start && tok.kind == logqlOp && slices.Contains(logqlLineFilterOps, tok.text):
+			if !isLabelFilter || (tok.text != "!=" && tok.text != "!~") {
+				return i
+			}
+		}
+	}
+	return len(tokens)
+}
+
+// describeLogQLAggregation describes the aggregation function called at
+// tokens[start], including its range and grouping labels.
+func describeLogQLAggregation(tokens []logqlToken, start int) (string, bool) {
+	description := tokens[start].text
+	i := start + 1
+
+	grouping := ""
+	if i < len(tokens) && (tokens[i].is(logqlIdent, "by") || tokens[i].is(logqlIdent, "without")) {
+		end := matchingLogQLParen(tokens, i+1)
+		grouping = joinLogQLTokens(tokens[i : end+1])
+		i = end + 1
+	}
+	if i >= len(tokens) || !tokens[i].is(logqlPunct, "(") {
+		return "", false
+	}
+
+	end := matchingLogQLParen(tokens, i)
+	if slices.Contains(logqlRangeAggregations, tokens[start].text) {
+		if r := logQLRange(tokens[i+1 : end]); r != "" {
+			description += " " + r
+		}
+	}
+	if grouping == "" && end+1 < len(tokens) && (tokens[end+1].is(logqlIdent, "by") || tokens[end+1].is(logqlIdent, "without")) {
+		groupEnd := matchingLogQLParen(tokens, end+2)
+		grouping = joinLogQLTokens(tokens[end+1 : groupEnd+1])
+	}
+	if grouping != "" {
+		description += " " + grouping
+	}
+	return description, true
+}
+
+// logQLRange returns the range selector, e.g. [5m], found at the top level of
+// a function's arguments.
+func logQLRange(args []logqlToken) string {
+	depth := 0
+	for i, tok := range args {
+		switch {
+		case tok.is(logqlPunct, "("):
+			depth++
+		case tok.is(logqlPunct, ")"):
+			depth--
+		case tok.is(logqlPunct, "[") && depth == 0:
+			for j := i; j < len(args); j++ {
+				if args[j].is(logqlPunct, "]") {
+					return joinLogQLTokens(args[i : j+1])
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// matchingLogQLParen returns the index of the parenthesis closing the one at
+// tokens[open], or the last index when it is not closed.
+func matchingLogQLParen(tokens []logqlToken, open int) int {
+	depth := 0
+	for i := open; i < len(tokens); i++ {
+		switch {
+		case tokens[i].is(logqlPunct, "("):
+			depth++
+		case tokens[i].is(logqlPunct, ")"):
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(tokens) - 1
+}
+
+// joinLogQLTokens renders tokens back into a normalized LogQL fragment.
+func joinLogQLTokens(tokens []logqlToken) string {
+	var b strings.Builder
+	for i, tok := range tokens {
+		if i > 0 {
+			prev := tokens[i-1]
+			tight := tok.is(logqlPunct, ")") || tok.is(logqlPunct, "]") || tok.is(logqlPunct, ",") ||
+				prev.is(logqlPunct, "(") || prev.is(logqlPunct, "[") ||
+				(tok.is(logqlPunct, "(") && prev.kind == logqlIdent && prev.text != "by" && prev.text != "without")
+			if !tight {
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(tok.text)
+	}
+	return b.String()
+}
+
+// logqlTokenKind classifies LogQL tokens.
+type logqlTokenKind int
+
+const (
+	// logqlIdent is an identifier, keyword, number, duration or variable reference
+	logqlIdent logqlTokenKind = iota
+	// logqlString is a quoted string, including its quotes
+	logqlString
+	// logqlOp is an operator such as |=, =~ or |
+	logqlOp
+	// logqlPunct is one of { } ( ) [ ] ,
+	logqlPunct
+)
+
+// logqlToken is a single lexical token of a LogQL expression.
+type logqlToken struct {
+	kind logqlTokenKind
+	text string
+	pos  int
+}
+
+// is reports whether the token has the given kind and text.
+func (t logqlToken) is(kind logqlTokenKind, text string) bool {
+	return t.kind == kind && t.text == text
+}
+
+// lexLogQL splits a LogQL expression into tokens.
+func lexLogQL(expr string) ([]logqlToken, error) {
+	var tokens []logqlToken
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '#':
+			for i < len(expr) && expr[i] != '\n' {
+				i++
+			}
+		case c == '"' || c == '`':
+			end := i + 1
+			for end < len(expr) && expr[end] != c {
+				if c == '"' && expr[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(expr) {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			tokens = append(tokens, logqlToken{kind: logqlString, text: expr[i : end+1], pos: i})
+			i = end + 1
+		case strings.ContainsRune("{}()[],", rune(c)):
+			tokens = append(tokens, logqlToken{kind: logqlPunct, text: string(c), pos: i})
+			i++
+		case c == '$' && strings.HasPrefix(expr[i:], "${"):
+			end := strings.IndexByte(expr[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated variable at position %d", i)
+			}
+			tokens = append(tokens, logqlToken{kind: logqlIdent, text: expr[i : i+end+1], pos: i})
+			i += end + 1
+		case isIdentifierChar(c) || c == '$' || c == '.':
+			end := i + 1
+			for end < len(expr) && (isIdentifierChar(expr[end]) || expr[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, logqlToken{kind: logqlIdent, text: expr[i:end], pos: i})
+			i = end
+		default:
+			end := i + 1
+			if end < len(expr) && strings.ContainsRune("=~>", rune(expr[end])) && strings.ContainsRune("|!=<>", rune(c)) {
+				end++
+			}
+			tokens = append(tokens, logqlToken{kind: logqlOp, text: expr[i:end], pos: i})
+			i = end
+		}
+	}
+
+	depth := 0
+	for _, tok := range tokens {
+		switch {
+		case tok.is(logqlPunct, "{"), tok.is(logqlPunct, "("):
+			depth++
+		case tok.is(logqlPunct, "}"), tok.is(logqlPunct, ")"):
+			depth--
+		}
+		if depth < 0 {
+			return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+		}
+	}
+	if depth != 0 {
+		return nil, errors.New("unbalanced brackets")
+	}
+	return tokens, nil
+}
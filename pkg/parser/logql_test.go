@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLogQL(t *testing.T) {
+	tests := []struct {
+		name         string
+		expr         string
+		expected     LogQuery
+		expectError  bool
+		errorMessage string
+	}{
+		{
+			name: "stream selector with line filters",
+			expr: `{app="api", env=~"prod|staging"} |= "error" != "debug" |~ "time(out)?"`,
+			expected: LogQuery{
+				Streams:     []string{`{app="api", env=~"prod|staging"}`},
+				Matchers:    []string{`app="api"`, `env=~"prod|staging"`},
+				LineFilters: []string{`|= "error"`, `!= "debug"`, `|~ "time(out)?"`},
+			},
+		}, {
+			name: "parser stages and label filters",
+			expr: "{job=\"nginx\"} | json | status >= 500 | logfmt | regexp `(?P<ip>\\S+)` | pattern \"<_> <method> <path>\" | line_format \"{{.msg}}\"",
+			expected: LogQuery{
+				Streams:      []string{`{job="nginx"}`},
+				Matchers:     []string{`job="nginx"`},
+				Parsers:      []string{"json", "logfmt", "regexp `(?P<ip>\\S+)`", `pattern "<_> <method> <path>"`},
+				LabelFilters: []string{"status >= 500"},
+			},
+		}, {
+			name: "label filter with negative comparison should not become a line filter",
+			expr: `{app="api"} | json | level != "debug" != "healthcheck"`,
+			expected: LogQuery{
+				Streams:      []string{`{app="api"}`},
+				Matchers:     []string{`app="api"`},
+				Parsers:      []string{"json"},
+				LabelFilters: []string{`level != "debug" != "healthcheck"`},
+			},
+		}, {
+			name: "metric query with prefix grouping",
+			expr: `sum by (level) (count_over_time({app="api"} |= "error" [$__interval]))`,
+			expected: LogQuery{
+				Streams:      []string{`{app="api"}`},
+				Matchers:     []string{`app="api"`},
+				LineFilters:  []string{`|= "error"`},
+				Aggregations: []string{"sum by (level)", "count_over_time [$__interval]"},
+			},
+		}, {
+			name: "metric query with suffix grouping and unwrap",
+			expr: `topk(5, sum(rate({app="api"} | logfmt | unwrap duration [5m])) by (path))`,
+			expected: LogQuery{
+				Streams:      []string{`{app="api"}`},
+				Matchers:     []string{`app="api"`},
+				Parsers:      []string{"logfmt"},
+				Aggregations: []string{"topk", "sum by (path)", "rate [5m]", "unwrap duration"},
+			},
+		}, {
+			name: "line filter alternatives and ip filter",
+			expr: `{app="api"} |= "error" or "fatal" |= ip("10.0.0.0/8")`,
+			expected: LogQuery{
+				Streams:     []string{`{app="api"}`},
+				Matchers:    []string{`app="api"`},
+				LineFilters: []string{`|= "error" or "fatal"`, `|= ip("10.0.0.0/8")`},
+			},
+		}, {
+			name: "variables in selectors should be tolerated",
+			expr: `{namespace="$namespace", pod=~"${pod:regex}"} |~ "$search"`,
+			expected: LogQuery{
+				Streams:     []string{`{namespace="$namespace", pod=~"${pod:regex}"}`},
+				Matchers:    []string{`namespace="$namespace"`, `pod=~"${pod:regex}"`},
+				LineFilters: []string{`|~ "$search"`},
+			},
+		}, {
+			name:         "unterminated string should return error",
+			expr:         `{app="api} |= "error"`,
+			expectError:  true,
+			errorMessage: "unterminated string",
+		}, {
+			name:         "unbalanced brackets should return error",
+			expr:         `sum(count_over_time({app="api"}[5m])`,
+			expectError:  true,
+			errorMessage: "unbalanced brackets",
+		}, {
+			name:         "expression without stream selector should return error",
+			expr:         `vector(1)`,
+			expectError:  true,
+			errorMessage: "no stream selector found",
+		}, {
+			name:         "invalid matcher should return error",
+			expr:         `{app}`,
+			expectError:  true,
+			errorMessage: "invalid label matcher",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := ParseLogQL(tc.expr)
+			if tc.expectError {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tc.errorMessage)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, q)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// LoadMetricList reads a list of known metric names from a file containing one
+// metric name per line. Blank lines and lines starting with # are ignored.
+//
+// Parameters:
+//   - path: the path to the metric list file
+//
+// Returns the metric names and an error if the file cannot be read.
+func LoadMetricList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading metric list: %w", err)
+	}
+	defer f.Close()
+
+	var metrics []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		metrics = append(metrics, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading metric list: %w", err)
+	}
+	return metrics, nil
+}
+
+// expandMetricPattern returns the known metric names fully matched by a
+// metric name regex, the way Prometheus anchors __name__=~ matchers.
+// Patterns that are not valid regular expressions match nothing.
+func expandMetricPattern(pattern string, known []string) []string {
+	if len(known) == 0 {
+		return nil
+	}
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil
+	}
+	var matches []string
+	for _, metric := range known {
+		if re.MatchString(metric) {
+			matches = append(matches, metric)
+		}
+	}
+	return matches
+}
@@ -0,0 +1,472 @@
+package parser
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Schema versions introducing the changes migrated by Dashboard.migrate,
+// matching Grafana's dashboard migrator.
+const (
+	// gridLayoutSchemaVersion replaced the rows of a dashboard with row panels
+	// and grid positions
+	gridLayoutSchemaVersion = 16
+	// textPanelSchemaVersion renamed the text2 panel to text
+	textPanelSchemaVersion = 26
+	// datasourceRefSchemaVersion replaced datasource names with references
+	datasourceRefSchemaVersion = 33
+	// targetDatasourceSchemaVersion gave every target the datasource of its panel
+	targetDatasourceSchemaVersion = 36
+)
+
+const (
+	// gridColumns is the number of columns of the dashboard grid
+	gridColumns = 24
+	// gridCellHeight and gridCellMargin are the height and vertical margin of
+	// a grid cell in pixels, used to convert legacy panel heights
+	gridCellHeight = 30
+	gridCellMargin = 8
+	// defaultRowHeight is the height in pixels of a legacy row without height
+	defaultRowHeight = 250
+	// defaultPanelSpan is the width of a legacy panel without span, out of 12
+	defaultPanelSpan = 4
+)
+
+// mixedDatasource is the datasource of panels whose targets query several datasources.
+var mixedDatasource = Datasource{Type: "datasource", UID: "-- Mixed --"}
+
+// specialDatasources maps the names of Grafana's built-in datasources, as
+// referenced by legacy dashboards, to their datasource references.
+var specialDatasources = map[string]Datasource{
+	"-- Mixed --":     mixedDatasource,
+	"-- Grafana --":   {Type: "datasource", UID: "grafana"},
+	"-- Dashboard --": {Type: "datasource", UID: "-- Dashboard --"},
+}
+
+// panelMigrations maps deprecated panel types to the panel type Grafana
+// replaces them with when a dashboard is loaded.
+var panelMigrations = map[string]string{
+	"graph":                    "timeseries",
+	"singlestat":               "stat",
+	"grafana-singlestat-panel": "stat",
+	"table-old":                "table",
+	"grafana-piechart-panel":   "piechart",
+	"grafana-worldmap-panel":   "geomap",
+	"natel-discrete-panel":     "state-timeline",
+}
+
+// legacyDashboard holds the fields of a dashboard model that were removed or
+// changed by the schema migrations, decoded alongside Dashboard.
+type legacyDashboard struct {
+	Rows   []legacyRow   `json:"rows"`
+	Panels []legacyPanel `json:"panels"`
+}
+
+// legacyRow is a row of a dashboard older than schema version 16, which holds
+// its panels, sized in twelfths of the width and in pixels.
+type legacyRow struct {
+	Title     string            `json:"title"`
+	Collapse  bool              `json:"collapse"`
+	ShowTitle bool              `json:"showTitle"`
+	Repeat    string            `json:"repeat"`
+	Height    json.RawMessage   `json:"height"`
+	Panels    []json.RawMessage `json:"panels"`
+}
+
+// legacyPanel holds the fields of a panel that were removed or changed by
+// the schema migrations and the replacement of deprecated panels.
+type legacyPanel struct {
+	Span   float64         `json:"span"`
+	Height json.RawMessage `json:"height"`
+	// Thresholds is a comma separated string for singlestat panels and a
+	// list of legacyGraphThreshold for graph panels
+	Thresholds json.RawMessage `json:"thresholds"`
+	Colors     []string        `json:"colors"`
+	Gauge      struct {
+		Show     bool     `json:"show"`
+		MinValue *float64 `json:"minValue"`
+		MaxValue *float64 `json:"maxValue"`
+	} `json:"gauge"`
+	// Format and Decimals are the unit and decimals of a singlestat panel
+	Format   string `json:"format"`
+	Decimals *int   `json:"decimals"`
+	// ValueMaps and RangeMaps are the value mappings of a singlestat panel
+	ValueMaps []legacyValueMap `json:"valueMaps"`
+	RangeMaps []legacyRangeMap `json:"rangeMaps"`
+	// YAxes contains the left and right axes of a graph panel
+	YAxes  []legacyAxis  `json:"yaxes"`
+	Panels []legacyPanel `json:"panels"`
+}
+
+// legacyValueMap maps a value of a singlestat panel to a text.
+type legacyValueMap struct {
+	Value string `json:"value"`
+	Text  string `json:"text"`
+}
+
+// legacyRangeMap maps a range of values of a singlestat panel to a text.
+type legacyRangeMap struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Text string `json:"text"`
+}
+
+// legacyAxis is a y axis of a graph panel. Its minimum and maximum are
+// numbers or strings, depending on the Grafana version that saved it.
+type legacyAxis struct {
+	Format   string          `json:"format"`
+	Decimals *int            `json:"decimals"`
+	Min      json.RawMessage `json:"min"`
+	Max      json.RawMessage `json:"max"`
+}
+
+// legacyGraphThreshold is a threshold of a graph panel.
+type legacyGraphThreshold struct {
+	Value     *float64 `json:"value"`
+	Op        string   `json:"op"`
+	ColorMode string   `json:"colorMode"`
+	FillColor string   `json:"fillColor"`
+	LineColor string   `json:"lineColor"`
+}
+
+// graphThresholdColors maps the color modes of graph thresholds to colors.
+var graphThresholdColors = map[string]string{
+	"critical": "red",
+	"warning":  "orange",
+	"ok":       "green",
+}
+
+// migrate upgrades a dashboard in memory the way Grafana's dashboard migrator
+// does when it loads a dashboard, so that every generation of dashboards is
+// documented consistently:
+//   - rows of dashboards older than schema version 16 become row panels, and
+//     their panels get grid positions from their span and height
+//   - text2 panels become text panels
+//   - datasources referenced by the name of a built-in datasource get its
+//     reference, and targets without datasource get their panel's
+//   - deprecated panels such as graph and singlestat become their
+//     replacement, keeping their thresholds, unit, range and value mappings
+//
+// Parameters:
+//   - model: the raw JSON of the dashboard model, used to read the fields
+//     removed by the migrations
+func (d *Dashboard) migrate(model json.RawMessage) {
+	legacyRows := d.SchemaVersion < gridLayoutSchemaVersion && len(d.Panels) == 0 && hasLegacyRows(model)
+	if !legacyRows && !d.hasDeprecatedPanels() && d.SchemaVersion >= targetDatasourceSchemaVersion {
+		return
+	}
+	var legacy legacyDashboard
+	if err := json.Unmarshal(model, &legacy); err != nil {
+		return
+	}
+	if legacyRows {
+		d.upgradeRows(legacy.Rows)
+	} else {
+		for i := range d.Panels {
+			if i >= len(legacy.Panels) {
+				break
+			}
+			d.migratePanel(&d.Panels[i].Panel, legacy.Panels[i])
+			for j := range d.Panels[i].Panels {
+				if j < len(legacy.Panels[i].Panels) {
+					d.migratePanel(&d.Panels[i].Panels[j], legacy.Panels[i].Panels[j])
+				}
+			}
+		}
+	}
+}
+
+// hasLegacyRows reports whether a dashboard model has a rows section.
+func hasLegacyRows(model json.RawMessage) bool {
+	var rows struct {
+		Rows []json.RawMessage `json:"rows"`
+	}
+	return json.Unmarshal(model, &rows) == nil && len(rows.Rows) > 0
+}
+
+// hasDeprecatedPanels reports whether any panel of the dashboard, including
+// the panels of collapsed rows, has a deprecated panel type.
+func (d *Dashboard) hasDeprecatedPanels() bool {
+	for _, panel := range d.Panels {
+		if _, ok := panelMigrations[panel.Type]; ok {
+			return true
+		}
+		for _, nested := range panel.Panels {
+			if _, ok := panelMigrations[nested.Type]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// upgradeRows replaces the legacy rows of a dashboard with row panels. Rows
+// are only kept if any of them has a visible title, is collapsed or repeats,
+// as in Grafana; otherwise their panels are placed directly on the grid.
+// Panels are laid out left to right in their row, wrapping to a new line when
+// the row is full.
+func (d *Dashboard) upgradeRows(rows []legacyRow) {
+	showRows := slices.ContainsFunc(rows, func(row legacyRow) bool {
+		return row.Collapse || row.ShowTitle || row.Repeat != ""
+	})
+	nextID := 1
+	for _, row := range rows {
+		for _, raw := range row.Panels {
+			var panel struct {
+				ID int `json:"id"`
+			}
+			_ = json.Unmarshal(raw, &panel)
+			nextID = max(nextID, panel.ID+1)
+		}
+	}
+
+	y := 0
+	for i, row := range rows {
+		rowHeight := gridHeight(row.Height, defaultRowHeight)
+		var rowPanel *RowPanel
+		if showRows {
+			rowPanel = &RowPanel{
+				Panel: Panel{
+					ID:      nextID,
+					Type:    "row",
+					Title:   row.Title,
+					GridPos: GridPos{H: 1, W: gridColumns, Y: y},
+					pointer: fmt.Sprintf("/rows/%d", i),
+				},
+				Collapsed: row.Collapse,
+			}
+			nextID++
+			y++
+		}
+
+		x, lineY, lineHeight := 0, y, 0
+		var panels []Panel
+		for j, raw := range row.Panels {
+			var panel Panel
+			var legacy legacyPanel
+			if json.Unmarshal(raw, &panel) != nil || json.Unmarshal(raw, &legacy) != nil {
+				continue
+			}
+			span := legacy.Span
+			if span <= 0 {
+				span = defaultPanelSpan
+			}
+			width := min(max(int(math.Floor(span))*gridColumns/12, 1), gridColumns)
+			height := rowHeight
+			if len(legacy.Height) > 0 {
+				height = gridHeight(legacy.Height, defaultRowHeight)
+			}
+			if x+width > gridColumns {
+				x, lineY, lineHeight = 0, lineY+lineHeight, 0
+			}
+			panel.GridPos = GridPos{H: height, W: width, X: x, Y: lineY}
+			panel.pointer = fmt.Sprintf("/rows/%d/panels/%d", i, j)
+			x += width
+			lineHeight = max(lineHeight, height)
+
+			d.migratePanel(&panel, legacy)
+			panels = append(panels, panel)
+		}
+
+		if rowPanel != nil {
+			if row.Collapse {
+				rowPanel.Panels = panels
+				panels = nil
+			}
+			d.Panels = append(d.Panels, *rowPanel)
+		}
+		for _, panel := range panels {
+			d.Panels = append(d.Panels, RowPanel{Panel: panel})
+		}
+		if rowPanel == nil || !row.Collapse {
+			y = max(lineY+lineHeight, y+rowHeight)
+		}
+	}
+}
+
+// gridHeight converts a legacy height in pixels, e.g. 250 or "250px", into
+// grid rows, using fallback pixels if the height is missing or invalid.
+func gridHeight(raw json.RawMessage, fallback int) int {
+	pixels := fallback
+	var height any
+	if err := json.Unmarshal(raw, &height); err == nil {
+		switch h := height.(type) {
+		case float64:
+			pixels = int(h)
+		case string:
+			if n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(h), "px")); err == nil {
+				pixels = n
+			}
+		}
+	}
+	pixels = max(pixels, 3*gridCellHeight)
+	return int(math.Ceil(float64(pixels) / (gridCellHeight + gridCellMargin)))
+}
+
+// migratePanel applies the migrations of a panel: renamed panel types,
+// datasource references and the replacement of deprecated panels.
+func (d *Dashboard) migratePanel(panel *Panel, legacy legacyPanel) {
+	if d.SchemaVersion < textPanelSchemaVersion && panel.Type == "text2" {
+		panel.Type = "text"
+	}
+
+	if d.SchemaVersion < datasourceRefSchemaVersion {
+		if ds, ok := specialDatasources[datasourceName(panel.Datasource)]; ok {
+			panel.Datasource = &ds
+		}
+		for i := range panel.Targets {
+			if ds, ok := specialDatasources[datasourceName(&panel.Targets[i].Datasource)]; ok {
+				panel.Targets[i].Datasource = ds
+			}
+		}
+	}
+	if d.SchemaVersion < targetDatasourceSchemaVersion && panel.Datasource != nil && *panel.Datasource != mixedDatasource {
+		for i := range panel.Targets {
+			if panel.Targets[i].Datasource == (Datasource{}) {
+				panel.Targets[i].Datasource = *panel.Datasource
+			}
+		}
+	}
+
+	replacement, ok := panelMigrations[panel.Type]
+	if !ok {
+		return
+	}
+	switch panel.Type {
+	case "singlestat", "grafana-singlestat-panel":
+		if legacy.Gauge.Show {
+			replacement = "gauge"
+		}
+		if panel.FieldConfig.Defaults.Thresholds == nil {
+			panel.FieldConfig.Defaults.Thresholds = singlestatThresholds(legacy)
+		}
+		if panel.FieldConfig.Defaults.Unit == "" {
+			panel.FieldConfig.Defaults = singlestatDefaults(panel.FieldConfig.Defaults, legacy)
+		}
+	case "graph":
+		if panel.FieldConfig.Defaults.Thresholds == nil {
+			panel.FieldConfig.Defaults.Thresholds = graphThresholds(legacy)
+		}
+		if panel.FieldConfig.Defaults.Unit == "" && len(legacy.YAxes) > 0 {
+			panel.FieldConfig.Defaults = graphDefaults(panel.FieldConfig.Defaults, legacy)
+		}
+	}
+	panel.migratedFrom = panel.Type
+	panel.Type = replacement
+}
+
+// datasourceName returns the name a legacy datasource reference holds, empty
+// for references with a type.
+func datasourceName(ds *Datasource) string {
+	if ds == nil || ds.Type != "" {
+		return ""
+	}
+	return ds.UID
+}
+
+// singlestatThresholds converts the comma separated thresholds and the colors
+// of a singlestat panel into thresholds: the first color is the base color
+// and each following color applies from the matching threshold upwards.
+func singlestatThresholds(legacy legacyPanel) *Thresholds {
+	var values string
+	if json.Unmarshal(legacy.Thresholds, &values) != nil || strings.TrimSpace(values) == "" || len(legacy.Colors) == 0 {
+		return nil
+	}
+	thresholds := &Thresholds{Mode: "absolute", Steps: []ThresholdStep{{Color: legacy.Colors[0]}}}
+	for i, value := range strings.Split(values, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || i+1 >= len(legacy.Colors) {
+			break
+		}
+		thresholds.Steps = append(thresholds.Steps, ThresholdStep{Color: legacy.Colors[i+1], Value: &v})
+	}
+	return thresholds
+}
+
+// graphThresholds converts the thresholds of a graph panel into thresholds
+// the way Grafana does when it migrates graph panels to time series: "gt"
+// thresholds color the values above them and "lt" thresholds the values
+// below them, the remaining ranges being transparent.
+func graphThresholds(legacy legacyPanel) *Thresholds {
+	var graph []legacyGraphThreshold
+	if json.Unmarshal(legacy.Thresholds, &graph) != nil || len(graph) == 0 {
+		return nil
+	}
+	graph = slices.DeleteFunc(graph, func(t legacyGraphThreshold) bool { return t.Value == nil })
+	slices.SortStableFunc(graph, func(a, b legacyGraphThreshold) int {
+		return cmp.Compare(*a.Value, *b.Value)
+	})
+
+	thresholds := &Thresholds{Mode: "absolute", Steps: []ThresholdStep{{Color: "transparent"}}}
+	for _, t := range graph {
+		color := graphThresholdColors[t.ColorMode]
+		if color == "" {
+			color = cmp.Or(t.FillColor, t.LineColor, "red")
+		}
+		if t.Op == "lt" {
+			thresholds.Steps[len(thresholds.Steps)-1].Color = color
+			thresholds.Steps = append(thresholds.Steps, ThresholdStep{Color: "transparent", Value: t.Value})
+			continue
+		}
+		thresholds.Steps = append(thresholds.Steps, ThresholdStep{Color: color, Value: t.Value})
+	}
+	return thresholds
+}
+
+// singlestatDefaults adds the unit, decimals, gauge range and value mappings
+// of a singlestat panel to its field defaults. A value map of "null" becomes
+// a special mapping, as Grafana does when it migrates singlestat panels.
+func singlestatDefaults(defaults FieldDefaults, legacy legacyPanel) FieldDefaults {
+	defaults.Unit = legacy.Format
+	defaults.Decimals = cmp.Or(defaults.Decimals, legacy.Decimals)
+	if legacy.Gauge.Show {
+		defaults.Min = cmp.Or(defaults.Min, legacy.Gauge.MinValue)
+		defaults.Max = cmp.Or(defaults.Max, legacy.Gauge.MaxValue)
+	}
+	if len(defaults.Mappings) > 0 {
+		return defaults
+	}
+	for _, m := range legacy.ValueMaps {
+		if m.Value == "null" {
+			defaults.Mappings = append(defaults.Mappings, ValueMapping{Type: "special", Match: "null", Text: m.Text})
+			continue
+		}
+		defaults.Mappings = append(defaults.Mappings, ValueMapping{Type: "value", Match: m.Value, Text: m.Text})
+	}
+	for _, m := range legacy.RangeMaps {
+		defaults.Mappings = append(defaults.Mappings, ValueMapping{Type: "range", From: parseFloat(m.From), To: parseFloat(m.To), Text: m.Text})
+	}
+	return defaults
+}
+
+// graphDefaults adds the unit, decimals and range of the left y axis of a
+// graph panel to its field defaults.
+func graphDefaults(defaults FieldDefaults, legacy legacyPanel) FieldDefaults {
+	axis := legacy.YAxes[0]
+	defaults.Unit = axis.Format
+	defaults.Decimals = cmp.Or(defaults.Decimals, axis.Decimals, legacy.Decimals)
+	defaults.Min = cmp.Or(defaults.Min, axisBound(axis.Min))
+	defaults.Max = cmp.Or(defaults.Max, axisBound(axis.Max))
+	return defaults
+}
+
+// axisBound decodes the minimum or maximum of a graph axis, written as a
+// number or a string, returning nil if the axis has no bound.
+func axisBound(raw json.RawMessage) *float64 {
+	var bound any
+	if json.Unmarshal(raw, &bound) != nil {
+		return nil
+	}
+	switch b := bound.(type) {
+	case float64:
+		return &b
+	case string:
+		return parseFloat(b)
+	}
+	return nil
+}
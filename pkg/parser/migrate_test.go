@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrateLegacyDashboard(t *testing.T) {
+	doc, err := BuildDocumentation("testdata/legacy_dashboard.json", Options{})
+	assert.NoError(t, err)
+
+	var titles [][]string
+	for _, row := range doc.Rows {
+		rowTitles := []string{row.Title}
+		for _, panel := range row.Panels {
+			rowTitles = append(rowTitles, panel.Title+" ("+panel.Type+")")
+		}
+		titles = append(titles, rowTitles)
+	}
+	assert.Equal(t, [][]string{
+		{"Overview", "Error Ratio (timeseries)", "Availability (gauge)", "Runbook (text)"},
+		{"Details", "Mixed (timeseries)"},
+	}, titles)
+	assert.True(t, doc.Rows[1].Collapsed)
+
+	panels := doc.Panels()
+	assert.Equal(t, "transparent, red above 0.5", panels[0].FieldConfig.Defaults.Thresholds.String())
+	assert.Equal(t, "red, orange above 0.99, green above 0.999", panels[1].FieldConfig.Defaults.Thresholds.String())
+	assert.Equal(t, []string{"http_requests_total"}, panels[0].Metrics)
+	assert.Equal(t, &Datasource{UID: "Prometheus"}, panels[0].Targets[0].Datasource, "targets should inherit the datasource of their panel")
+	assert.Equal(t, "datasource", panels[3].Targets[1].DatasourceType, "built-in datasource names should be migrated")
+
+	var notes, errors []Diagnostic
+	for _, diag := range doc.Diagnostics {
+		switch {
+		case diag.Code == CodeDeprecatedPanel:
+			notes = append(notes, diag)
+		case diag.Severity == SeverityError:
+			errors = append(errors, diag)
+		}
+	}
+	assert.Len(t, notes, 3)
+	assert.Equal(t, "deprecated graph panel documented as timeseries", notes[0].Message)
+	assert.Equal(t, "/rows/0/panels/0/type", notes[0].Pointer)
+	assert.Equal(t, 15, notes[0].Line)
+	assert.Len(t, errors, 1)
+	assert.Equal(t, "/rows/1/panels/0/targets/0/expr", errors[0].Pointer)
+	assert.Equal(t, 68, errors[0].Line)
+}
+
+func TestUpgradeRows(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    string
+		expected []RowPanel
+	}{
+		{
+			name: "rows without visible titles should place their panels on the grid",
+			model: `{"schemaVersion": 10, "rows": [
+				{"height": 300, "panels": [{"id": 1, "type": "stat", "span": 6}, {"id": 2, "type": "stat", "span": 6}, {"id": 3, "type": "stat"}]},
+				{"panels": [{"id": 4, "type": "stat", "span": 12, "height": "100px"}]}
+			]}`,
+			expected: []RowPanel{
+				{Panel: Panel{ID: 1, Type: "stat", GridPos: GridPos{H: 8, W: 12, X: 0, Y: 0}}},
+				{Panel: Panel{ID: 2, Type: "stat", GridPos: GridPos{H: 8, W: 12, X: 12, Y: 0}}},
+				{Panel: Panel{ID: 3, Type: "stat", GridPos: GridPos{H: 8, W: 8, X: 0, Y: 8}}},
+				{Panel: Panel{ID: 4, Type: "stat", GridPos: GridPos{H: 3, W: 24, X: 0, Y: 16}}},
+			},
+		}, {
+			name: "rows with a visible title should become row panels",
+			model: `{"schemaVersion": 10, "rows": [
+				{"title": "A", "showTitle": true, "panels": [{"id": 1, "type": "stat", "span": 12}]},
+				{"title": "B", "collapse": true, "panels": [{"id": 2, "type": "stat", "span": 12}]}
+			]}`,
+			expected: []RowPanel{
+				{Panel: Panel{ID: 3, Type: "row", Title: "A", GridPos: GridPos{H: 1, W: 24, Y: 0}}},
+				{Panel: Panel{ID: 1, Type: "stat", GridPos: GridPos{H: 7, W: 24, X: 0, Y: 1}}},
+				{Panel: Panel{ID: 4, Type: "row", Title: "B", GridPos: GridPos{H: 1, W: 24, Y: 8}}, Collapsed: true, Panels: []Panel{
+					{ID: 2, Type: "stat", GridPos: GridPos{H: 7, W: 24, X: 0, Y: 9}},
+				}},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var dash Dashboard
+			assert.NoError(t, json.Unmarshal([]byte(tc.model), &dash))
+			dash.migrate(json.RawMessage(tc.model))
+			for i := range dash.Panels {
+				dash.Panels[i].pointer = ""
+				for j := range dash.Panels[i].Panels {
+					dash.Panels[i].Panels[j].pointer = ""
+				}
+			}
+			assert.Equal(t, tc.expected, dash.Panels)
+		})
+	}
+}
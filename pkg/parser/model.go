@@ -0,0 +1,200 @@
+package parser
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+)
+
+// DocumentationSchemaVersion is the version of the documentation model
+// serialized by the json and yaml output formats. It is incremented on every
+// backwards incompatible change of the model; the matching JSON Schema is
+// published in the schema directory of the repository.
+const DocumentationSchemaVersion = "1"
+
+// DashboardDoc is the documentation model of a dashboard. It is built once per
+// dashboard file and rendered into every output format. Unlike MarkdownData,
+// its values are not escaped for any output format.
+type DashboardDoc struct {
+	// SchemaVersion is the version of the documentation model, see DocumentationSchemaVersion
+	SchemaVersion string `json:"schemaVersion"`
+	// File is the path of the dashboard file
+	File string `json:"file"`
+	// UID is the dashboard UID
+	UID string `json:"uid,omitempty"`
+	// Title is the dashboard title
+	Title string `json:"title"`
+	// Description is the dashboard description
+	Description string `json:"description,omitempty"`
+	// Tags contains the dashboard tags
+	Tags []string `json:"tags,omitempty"`
+	// Links contains the dashboard links
+	Links []Link `json:"links,omitempty"`
+	// Inputs contains the inputs asked for when importing a dashboard exported
+	// for sharing externally
+	Inputs []DashboardInput `json:"inputs,omitempty"`
+	// Requires contains the plugins and Grafana version required by a
+	// dashboard exported for sharing externally
+	Requires []DashboardRequirement `json:"requires,omitempty"`
+	// Rows contains the dashboard rows in on-screen order, each holding the
+	// panels displayed under it. Panels above the first row are grouped in a
+	// leading row with an empty title.
+	Rows []RowDoc `json:"rows"`
+	// Variables contains the dashboard template variables
+	Variables []VariableDoc `json:"variables,omitempty"`
+	// Diagnostics contains the problems found while documenting the dashboard
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+}
+
+// Panels returns the documented panels of every row in on-screen order.
+func (d *DashboardDoc) Panels() []PanelDoc {
+	var panels []PanelDoc
+	for _, row := range d.Rows {
+		panels = append(panels, row.Panels...)
+	}
+	return panels
+}
+
+// RowDoc is the documentation model of a dashboard row.
+type RowDoc struct {
+	// Title is the row title, empty for panels placed above the first row
+	Title string `json:"title,omitempty"`
+	// Collapsed indicates whether the row is collapsed in the dashboard
+	Collapsed bool `json:"collapsed"`
+	// Panels contains the row's panels in on-screen order
+	Panels []PanelDoc `json:"panels"`
+}
+
+// PanelDoc is the documentation model of a panel. The embedded QueryEntities
+// hold the unique entities read by all of the panel's targets.
+type PanelDoc struct {
+	// ID is the panel ID
+	ID int `json:"id"`
+	// Title is the panel title
+	Title string `json:"title"`
+	// Description is the panel description
+	Description string `json:"description,omitempty"`
+	// Type is the panel type (e.g., "timeseries", "stat", "table")
+	Type string `json:"type"`
+	// Datasource is the panel datasource, if any
+	Datasource *Datasource `json:"datasource,omitempty"`
+	// LibraryPanel is the library panel the panel was resolved from, if any
+	LibraryPanel *LibraryPanelRef `json:"libraryPanel,omitempty"`
+	// Targets contains the panel's query targets
+	Targets []TargetDoc `json:"targets,omitempty"`
+	// Transformations contains the enabled transformations applied, in
+	// order, to the query results before they are visualized
+	Transformations []TransformationDoc `json:"transformations,omitempty"`
+	// FieldConfig describes how the panel displays its values, if it sets
+	// any display option
+	FieldConfig *FieldConfigDoc `json:"fieldConfig,omitempty"`
+	QueryEntities
+}
+
+// Pipeline returns the steps the panel's data goes through: its queries, its
+// transformations and its visualization, e.g. ["queries A, B", "merge the
+// query results into one table", "table"]. It returns nil for panels without
+// transformations, whose data is visualized as queried.
+func (p PanelDoc) Pipeline() []string {
+	if len(p.Transformations) == 0 {
+		return nil
+	}
+	var steps []string
+	if len(p.Targets) > 0 {
+		refs := make([]string, 0, len(p.Targets))
+		for i, target := range p.Targets {
+			refs = append(refs, cmp.Or(target.RefID, fmt.Sprintf("#%d", i+1)))
+		}
+		label := "queries"
+		if len(refs) == 1 {
+			label = "query"
+		}
+		steps = append(steps, label+" "+strings.Join(refs, ", "))
+	}
+	for _, t := range p.Transformations {
+		steps = append(steps, t.Description)
+	}
+	return append(steps, p.Type)
+}
+
+// thresholds returns the thresholds of the panel's field configuration
+// defaults, or nil if it has none.
+func (p PanelDoc) thresholds() *Thresholds {
+	if p.FieldConfig == nil {
+		return nil
+	}
+	return p.FieldConfig.Defaults.Thresholds
+}
+
+// TransformationDoc is the documentation model of a transformation of a
+// panel's query results.
+type TransformationDoc struct {
+	// ID is the transformation type, e.g. "merge" or "organize"
+	ID string `json:"id"`
+	// Description is a human readable description of the transformation,
+	// e.g. `join the query results on "instance" (outer join)`
+	Description string `json:"description"`
+}
+
+// FieldConfigDoc is the documentation model of the field configuration of a
+// panel: the unit, thresholds, value mappings and other options its values
+// are displayed with.
+type FieldConfigDoc struct {
+	// Summary is a human readable description of the defaults, e.g.
+	// "seconds; red above 0.5"
+	Summary string `json:"summary,omitempty"`
+	// Defaults contains the options applied to every field
+	Defaults FieldDefaults `json:"defaults"`
+	// Overrides contains the options applied to the fields matched by an override
+	Overrides []FieldOverrideDoc `json:"overrides,omitempty"`
+}
+
+// FieldOverrideDoc is the documentation model of a field override.
+type FieldOverrideDoc struct {
+	// Matcher describes the fields the override applies to, e.g. `field "errors"`
+	Matcher string `json:"matcher"`
+	// Summary is a human readable description of the override's options
+	Summary string `json:"summary"`
+	// Properties contains the options set by the override
+	Properties FieldDefaults `json:"properties"`
+}
+
+// TargetDoc is the documentation model of a query target. The embedded
+// QueryEntities hold the entities read by the target's query.
+type TargetDoc struct {
+	// RefID is the target reference ID, e.g. A
+	RefID string `json:"refId,omitempty"`
+	// DatasourceType is the resolved datasource plugin type of the target
+	DatasourceType string `json:"datasourceType"`
+	// Datasource is the datasource the target declares, if any
+	Datasource *Datasource `json:"datasource,omitempty"`
+	// Query is the raw query text of the target
+	Query string `json:"query,omitempty"`
+	// Error describes why the query could not be parsed, if it couldn't
+	Error string `json:"error,omitempty"`
+	QueryEntities
+}
+
+// VariableDoc is the documentation model of a dashboard template variable.
+type VariableDoc struct {
+	// Name is the variable name without the leading $
+	Name string `json:"name"`
+	// Label is the display label shown in the dashboard
+	Label string `json:"label,omitempty"`
+	// Type is the variable type (e.g., "query", "custom", "interval")
+	Type string `json:"type"`
+	// Datasource is the variable's datasource, if any
+	Datasource *Datasource `json:"datasource,omitempty"`
+	// Query is the query or definition used to populate the variable
+	Query string `json:"query,omitempty"`
+	// Current is the default (currently selected) value of the variable
+	Current string `json:"current,omitempty"`
+	// Multi indicates whether multiple values can be selected
+	Multi bool `json:"multi"`
+	// IncludeAll indicates whether an "All" option is offered
+	IncludeAll bool `json:"includeAll"`
+	// Regex is the regex used to filter or capture the variable values
+	Regex string `json:"regex,omitempty"`
+	// Metrics contains the metric names referenced by the variable query
+	Metrics []string `json:"metrics,omitempty"`
+}
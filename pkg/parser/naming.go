@@ -0,0 +1,168 @@
+package parser
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/rastogiji/autodoc-grafana/pkg/utils"
+)
+
+// Naming is a scheme naming the documentation file of a dashboard.
+type Naming string
+
+const (
+	// NamingBasename names the documentation after the dashboard file, e.g.
+	// overview.md for overview.json
+	NamingBasename Naming = "basename"
+	// NamingUID names the documentation after the dashboard UID
+	NamingUID Naming = "uid"
+	// NamingTitle names the documentation after the slugified dashboard title
+	NamingTitle Naming = "title"
+)
+
+// uidSyntax matches the characters Grafana allows in a dashboard UID, which
+// are all safe in a file name.
+var uidSyntax = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// IsValid reports whether n is a supported naming scheme.
+func (n Naming) IsValid() bool {
+	return n == NamingBasename || n == NamingUID || n == NamingTitle
+}
+
+// Name returns the name of the documentation file of a dashboard, without
+// extension. An empty naming scheme names it after the dashboard file.
+//
+// Returns an error if the dashboard has no UID, or no title with letters or
+// digits, to be named after, or if its UID has characters Grafana does not
+// allow, such as / or .., which could name a file outside the output directory.
+func (n Naming) Name(doc *DashboardDoc) (string, error) {
+	switch n {
+	case NamingUID:
+		if doc.UID == "" {
+			return "", fmt.Errorf("dashboard %s has no uid to name its documentation after", doc.File)
+		}
+		if !uidSyntax.MatchString(doc.UID) {
+			return "", fmt.Errorf("dashboard %s has an invalid uid %q to name its documentation after: only letters, digits, - and _ are allowed", doc.File, doc.UID)
+		}
+		return doc.UID, nil
+	case NamingTitle:
+		slug := utils.Slugify(doc.Title)
+		if slug == "" {
+			return "", fmt.Errorf("dashboard %s has no title to name its documentation after", doc.File)
+		}
+		return slug, nil
+	default:
+		base := filepath.Base(doc.File)
+		return strings.TrimSuffix(base, filepath.Ext(base)), nil
+	}
+}
+
+// DocumentationPath returns the path of the documentation file of a dashboard
+// in a directory, named with opts.Naming and the extension of opts.Format.
+//
+// Returns an error if the dashboard cannot be named with the naming scheme.
+func DocumentationPath(doc *DashboardDoc, dir string, opts Options) (string, error) {
+	name, err := opts.Naming.Name(doc)
+	if err != nil {
+		return "", err
+	}
+	format := cmp.Or(opts.Format, FormatMarkdown)
+	return filepath.Join(dir, name+format.Extension()), nil
+}
+
+// IsDocumentationFile reports whether a file is documentation written in the
+// json or yaml format, e.g. overview.doc.json, rather than a dashboard, so
+// that inputs can skip the documentation of a previous run.
+func IsDocumentationFile(path string) bool {
+	name := strings.ToLower(filepath.Base(path))
+	return strings.HasSuffix(name, FormatJSON.Extension()) || strings.HasSuffix(name, FormatYAML.Extension())
+}
+
+// sameFile reports whether two paths name the same file: the same absolute
+// path, or, for existing files, the same file on disk through a symlink or a
+// case-insensitive file system.
+func sameFile(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA == nil && errB == nil && absA == absB {
+		return true
+	}
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(infoA, infoB)
+}
+
+// Output is a documentation file to be written for a dashboard.
+type Output struct {
+	// Doc is the documentation model of the dashboard
+	Doc *DashboardDoc
+	// Path is the path of the documentation file
+	Path string
+}
+
+// DetectCollisions checks that no two dashboards are documented in the same
+// file, and that no documentation file is one of the input files, so that a
+// run never silently overwrites documentation or a dashboard. Paths are
+// compared case-insensitively, as on the default macOS and Windows file
+// systems.
+//
+// Parameters:
+//   - outputs: the documentation files to be written
+//   - inputs: the paths of the dashboard files read by the run
+//
+// Returns an error listing every colliding file and its dashboards.
+func DetectCollisions(outputs []Output, inputs []string) error {
+	files := make(map[string][]string)
+	paths := make(map[string]string)
+	for _, output := range outputs {
+		key := collisionKey(output.Path)
+		if _, ok := paths[key]; !ok {
+			paths[key] = output.Path
+		}
+		files[key] = append(files[key], output.Doc.File)
+	}
+
+	var overwrites []error
+	for _, input := range inputs {
+		key := collisionKey(input)
+		if _, ok := files[key]; ok {
+			slog.Error("documentation file is an input file", slog.String("documentation-file", paths[key]), slog.String("input-file", input))
+			overwrites = append(overwrites, fmt.Errorf("%s would overwrite the dashboard %s", paths[key], input))
+		}
+	}
+	var overwriteErr error
+	if len(overwrites) > 0 {
+		overwriteErr = fmt.Errorf("%d documentation files would overwrite a dashboard: %w", len(overwrites), errors.Join(overwrites...))
+	}
+
+	var errs []error
+	for _, key := range slices.Sorted(maps.Keys(files)) {
+		if len(files[key]) < 2 {
+			continue
+		}
+		slices.Sort(files[key])
+		slog.Error("documentation file collision", slog.String("documentation-file", paths[key]), slog.Any("files", files[key]))
+		errs = append(errs, fmt.Errorf("%s would document %s", paths[key], strings.Join(files[key], ", ")))
+	}
+	if len(errs) > 0 {
+		return errors.Join(overwriteErr, fmt.Errorf("%d documentation files would be written for several dashboards: %w", len(errs), errors.Join(errs...)))
+	}
+	return overwriteErr
+}
+
+// collisionKey returns the key two paths naming the same file share: the
+// lowercased absolute path, or the lowercased cleaned path if it has none.
+func collisionKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return strings.ToLower(filepath.Clean(path))
+}
@@ -0,0 +1,117 @@
+package parser
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocumentationPath(t *testing.T) {
+	doc := &DashboardDoc{File: "dashboards/teamA/Overview.JSON", UID: "team-a-overview", Title: "Team A / Overview"}
+
+	tests := []struct {
+		name        string
+		doc         *DashboardDoc
+		opts        Options
+		expected    string
+		expectError bool
+		errorMsg    string
+	}{
+		{
+			name:     "default naming should use the dashboard file name",
+			doc:      doc,
+			expected: filepath.Join("docs", "Overview.md"),
+		},
+		{
+			name:     "uid naming should use the dashboard uid",
+			doc:      doc,
+			opts:     Options{Naming: NamingUID, Format: FormatYAML},
+			expected: filepath.Join("docs", "team-a-overview.doc.yaml"),
+		},
+		{
+			name:     "title naming should use the slugified dashboard title",
+			doc:      doc,
+			opts:     Options{Naming: NamingTitle},
+			expected: filepath.Join("docs", "team-a-overview.md"),
+		},
+		{
+			name:        "uid naming without uid should return error",
+			doc:         &DashboardDoc{File: "overview.json", Title: "Overview"},
+			opts:        Options{Naming: NamingUID},
+			expectError: true,
+			errorMsg:    "dashboard overview.json has no uid to name its documentation after",
+		},
+		{
+			name:        "uid naming with a path in the uid should return error",
+			doc:         &DashboardDoc{File: "overview.json", UID: "../../x"},
+			opts:        Options{Naming: NamingUID},
+			expectError: true,
+			errorMsg:    `dashboard overview.json has an invalid uid "../../x" to name its documentation after`,
+		},
+		{
+			name:        "uid naming with a directory in the uid should return error",
+			doc:         &DashboardDoc{File: "overview.json", UID: "a/b"},
+			opts:        Options{Naming: NamingUID},
+			expectError: true,
+			errorMsg:    `dashboard overview.json has an invalid uid "a/b" to name its documentation after`,
+		},
+		{
+			name:        "title naming without title should return error",
+			doc:         &DashboardDoc{File: "overview.json", Title: " / "},
+			opts:        Options{Naming: NamingTitle},
+			expectError: true,
+			errorMsg:    "dashboard overview.json has no title to name its documentation after",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path, err := DocumentationPath(tc.doc, "docs", tc.opts)
+			if tc.expectError {
+				assert.ErrorContains(t, err, tc.errorMsg)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, path)
+		})
+	}
+}
+
+func TestDetectCollisions(t *testing.T) {
+	a := &DashboardDoc{File: "teamA/overview.json"}
+	b := &DashboardDoc{File: "teamB/overview.json"}
+	c := &DashboardDoc{File: "teamC/Overview.json"}
+
+	t.Run("distinct paths should not collide", func(t *testing.T) {
+		assert.NoError(t, DetectCollisions([]Output{
+			{Doc: a, Path: "docs/teamA/overview.md"},
+			{Doc: b, Path: "docs/teamB/overview.md"},
+		}, []string{a.File, b.File}))
+	})
+
+	t.Run("same path should collide", func(t *testing.T) {
+		err := DetectCollisions([]Output{
+			{Doc: b, Path: "docs/overview.md"},
+			{Doc: a, Path: "docs/./overview.md"},
+		}, nil)
+		assert.EqualError(t, err, "1 documentation files would be written for several dashboards: docs/overview.md would document teamA/overview.json, teamB/overview.json")
+	})
+
+	t.Run("paths differing by case only should collide", func(t *testing.T) {
+		err := DetectCollisions([]Output{
+			{Doc: a, Path: "docs/overview.md"},
+			{Doc: c, Path: "docs/Overview.md"},
+		}, nil)
+		assert.ErrorContains(t, err, "docs/overview.md would document teamA/overview.json, teamC/Overview.json")
+	})
+
+	t.Run("path of an input file should be rejected", func(t *testing.T) {
+		err := DetectCollisions([]Output{
+			{Doc: a, Path: "teamA/overview.doc.json"},
+			{Doc: b, Path: "./teamB/../teamA/Overview.doc.json"},
+		}, []string{a.File, b.File, "teamA/overview.doc.json"})
+		assert.EqualError(t, err, "1 documentation files would overwrite a dashboard: teamA/overview.doc.json would overwrite the dashboard teamA/overview.doc.json\n"+
+			"1 documentation files would be written for several dashboards: teamA/overview.doc.json would document teamA/overview.json, teamB/overview.json")
+	})
+}
@@ -4,18 +4,52 @@
 package parser
 
 import (
+	"bytes"
+	"cmp"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
-	"path/filepath"
 	"strings"
+	"text/template"
 
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/promql/parser"
-	"github.com/rastogiji/autodoc-grafana/pkg/templates"
 	"github.com/rastogiji/autodoc-grafana/pkg/utils"
 )
 
+// Options configures how dashboards are documented.
+type Options struct {
+	// LibraryPanels is used to resolve library panel references. It may be nil,
+	// in which case every library panel reference is reported as unresolved.
+	LibraryPanels *LibraryPanels
+	// KnownMetrics lists the metric names available in the monitoring system.
+	// Metric name patterns such as {__name__=~"node_cpu.*"} are expanded
+	// against it. It may be empty.
+	KnownMetrics []string
+	// Strict aborts documenting a dashboard on the first query that cannot be
+	// parsed. By default such problems are recorded as warnings rendered in
+	// the documentation and the rest of the dashboard is still documented.
+	Strict bool
+	// Diagnostics collects the problems found while documenting dashboards.
+	// It may be nil.
+	Diagnostics *Diagnostics
+	// Format is the output format of the documentation. It defaults to markdown.
+	Format Format
+	// Check compares the documentation with the existing files instead of
+	// writing them. It may be nil.
+	Check *Check
+	// Template replaces the default markdown template, see
+	// templates.ParseCustomTemplate. It is executed with the DashboardDoc of
+	// each dashboard and only used with the markdown format. It may be nil.
+	Template *template.Template
+	// Naming names the documentation file of each dashboard. It defaults to
+	// the name of the dashboard file.
+	Naming Naming
+}
+
 // MarkdownData represents the structured data used for generating markdown documentation
 // from a Grafana dashboard. It contains the dashboard's title, description, and
 // all panel information formatted for template processing.
@@ -24,8 +58,68 @@ type MarkdownData struct {
 	Title string
 	// Description is the dashboard description
 	Description string
-	// Panels contains all panels from the dashboard with their metadata and metrics
+	// Panels contains all panels from the dashboard with their metadata and metrics,
+	// in on-screen order
 	Panels []panelData
+	// Rows contains the dashboard rows in on-screen order, each holding the
+	// panels displayed under it. Panels above the first row are grouped in a
+	// leading row with an empty title.
+	Rows []rowData
+	// Inputs contains the inputs asked for when importing a dashboard
+	// exported for sharing externally
+	Inputs []inputData
+	// Requires contains the plugins and Grafana version required by a
+	// dashboard exported for sharing externally
+	Requires []requirementData
+	// Variables contains the dashboard template variables
+	Variables []variableData
+	// Warnings contains the problems that were skipped while documenting the
+	// dashboard, e.g. queries that could not be parsed
+	Warnings []warningData
+}
+
+// inputData represents an input of a dashboard exported for sharing
+// externally, escaped for use inside a markdown table cell.
+type inputData struct {
+	// Name is the input name, referenced as ${Name} in the dashboard
+	Name string
+	// Label is the label shown on import
+	Label string
+	// Type is the input type: "datasource" or "constant"
+	Type string
+	// Plugin is the plugin of a datasource input, e.g. Prometheus (prometheus)
+	Plugin string
+	// Value is the default value of a constant input
+	Value string
+	// Description is the description shown on import
+	Description string
+}
+
+// requirementData represents a plugin required by a dashboard exported for
+// sharing externally, escaped for use inside a markdown table cell.
+type requirementData struct {
+	// Type is the requirement type: "grafana", "datasource" or "panel"
+	Type string
+	// ID is the plugin ID
+	ID string
+	// Name is the display name of the plugin
+	Name string
+	// Version is the plugin or Grafana version
+	Version string
+}
+
+// warningData represents a problem that was skipped while documenting a dashboard.
+type warningData struct {
+	// Location describes where the problem was found, e.g. panel "CPU", target A
+	Location string
+	// Message describes the problem
+	Message string
+}
+
+// singleLine collapses the whitespace of a message, including newlines, so
+// that it can be rendered on a single line.
+func singleLine(message string) string {
+	return strings.Join(strings.Fields(message), " ")
 }
 
 // panelData represents a single dashboard panel with its associated metadata
@@ -37,8 +131,78 @@ type panelData struct {
 	Description string
 	// Type indicates the panel type (e.g., "graph", "stat", "table")
 	Type string
+	// Values describes how the panel displays its values: the summary of the
+	// field defaults, e.g. "seconds; red above 0.5", followed by one line per
+	// field override
+	Values []string
 	// Metrics contains unique metric names extracted from the panel's PromQL queries
 	Metrics []string
+	// MetricUsages contains the unique metrics with their label matchers and
+	// grouping labels, e.g. http_requests_total{job="api"} by (le)
+	MetricUsages []metricUsageData
+	// LogQueries contains the description of the panel's LogQL queries
+	LogQueries []logQueryData
+	// Tables contains unique tables or measurements read by the panel's SQL-like queries
+	Tables []string
+	// Indices contains unique indices read by the panel's search queries
+	Indices []string
+	// Queries contains raw queries whose entities could not be extracted,
+	// e.g. for datasource types without a registered extractor
+	Queries []string
+	// Pipeline contains the steps the panel's data goes through, from its
+	// queries through its transformations to its visualization; empty for
+	// panels without transformations
+	Pipeline []string
+}
+
+// metricUsageData represents a metric usage formatted for documentation purposes.
+type metricUsageData struct {
+	// Selector is the metric selector with its grouping clauses
+	Selector string
+	// Matches contains the known metric names matched by a metric name pattern
+	Matches []string
+}
+
+// rowData represents a dashboard row and the panels displayed under it.
+type rowData struct {
+	// Title is the row title, empty for panels placed above the first row
+	Title string
+	// Collapsed indicates whether the row is collapsed in the dashboard
+	Collapsed bool
+	// Panels contains the row's panels in on-screen order
+	Panels []panelData
+}
+
+// MetricUsage describes how a PromQL query selects and aggregates a metric.
+type MetricUsage struct {
+	// Name is the metric name, either written out or taken from an equality
+	// __name__ matcher. It is empty when the metric is selected by a pattern.
+	Name string `json:"name,omitempty"`
+	// Pattern is the regex of a __name__=~"..." matcher selecting the metric
+	// when its name isn't known
+	Pattern string `json:"pattern,omitempty"`
+	// Matches contains the known metric names matched by Pattern
+	Matches []string `json:"matches,omitempty"`
+	// Matchers contains the label matchers with their operators, e.g. job=~"api|web".
+	// __name__ matchers are only kept when they don't resolve to Name.
+	Matchers []string `json:"matchers,omitempty"`
+	// Groupings contains the by/without clauses of the aggregations applied to
+	// the metric, innermost first, e.g. by (le)
+	Groupings []string `json:"groupings,omitempty"`
+}
+
+// String renders the metric usage as a PromQL-like selector followed by its
+// grouping clauses, e.g. http_requests_total{job="my-service"} by (le).
+func (m MetricUsage) String() string {
+	var b strings.Builder
+	b.WriteString(m.Name)
+	if len(m.Matchers) > 0 {
+		b.WriteString("{" + strings.Join(m.Matchers, ", ") + "}")
+	}
+	for _, grouping := range m.Groupings {
+		b.WriteString(" " + grouping)
+	}
+	return b.String()
 }
 
 // metricNameVisitor implements the prometheus parser.Visitor interface
@@ -46,6 +210,8 @@ type panelData struct {
 type metricNameVisitor struct {
 	// metricNames stores the collected metric names during AST traversal
 	metricNames []string
+	// usages stores the collected metric usages during AST traversal
+	usages []MetricUsage
 }
 
 // Visit implements the parser.Visitor interface to traverse PromQL AST nodes
@@ -54,22 +220,158 @@ type metricNameVisitor struct {
 func (v *metricNameVisitor) Visit(node parser.Node, path []parser.Node) (parser.Visitor, error) {
 	switch n := node.(type) {
 	case *parser.VectorSelector:
-		v.metricNames = append(v.metricNames, n.Name)
+		usage := newMetricUsage(n, path)
+		switch {
+		case usage.Name != "":
+			v.metricNames = append(v.metricNames, usage.Name)
+		case usage.Pattern != "":
+			v.metricNames = append(v.metricNames, usage.Pattern)
+		}
+		v.usages = append(v.usages, usage)
 	}
 	return v, nil
 }
 
+// newMetricUsage describes a vector selector together with the grouping
+// labels of the aggregations enclosing it. Selectors without a metric name,
+// such as {__name__="up"} or {__name__=~"node_cpu.*"}, take their name from
+// an equality __name__ matcher or their pattern from a regex one.
+//
+// Parameters:
+//   - selector: the vector selector selecting the metric
+//   - path: the selector's ancestors in the AST, outermost first
+//
+// Returns the metric usage of the selector.
+func newMetricUsage(selector *parser.VectorSelector, path []parser.Node) MetricUsage {
+	usage := MetricUsage{Name: selector.Name}
+	for _, m := range selector.LabelMatchers {
+		if m.Name == labels.MetricName && m.Type == labels.MatchEqual && usage.Name == "" {
+			usage.Name = m.Value
+		}
+	}
+	for _, m := range selector.LabelMatchers {
+		if m.Name == labels.MetricName {
+			if m.Type == labels.MatchEqual && m.Value == usage.Name {
+				continue
+			}
+			if m.Type == labels.MatchRegexp && usage.Name == "" && usage.Pattern == "" {
+				usage.Pattern = m.Value
+			}
+		}
+		usage.Matchers = append(usage.Matchers, m.String())
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		agg, ok := path[i].(*parser.AggregateExpr)
+		if !ok || (len(agg.Grouping) == 0 && !agg.Without) {
+			continue
+		}
+		keyword := "by"
+		if agg.Without {
+			keyword = "without"
+		}
+		usage.Groupings = append(usage.Groupings, fmt.Sprintf("%s (%s)", keyword, strings.Join(agg.Grouping, ", ")))
+	}
+	return usage
+}
+
 // CreateDocumentationFromFile processes a Grafana dashboard JSON file and generates
-// corresponding markdown documentation. It reads the dashboard file, extracts panel
-// information and metrics, and writes the formatted documentation to the output directory.
+// corresponding documentation. It reads the dashboard file, extracts panel
+// information and metrics, and writes the documentation rendered in the
+// requested format to the output directory.
+//
+// Parameters:
+//   - dashboard: path to the Grafana dashboard JSON file
+//   - outputDir: directory where the generated documentation file will be saved,
+//     named with opts.Naming
+//   - opts: options controlling how the dashboard is documented
+//
+// Returns an error if file reading, JSON parsing or rendering fails, or, in
+// strict mode, if a query cannot be parsed. Otherwise queries that cannot be
+// parsed are rendered as warnings. Every problem found is also recorded as a
+// diagnostic in opts.Diagnostics.
+func CreateDocumentationFromFile(dashboard string, outputDir string, opts Options) error {
+	doc, err := BuildDocumentation(dashboard, opts)
+	if err != nil {
+		return err
+	}
+
+	fileName, err := DocumentationPath(doc, outputDir, opts)
+	if err != nil {
+		slog.Error("error naming documentation file", slog.Any("error", err), slog.String("processing-file", dashboard))
+		return err
+	}
+	return WriteDocumentation(doc, fileName, opts)
+}
+
+// WriteDocumentation renders the documentation model of a dashboard in the
+// requested format and writes it to a file, or compares it with the file in
+// check mode.
+//
+// Parameters:
+//   - doc: the documentation model of the dashboard
+//   - fileName: path of the documentation file
+//   - opts: options controlling how the documentation is rendered and written
+//
+// Returns an error if the documentation file is the dashboard file itself,
+// or if rendering or writing fails.
+func WriteDocumentation(doc *DashboardDoc, fileName string, opts Options) error {
+	logger := slog.With(
+		slog.String("processing-file", doc.File),
+	)
+
+	if sameFile(doc.File, fileName) {
+		logger.Error("documentation file is the dashboard file", slog.String("documentation-file", fileName))
+		return fmt.Errorf("documentation file %s would overwrite its dashboard", fileName)
+	}
+
+	format := cmp.Or(opts.Format, FormatMarkdown)
+	var buf bytes.Buffer
+	if err := RenderDocumentation(&buf, doc, opts); err != nil {
+		return err
+	}
+
+	if err := writeOutput(fileName, buf.Bytes(), opts); err != nil {
+		logger.Error("error writing documentation file", slog.Any("error", err), slog.String("documentation-file", fileName))
+		return fmt.Errorf("error opening the corresponding %s file: %w", format, err)
+	}
+	return nil
+}
+
+// RenderDocumentation renders the documentation model of a dashboard in the
+// requested format, with the custom template if any.
+//
+// Parameters:
+//   - w: the writer the documentation is written to
+//   - doc: the documentation model of the dashboard
+//   - opts: options controlling how the documentation is rendered
+//
+// Returns an error if rendering fails.
+func RenderDocumentation(w io.Writer, doc *DashboardDoc, opts Options) error {
+	format := cmp.Or(opts.Format, FormatMarkdown)
+	var err error
+	if format == FormatMarkdown && opts.Template != nil {
+		err = RenderTemplate(w, doc, opts.Template)
+	} else {
+		err = Render(w, doc, format)
+	}
+	if err != nil {
+		slog.Error("error rendering documentation", slog.Any("error", err), slog.String("processing-file", doc.File), slog.String("format", string(format)))
+		return err
+	}
+	return nil
+}
+
+// BuildDocumentation reads a Grafana dashboard JSON file and builds its
+// documentation model, which can then be rendered in any output format.
 //
 // Parameters:
 //   - dashboard: path to the Grafana dashboard JSON file
-//   - outputDir: directory where the generated markdown file will be saved
+//   - opts: options controlling how the dashboard is documented
 //
-// Returns an error if file reading, JSON parsing, metric extraction, or template
-// execution fails.
-func CreateDocumentationFromFile(dashboard string, outputDir string) error {
+// Returns the documentation model and an error if file reading or JSON
+// parsing fails, or, in strict mode, if a query cannot be parsed. Every
+// problem found is recorded as a diagnostic in the model and in opts.Diagnostics.
+func BuildDocumentation(dashboard string, opts Options) (*DashboardDoc, error) {
 	logger := slog.With(
 		slog.String("processing-file", dashboard),
 	)
@@ -79,62 +381,312 @@ func CreateDocumentationFromFile(dashboard string, outputDir string) error {
 	bs, err := os.ReadFile(dashboard)
 	if err != nil {
 		logger.Error("error reading json file", slog.Any("error", err))
-		return fmt.Errorf("error reading dashboard file: %w", err)
+		return nil, fmt.Errorf("error reading dashboard file: %w", err)
 	}
+	return BuildDocumentationFromBytes(dashboard, bs, opts)
+}
 
-	var dash Dashboard
-	if err := json.Unmarshal(bs, &dash); err != nil {
+// BuildDocumentationFromBytes builds the documentation model of a Grafana
+// dashboard JSON model that was not read from a file on disk, e.g. a version
+// of a dashboard file read from git.
+//
+// Parameters:
+//   - dashboard: the name of the dashboard, used as its file in the model and
+//     in diagnostics
+//   - bs: the dashboard JSON model
+//   - opts: options controlling how the dashboard is documented
+//
+// Returns the documentation model and an error if JSON parsing fails, or, in
+// strict mode, if a query cannot be parsed.
+func BuildDocumentationFromBytes(dashboard string, bs []byte, opts Options) (*DashboardDoc, error) {
+	logger := slog.With(
+		slog.String("processing-file", dashboard),
+	)
+
+	dash, root, err := unmarshalDashboard(bs)
+	if err != nil {
 		logger.Error("error unmarshalling dashboard json", slog.Any("error", err))
-		return fmt.Errorf("error unmarshalling dashboard json: %w", err)
+		opts.Diagnostics.Add(Diagnostic{
+			File:     dashboard,
+			Line:     lineOfError(bs, err),
+			Location: "dashboard",
+			Severity: SeverityError,
+			Code:     CodeInvalidDashboard,
+			Message:  err.Error(),
+		})
+		return nil, fmt.Errorf("error unmarshalling dashboard json: %w", err)
 	}
+	dash.setPointers()
 
-	fileName := filepath.Join(outputDir, strings.TrimSuffix(filepath.Base(dashboard), ".json")+".md")
-	f, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
-	if err != nil {
-		logger.Error("error opening md file", slog.Any("error", err), slog.String("mardown-file", fileName))
-		return fmt.Errorf("error opening the corresponding markdown file: %w", err)
-	}
-	defer f.Close()
-
-	var data MarkdownData
-
-	data.Title = dash.Title
-	data.Description = dash.Description
-
-	// TODO: Refine this fairly ugly piece of code
-	for _, panel := range dash.GetPanels() {
-		var pd panelData
-		var metrics []string
-		if panel.Type != "row" {
-			pd.Title = panel.Title
-			pd.Description = strings.ReplaceAll(panel.Description, "\n", "\\n")
-			pd.Type = panel.Type
-			for _, target := range panel.Targets {
-				// Replacing these since promql parser doesn't support these grafana native vars
-				replacer := strings.NewReplacer("$__range", "1m", "$__rate_interval", "1m", "$interval", "1m")
-				tg := replacer.Replace(target.Expr)
-				allMetrics, err := extractMetricFromExpression(tg)
-				if err != nil {
-					return err
-				}
-				metrics = append(metrics, allMetrics...)
+	doc := &DashboardDoc{
+		SchemaVersion: DocumentationSchemaVersion,
+		File:          dashboard,
+		UID:           dash.UID,
+		Title:         dash.Title,
+		Description:   dash.Description,
+		Tags:          dash.Tags,
+		Links:         dash.Links,
+		Inputs:        dash.Inputs,
+		Requires:      dash.Requires,
+		Rows:          []RowDoc{},
+	}
+	var diagnostics []Diagnostic
+
+	for _, panel := range dash.resolveLibraryPanels(opts.LibraryPanels) {
+		location := "library panel " + panel.LibraryPanel.String()
+		diagnostics = append(diagnostics, newPanelDiagnostic(panel, "/libraryPanel", location, SeverityWarning,
+			CodeUnresolvedLibraryPanel, "unresolved library panel reference"))
+	}
+
+	interpolator := NewInterpolator(append(dash.inputVariables(), dash.Templating.List...))
+
+	for _, row := range dash.GetRows() {
+		rd := RowDoc{
+			Title:     row.Title,
+			Collapsed: row.Collapsed,
+			Panels:    []PanelDoc{},
+		}
+		for _, panel := range row.Panels {
+			pd, panelDiagnostics := buildPanelDoc(panel, interpolator, opts)
+			diagnostics = append(diagnostics, panelDiagnostics...)
+			rd.Panels = append(rd.Panels, pd)
+		}
+		doc.Rows = append(doc.Rows, rd)
+	}
+
+	variables, variableDiagnostics := buildVariableDocs(dash.Templating.List, interpolator)
+	diagnostics = append(diagnostics, variableDiagnostics...)
+	doc.Variables = variables
+
+	var failure error
+	var lines pointerLines
+	for i := range diagnostics {
+		diag := &diagnostics[i]
+		// diagnostics with a file are already located in it, e.g. in a library panel file
+		if diag.File == "" {
+			if lines == nil {
+				lines = newPointerLines(bs)
+			}
+			diag.File = dashboard
+			diag.Pointer = root + diag.Pointer
+			diag.Line = lines.line(diag.Pointer)
+		}
+		attrs := []any{
+			slog.String("code", diag.Code),
+			slog.String("location", diag.Location),
+			slog.String("pointer", diag.Pointer),
+			slog.String("message", diag.Message),
+		}
+		if diag.Severity == SeverityNote {
+			logger.Debug("documentation note", attrs...)
+			continue
+		}
+		logger.Warn("documentation warning", attrs...)
+		if opts.Strict && diag.Severity == SeverityError && failure == nil {
+			failure = fmt.Errorf("error documenting %s: %w", diag.Location, diag.err)
+		}
+	}
+	doc.Diagnostics = diagnostics
+	opts.Diagnostics.Add(diagnostics...)
+	if failure != nil {
+		logger.Error("error documenting dashboard in strict mode", slog.Any("error", failure))
+		return nil, failure
+	}
+	return doc, nil
+}
+
+// buildPanelDoc converts a dashboard panel into its documentation model,
+// extracting the entities read by each of the panel's queries with the
+// extractor registered for the target's datasource type.
+//
+// Parameters:
+//   - panel: the dashboard panel to document
+//   - interpolator: the interpolator used to resolve variables in the panel's queries
+//   - opts: options controlling how the dashboard is documented
+//
+// Returns the panel documentation model and the diagnostics found in the
+// panel, such as queries that cannot be parsed.
+func buildPanelDoc(panel Panel, interpolator *Interpolator, opts Options) (PanelDoc, []Diagnostic) {
+	pd := PanelDoc{
+		ID:           panel.ID,
+		Title:        panel.Title,
+		Description:  panel.Description,
+		Type:         panel.Type,
+		Datasource:   panel.Datasource,
+		LibraryPanel: panel.LibraryPanel,
+	}
+	pd.FieldConfig = newFieldConfigDoc(panel.FieldConfig)
+	pd.Transformations = newTransformationDocs(panel.Transformations)
+
+	var diagnostics []Diagnostic
+	if panel.migratedFrom != "" {
+		diagnostics = append(diagnostics, newPanelDiagnostic(panel, "/type", "", SeverityNote,
+			CodeDeprecatedPanel, fmt.Sprintf("deprecated %s panel documented as %s", panel.migratedFrom, panel.Type)))
+	}
+	if strings.TrimSpace(panel.Description) == "" {
+		diagnostics = append(diagnostics, newPanelDiagnostic(panel, "", "", SeverityNote,
+			CodeMissingDescription, "panel has no description"))
+	}
+
+	var entities QueryEntities
+	for i, target := range panel.Targets {
+		ref := target.RefID
+		if ref == "" {
+			ref = fmt.Sprintf("#%d", i+1)
+		}
+		location := fmt.Sprintf("panel %q, target %s", panel.Title, ref)
+		pointer := panel.targetPointer(i)
+
+		td := TargetDoc{
+			RefID:          target.RefID,
+			DatasourceType: resolveDatasourceType(target, panel, interpolator),
+		}
+		if target.Datasource != (Datasource{}) {
+			td.Datasource = &target.Datasource
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(target.GetRaw(), &fields); err == nil {
+			td.Query = rawQuery(fields)
+		}
+
+		if !hasExtractor(td.DatasourceType) {
+			diagnostics = append(diagnostics, newPanelDiagnostic(panel, pointer, location, SeverityNote,
+				CodeUnknownDatasource, fmt.Sprintf("no query extractor for datasource type %q, documenting the raw query", td.DatasourceType)))
+		}
+		extracted, err := GetExtractor(td.DatasourceType).Extract(target.GetRaw(), interpolator)
+		if err != nil {
+			var queryErr *QueryError
+			if errors.As(err, &queryErr) && queryErr.Field != "" {
+				pointer += "/" + queryErr.Field
+			}
+			td.Error = singleLine(err.Error())
+			diag := newPanelDiagnostic(panel, pointer, location, SeverityError, CodeQueryParseError, td.Error)
+			diag.err = err
+			diagnostics = append(diagnostics, diag)
+			pd.Targets = append(pd.Targets, td)
+			continue
+		}
+		for j, usage := range extracted.MetricUsages {
+			if usage.Pattern != "" {
+				extracted.MetricUsages[j].Matches = expandMetricPattern(usage.Pattern, opts.KnownMetrics)
+				extracted.Metrics = append(extracted.Metrics, extracted.MetricUsages[j].Matches...)
 			}
-			uniqueMetrics := utils.GetUniqueElements(metrics)
-			pd.Metrics = uniqueMetrics
+		}
+		td.QueryEntities = extracted
+		pd.Targets = append(pd.Targets, td)
+		entities.merge(extracted)
+	}
+
+	seen := make(map[string]bool)
+	for _, usage := range entities.MetricUsages {
+		if !seen[usage.String()] {
+			seen[usage.String()] = true
+			pd.MetricUsages = append(pd.MetricUsages, usage)
+		}
+	}
+	pd.Metrics = utils.GetUniqueElements(entities.Metrics)
+	pd.LogStreams = utils.GetUniqueElements(entities.LogStreams)
+	pd.LogQueries = entities.LogQueries
+	pd.Tables = utils.GetUniqueElements(entities.Tables)
+	pd.Indices = utils.GetUniqueElements(entities.Indices)
+	pd.Queries = utils.GetUniqueElements(entities.Queries)
+
+	return pd, diagnostics
+}
+
+// newMarkdownData converts a dashboard documentation model into the data
+// rendered by the markdown template, escaping values for markdown tables.
+func newMarkdownData(doc *DashboardDoc) MarkdownData {
+	data := MarkdownData{
+		Title:       doc.Title,
+		Description: doc.Description,
+	}
+	for _, row := range doc.Rows {
+		rd := rowData{
+			Title:     row.Title,
+			Collapsed: row.Collapsed,
+		}
+		for _, panel := range row.Panels {
+			pd := newPanelData(panel)
+			rd.Panels = append(rd.Panels, pd)
 			data.Panels = append(data.Panels, pd)
 		}
+		data.Rows = append(data.Rows, rd)
+	}
+	for _, input := range doc.Inputs {
+		data.Inputs = append(data.Inputs, newInputData(input))
+	}
+	for _, req := range doc.Requires {
+		data.Requires = append(data.Requires, requirementData{
+			Type:    req.Type,
+			ID:      escapeTableCell(req.ID),
+			Name:    escapeTableCell(req.Name),
+			Version: escapeTableCell(req.Version),
+		})
+	}
+	for _, v := range doc.Variables {
+		data.Variables = append(data.Variables, newVariableData(v))
+	}
+	for _, diag := range doc.Diagnostics {
+		if diag.Severity != SeverityNote {
+			data.Warnings = append(data.Warnings, warningData{Location: diag.Location, Message: diag.Message})
+		}
+	}
+	return data
+}
 
+// newInputData converts an input of a dashboard exported for sharing
+// externally into its markdown representation.
+func newInputData(input DashboardInput) inputData {
+	plugin := input.PluginID
+	if input.PluginName != "" && input.PluginID != "" {
+		plugin = fmt.Sprintf("%s (%s)", input.PluginName, input.PluginID)
 	}
-	tmpl, err := templates.GetTemplate()
-	if err != nil {
-		return err
+	return inputData{
+		Name:        input.Name,
+		Label:       escapeTableCell(input.Label),
+		Type:        input.Type,
+		Plugin:      escapeTableCell(plugin),
+		Value:       escapeTableCell(input.Value),
+		Description: escapeTableCell(input.Description),
 	}
-	err = tmpl.Execute(f, data)
-	if err != nil {
-		logger.Error("error executing markdown template", slog.Any("error", err))
-		return fmt.Errorf("error executing markdown template: %w", err)
+}
+
+// newPanelData converts a panel documentation model into its markdown
+// representation, escaping every value for use inside a markdown table cell.
+func newPanelData(panel PanelDoc) panelData {
+	pd := panelData{
+		Title:       panel.Title,
+		Description: strings.ReplaceAll(panel.Description, "\n", "\\n"),
+		Type:        panel.Type,
+		Metrics:     panel.Metrics,
+		Tables:      panel.Tables,
+		Indices:     panel.Indices,
 	}
-	return nil
+	if fc := panel.FieldConfig; fc != nil {
+		if fc.Summary != "" {
+			pd.Values = append(pd.Values, escapeTableCell(fc.Summary))
+		}
+		for _, override := range fc.Overrides {
+			pd.Values = append(pd.Values, escapeTableCell(override.Matcher+": "+override.Summary))
+		}
+	}
+	for _, usage := range panel.MetricUsages {
+		pd.MetricUsages = append(pd.MetricUsages, metricUsageData{
+			Selector: escapeTableCell(usage.String()),
+			Matches:  usage.Matches,
+		})
+	}
+	for _, q := range panel.LogQueries {
+		pd.LogQueries = append(pd.LogQueries, newLogQueryData(q))
+	}
+	for _, query := range panel.Queries {
+		pd.Queries = append(pd.Queries, escapeTableCell(query))
+	}
+	for _, step := range panel.Pipeline() {
+		pd.Pipeline = append(pd.Pipeline, escapeTableCell(step))
+	}
+	return pd
 }
 
 // extractMetricFromExpression parses a PromQL expression and extracts all metric names
@@ -146,12 +698,37 @@ func CreateDocumentationFromFile(dashboard string, outputDir string) error {
 //
 // Returns a slice of metric names and an error if parsing fails.
 func extractMetricFromExpression(expr string) ([]string, error) {
+	p, err := parseExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+	return extractMetrics(p), nil
+}
+
+// extractMetricUsagesFromExpression parses a PromQL expression and describes
+// every metric it selects with its label matchers and grouping labels.
+//
+// Parameters:
+//   - expr: the PromQL expression string to parse
+//
+// Returns a slice of metric usages and an error if parsing fails.
+func extractMetricUsagesFromExpression(expr string) ([]MetricUsage, error) {
+	p, err := parseExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+	return extractMetricUsages(p), nil
+}
+
+// parseExpression parses a PromQL expression, wrapping any parse error. The
+// error is not logged here: callers report it as a diagnostic, or fail in
+// strict mode.
+func parseExpression(expr string) (parser.Expr, error) {
 	p, err := parser.ParseExpr(expr)
 	if err != nil {
-		slog.Error("error parsing promql expression", slog.Any("error", err), slog.Any("expr", expr))
 		return nil, fmt.Errorf("error parsing promql expression: %w", err)
 	}
-	return extractMetrics(p), nil
+	return p, nil
 }
 
 // extractMetrics traverses a PromQL AST node and extracts all metric names
@@ -167,3 +744,16 @@ func extractMetrics(node parser.Node) []string {
 	parser.Walk(v, node, nil)
 	return v.metricNames
 }
+
+// extractMetricUsages traverses a PromQL AST node and describes every metric
+// selected in it using the metricNameVisitor.
+//
+// Parameters:
+//   - node: the root PromQL AST node to traverse
+//
+// Returns a slice of metric usages found in the AST.
+func extractMetricUsages(node parser.Node) []MetricUsage {
+	v := &metricNameVisitor{}
+	parser.Walk(v, node, nil)
+	return v.usages
+}
@@ -1,7 +1,9 @@
 package parser
 
 import (
+	"encoding/json"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -18,22 +20,44 @@ func TestCreateDocumentationFromFile(t *testing.T) {
 		filename     string
 		expectError  bool
 		outputDir    string
+		strict       bool
 		errorMessage string
 	}{
 		{
 			name:        "valid json file should create the correct markdown file. no errors",
 			filename:    "testdata/valid_dashboard.json",
 			expectError: false,
+		}, {
+			name:        "dashboard with template variables should document them. no errors",
+			filename:    "testdata/variables_dashboard.json",
+			expectError: false,
+		}, {
+			name:        "dashboard with rows should document panels per row. no errors",
+			filename:    "testdata/rows_dashboard.json",
+			expectError: false,
+		}, {
+			name:        "dashboard with non prometheus datasources should document them. no errors",
+			filename:    "testdata/mixed_datasources_dashboard.json",
+			expectError: false,
+		}, {
+			name:        "dashboard with loki panels should document log streams. no errors",
+			filename:    "testdata/loki_dashboard.json",
+			expectError: false,
 		}, {
 			name:         "empty file. should return error",
 			filename:     "testdata/empty_dashboard.json",
 			expectError:  true,
 			errorMessage: "error unmarshalling dashboard json: unexpected end of JSON input",
 		}, {
-			name:         "bad query in panel should return error",
+			name:         "bad query in panel should return error in strict mode",
 			filename:     "testdata/bad_query.json",
+			strict:       true,
 			expectError:  true,
 			errorMessage: "error parsing promql expression",
+		}, {
+			name:        "bad query in panel should be documented as a warning by default. no errors",
+			filename:    "testdata/bad_query.json",
+			expectError: false,
 		}, {
 			name:         "bad json schema should return error",
 			filename:     "testdata/bad_schema.json",
@@ -61,7 +85,7 @@ func TestCreateDocumentationFromFile(t *testing.T) {
 			} else {
 				outputDir = tc.outputDir
 			}
-			err = CreateDocumentationFromFile(tc.filename, outputDir)
+			err = CreateDocumentationFromFile(tc.filename, outputDir, Options{Strict: tc.strict})
 			if tc.expectError {
 				assert.Error(t, err)
 				if tc.errorMessage != "" {
@@ -73,3 +97,226 @@ func TestCreateDocumentationFromFile(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateDocumentationFromFileWarnings(t *testing.T) {
+	outputDir := t.TempDir()
+
+	err := CreateDocumentationFromFile("testdata/bad_query.json", outputDir, Options{})
+	assert.NoError(t, err)
+
+	bs, err := os.ReadFile(filepath.Join(outputDir, "bad_query.md"))
+	assert.NoError(t, err)
+	doc := string(bs)
+
+	// panels with valid queries should still be documented
+	assert.Contains(t, doc, "`container_memory_working_set_bytes{namespace=\"default\"}`")
+	assert.Contains(t, doc, "`http_request_duration_seconds_bucket{job=\"my-service\"} by (le)`")
+	assert.Contains(t, doc, "## Warnings")
+	assert.Contains(t, doc, "- panel \"Pod Memory Usage\", target #3: error parsing promql expression")
+}
+
+func TestGetRows(t *testing.T) {
+	tests := []struct {
+		name      string
+		dashboard Dashboard
+		expected  []Row
+	}{
+		{
+			name: "dashboard without rows should return a single untitled row",
+			dashboard: Dashboard{Panels: []RowPanel{
+				{Panel: Panel{Title: "B", Type: "stat", GridPos: GridPos{X: 12, Y: 0}}},
+				{Panel: Panel{Title: "A", Type: "stat", GridPos: GridPos{X: 0, Y: 0}}},
+			}},
+			expected: []Row{
+				{Panels: []Panel{
+					{Title: "A", Type: "stat", GridPos: GridPos{X: 0, Y: 0}},
+					{Title: "B", Type: "stat", GridPos: GridPos{X: 12, Y: 0}},
+				}},
+			},
+		}, {
+			name: "panels above the first row should be grouped in an untitled row",
+			dashboard: Dashboard{Panels: []RowPanel{
+				{Panel: Panel{Title: "Row", Type: "row", GridPos: GridPos{Y: 5}}},
+				{Panel: Panel{Title: "Top", Type: "stat", GridPos: GridPos{Y: 0}}},
+				{Panel: Panel{Title: "Below", Type: "stat", GridPos: GridPos{Y: 6}}},
+			}},
+			expected: []Row{
+				{Panels: []Panel{{Title: "Top", Type: "stat", GridPos: GridPos{Y: 0}}}},
+				{Title: "Row", Panels: []Panel{{Title: "Below", Type: "stat", GridPos: GridPos{Y: 6}}}},
+			},
+		}, {
+			name: "panels without grid positions should keep their json order",
+			dashboard: Dashboard{Panels: []RowPanel{
+				{Panel: Panel{Title: "Second", Type: "stat"}},
+				{Panel: Panel{Title: "First", Type: "stat"}},
+			}},
+			expected: []Row{
+				{Panels: []Panel{{Title: "Second", Type: "stat"}, {Title: "First", Type: "stat"}}},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.dashboard.GetRows())
+		})
+	}
+}
+
+func TestGetRowsFromFile(t *testing.T) {
+	bs, err := os.ReadFile("testdata/rows_dashboard.json")
+	assert.NoError(t, err)
+
+	var dash Dashboard
+	assert.NoError(t, json.Unmarshal(bs, &dash))
+
+	var titles [][]string
+	for _, row := range dash.GetRows() {
+		panelTitles := []string{row.Title}
+		for _, panel := range row.Panels {
+			panelTitles = append(panelTitles, panel.Title)
+		}
+		titles = append(titles, panelTitles)
+	}
+
+	assert.Equal(t, [][]string{
+		{"Expanded Row", "Expanded Left", "Expanded Right"},
+		{"Collapsed Row", "Collapsed Left", "Collapsed Right"},
+		{"Trailing Row", "Notes"},
+	}, titles)
+}
+
+func TestExtractMetricUsagesFromExpression(t *testing.T) {
+	tests := []struct {
+		name     string
+		expr     string
+		expected []string
+	}{
+		{
+			name:     "bare metric should have no matchers or groupings",
+			expr:     "up",
+			expected: []string{"up"},
+		}, {
+			name:     "matchers should keep their operators",
+			expr:     `rate(http_requests_total{job=~"api|web", code!="200", path!~"/health.*", env="prod"}[5m])`,
+			expected: []string{`http_requests_total{job=~"api|web", code!="200", path!~"/health.*", env="prod"}`},
+		}, {
+			name:     "grouping labels of the enclosing aggregation should be kept",
+			expr:     `histogram_quantile(0.95, sum(rate(http_request_duration_seconds_bucket{job="my-service"}[5m])) by (le))`,
+			expected: []string{`http_request_duration_seconds_bucket{job="my-service"} by (le)`},
+		}, {
+			name:     "nested aggregations should be listed innermost first",
+			expr:     `sum without (instance) (avg by (instance, pod) (container_memory_usage_bytes))`,
+			expected: []string{"container_memory_usage_bytes by (instance, pod) without (instance)"},
+		}, {
+			name:     "aggregation without grouping should not add a grouping clause",
+			expr:     `sum(node_cpu_seconds_total{mode="user"}) / count(node_cpu_seconds_total)`,
+			expected: []string{`node_cpu_seconds_total{mode="user"}`, "node_cpu_seconds_total"},
+		}, {
+			name:     "equality __name__ matcher should provide the metric name",
+			expr:     `rate({__name__="http_requests_total", job="api"}[5m])`,
+			expected: []string{`http_requests_total{job="api"}`},
+		}, {
+			name:     "regex __name__ matcher should be kept as a pattern",
+			expr:     `sum by (mode) ({__name__=~"node_cpu.*", mode!="idle"})`,
+			expected: []string{`{__name__=~"node_cpu.*", mode!="idle"} by (mode)`},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			usages, err := extractMetricUsagesFromExpression(tc.expr)
+			assert.NoError(t, err)
+
+			var rendered []string
+			for _, usage := range usages {
+				rendered = append(rendered, usage.String())
+			}
+			assert.Equal(t, tc.expected, rendered)
+		})
+	}
+}
+
+func TestExtractMetricNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		expr     string
+		expected []string
+	}{
+		{
+			name:     "metric name should be returned",
+			expr:     `up{job="api"}`,
+			expected: []string{"up"},
+		}, {
+			name:     "equality __name__ matcher should be returned as the metric name",
+			expr:     `{__name__="up", job="api"}`,
+			expected: []string{"up"},
+		}, {
+			name:     "regex __name__ matcher should be returned as a pattern",
+			expr:     `{__name__=~"node_cpu.*"}`,
+			expected: []string{"node_cpu.*"},
+		}, {
+			name:     "negative __name__ matcher alone should not return an empty name",
+			expr:     `{__name__!="up", job="api"}`,
+			expected: nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			metrics, err := extractMetricFromExpression(tc.expr)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, metrics)
+		})
+	}
+}
+
+func TestExpandMetricPattern(t *testing.T) {
+	known := []string{"node_cpu_seconds_total", "node_cpu_guest_seconds_total", "node_memory_MemFree_bytes", "x_node_cpu"}
+	tests := []struct {
+		name     string
+		pattern  string
+		known    []string
+		expected []string
+	}{
+		{
+			name:     "pattern should match whole metric names only",
+			pattern:  "node_cpu.*",
+			known:    known,
+			expected: []string{"node_cpu_seconds_total", "node_cpu_guest_seconds_total"},
+		}, {
+			name:     "alternation should be anchored as a whole",
+			pattern:  "node_memory_MemFree_bytes|x_node_cpu",
+			known:    known,
+			expected: []string{"node_memory_MemFree_bytes", "x_node_cpu"},
+		}, {
+			name:     "invalid pattern should match nothing",
+			pattern:  "node_(cpu",
+			known:    known,
+			expected: nil,
+		}, {
+			name:     "empty metric list should match nothing",
+			pattern:  ".*",
+			expected: nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, expandMetricPattern(tc.pattern, tc.known))
+		})
+	}
+}
+
+func TestLoadMetricList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.txt")
+	err := os.WriteFile(path, []byte("# exported from /api/v1/label/__name__/values\nup\n\n  node_cpu_seconds_total  \n"), 0o644)
+	assert.NoError(t, err)
+
+	metrics, err := LoadMetricList(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"up", "node_cpu_seconds_total"}, metrics)
+
+	_, err = LoadMetricList(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,138 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"text/template"
+
+	"github.com/rastogiji/autodoc-grafana/pkg/templates"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is an output format of the documentation.
+type Format string
+
+const (
+	// FormatMarkdown renders the documentation with the markdown template
+	FormatMarkdown Format = "markdown"
+	// FormatJSON serializes the documentation model as JSON
+	FormatJSON Format = "json"
+	// FormatYAML serializes the documentation model as YAML
+	FormatYAML Format = "yaml"
+	// FormatHTML writes a static HTML site documenting every dashboard of
+	// the run, see CreateSite
+	FormatHTML Format = "html"
+)
+
+// formatExtensions maps each output format to the extension of the files it
+// writes. The json and yaml documentation is suffixed with .doc so that it
+// never overwrites a dashboard written next to it, nor is picked up as a
+// dashboard by a later run.
+var formatExtensions = map[Format]string{
+	FormatMarkdown: ".md",
+	FormatJSON:     ".doc.json",
+	FormatYAML:     ".doc.yaml",
+	FormatHTML:     ".html",
+}
+
+// Extension returns the file extension used for documentation files in the format.
+func (f Format) Extension() string {
+	return formatExtensions[f]
+}
+
+// IsValid reports whether f is a supported output format.
+func (f Format) IsValid() bool {
+	_, ok := formatExtensions[f]
+	return ok
+}
+
+// Render writes the documentation model of a dashboard in the given format.
+//
+// Parameters:
+//   - w: the writer the documentation is written to
+//   - doc: the documentation model of the dashboard
+//   - format: the output format
+//
+// Returns an error if the format is not supported or the documentation
+// cannot be rendered.
+func Render(w io.Writer, doc *DashboardDoc, format Format) error {
+	switch format {
+	case FormatMarkdown:
+		tmpl, err := templates.GetTemplate()
+		if err != nil {
+			return err
+		}
+		if err := tmpl.Execute(w, newMarkdownData(doc)); err != nil {
+			slog.Error("error executing markdown template", slog.Any("error", err))
+			return fmt.Errorf("error executing markdown template: %w", err)
+		}
+		return nil
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(doc); err != nil {
+			return fmt.Errorf("error encoding json documentation: %w", err)
+		}
+		return nil
+	case FormatYAML:
+		return renderYAML(w, doc)
+	case FormatHTML:
+		return errors.New("html output documents all dashboards as a site and cannot be rendered per dashboard")
+	default:
+		return fmt.Errorf("unsupported output format: %s", format)
+	}
+}
+
+// RenderTemplate writes the documentation model of a dashboard with a custom
+// template. Unlike the default markdown template, which is executed with
+// MarkdownData, custom templates are executed with the unescaped DashboardDoc
+// so that they can target any format; its fields are the data contract of
+// custom templates.
+//
+// Parameters:
+//   - w: the writer the documentation is written to
+//   - doc: the documentation model of the dashboard
+//   - tmpl: the custom template
+//
+// Returns an error if the template cannot be executed.
+func RenderTemplate(w io.Writer, doc *DashboardDoc, tmpl *template.Template) error {
+	if err := tmpl.Execute(w, doc); err != nil {
+		slog.Error("error executing custom template", slog.Any("error", err), slog.String("template", tmpl.Name()))
+		return fmt.Errorf("error executing custom template: %w", err)
+	}
+	return nil
+}
+
+// renderYAML writes the documentation model as YAML. The model is converted
+// through its JSON form so that both formats share the same field names and
+// field order.
+func renderYAML(w io.Writer, doc *DashboardDoc) error {
+	bs, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("error encoding yaml documentation: %w", err)
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(bs, &node); err != nil {
+		return fmt.Errorf("error encoding yaml documentation: %w", err)
+	}
+	resetYAMLStyle(&node)
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return fmt.Errorf("error encoding yaml documentation: %w", err)
+	}
+	return enc.Close()
+}
+
+// resetYAMLStyle clears the JSON flow and quoting styles of a decoded node
+// tree so that it is encoded in block style.
+func resetYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetYAMLStyle(child)
+	}
+}
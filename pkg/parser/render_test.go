@@ -0,0 +1,267 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/rastogiji/autodoc-grafana/pkg/templates"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRender(t *testing.T) {
+	doc, err := BuildDocumentation("testdata/rows_dashboard.json", Options{})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		format   Format
+		validate func(t *testing.T, output []byte)
+	}{
+		{
+			name:   "markdown should render the markdown template",
+			format: FormatMarkdown,
+			validate: func(t *testing.T, output []byte) {
+				assert.True(t, strings.HasPrefix(string(output), "# "+doc.Title+"\n"))
+				assert.Contains(t, string(output), "| Panel Name | Panel Description |")
+			},
+		}, {
+			name:   "json should serialize the versioned documentation model",
+			format: FormatJSON,
+			validate: func(t *testing.T, output []byte) {
+				var decoded DashboardDoc
+				assert.NoError(t, json.Unmarshal(output, &decoded))
+				assert.Equal(t, DocumentationSchemaVersion, decoded.SchemaVersion)
+				assert.Equal(t, doc.Title, decoded.Title)
+				assert.Equal(t, len(doc.Rows), len(decoded.Rows))
+				assert.Equal(t, doc.Panels()[0].Metrics, decoded.Panels()[0].Metrics)
+				assert.Equal(t, doc.Panels()[0].Targets[0].DatasourceType, decoded.Panels()[0].Targets[0].DatasourceType)
+			},
+		}, {
+			name:   "yaml should serialize the same model with the same field names",
+			format: FormatYAML,
+			validate: func(t *testing.T, output []byte) {
+				var decoded map[string]any
+				assert.NoError(t, yaml.Unmarshal(output, &decoded))
+				assert.Equal(t, DocumentationSchemaVersion, decoded["schemaVersion"])
+				assert.Equal(t, doc.Title, decoded["title"])
+				assert.True(t, strings.HasPrefix(string(output), "schemaVersion: \"1\"\nfile: "))
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.NoError(t, Render(&buf, doc, tc.format))
+			tc.validate(t, buf.Bytes())
+		})
+	}
+
+	t.Run("unsupported format should return error", func(t *testing.T) {
+		err := Render(&bytes.Buffer{}, doc, Format("pdf"))
+		assert.ErrorContains(t, err, "unsupported output format: pdf")
+	})
+}
+
+func TestRenderTemplate(t *testing.T) {
+	doc, err := BuildDocumentation("testdata/rows_dashboard.json", Options{})
+	assert.NoError(t, err)
+
+	tmpl, err := template.New("custom").Funcs(templates.FuncMap()).Parse(
+		`{{.Title}}{{range .Rows}}{{if .Title}}|{{.Title | slugify}}{{end}}{{end}}`)
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, RenderTemplate(&buf, doc, tmpl))
+	assert.Equal(t, "Rows Dashboard|expanded-row|collapsed-row|trailing-row", buf.String())
+
+	t.Run("template errors should be returned", func(t *testing.T) {
+		tmpl := template.Must(template.New("broken").Parse(`{{.Missing}}`))
+		err := RenderTemplate(&bytes.Buffer{}, doc, tmpl)
+		assert.ErrorContains(t, err, "error executing custom template")
+	})
+}
+
+func TestCreateDocumentationFromFileFormats(t *testing.T) {
+	outputDir := t.TempDir()
+	for _, format := range []Format{FormatJSON, FormatYAML} {
+		err := CreateDocumentationFromFile("testdata/rows_dashboard.json", outputDir, Options{Format: format})
+		assert.NoError(t, err)
+		assert.FileExists(t, filepath.Join(outputDir, "rows_dashboard"+format.Extension()))
+	}
+
+	t.Run("json documentation written next to its dashboard should match the published schema", func(t *testing.T) {
+		dir := t.TempDir()
+		dashboard, err := os.ReadFile("testdata/rows_dashboard.json")
+		assert.NoError(t, err)
+		input := filepath.Join(dir, "rows_dashboard.json")
+		assert.NoError(t, os.WriteFile(input, dashboard, 0644))
+
+		assert.NoError(t, CreateDocumentationFromFile(input, dir, Options{Format: FormatJSON}))
+
+		bs, err := os.ReadFile(input)
+		assert.NoError(t, err)
+		assert.Equal(t, string(dashboard), string(bs), "dashboard should not be overwritten")
+
+		bs, err = os.ReadFile("../../schema/documentation.v1.schema.json")
+		assert.NoError(t, err)
+		var schema map[string]any
+		assert.NoError(t, json.Unmarshal(bs, &schema))
+
+		bs, err = os.ReadFile(filepath.Join(dir, "rows_dashboard.doc.json"))
+		assert.NoError(t, err)
+		var value any
+		assert.NoError(t, json.Unmarshal(bs, &value))
+		for _, problem := range validateSchema(schema, schema, value, "") {
+			t.Error(problem)
+		}
+	})
+
+	t.Run("documentation file equal to its dashboard should return error", func(t *testing.T) {
+		dir := t.TempDir()
+		input := filepath.Join(dir, "overview.json")
+		assert.NoError(t, os.WriteFile(input, []byte(`{"title": "Overview", "panels": []}`), 0644))
+		doc, err := BuildDocumentation(input, Options{})
+		assert.NoError(t, err)
+
+		err = WriteDocumentation(doc, filepath.Join(dir, ".", "overview.json"), Options{Format: FormatJSON})
+		assert.ErrorContains(t, err, "would overwrite its dashboard")
+		bs, err := os.ReadFile(input)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"title": "Overview", "panels": []}`, string(bs))
+	})
+}
+
+func TestDocumentationSchema(t *testing.T) {
+	bs, err := os.ReadFile("../../schema/documentation.v1.schema.json")
+	assert.NoError(t, err)
+
+	var schema map[string]any
+	assert.NoError(t, json.Unmarshal(bs, &schema))
+	assert.Equal(t, DocumentationSchemaVersion, schema["properties"].(map[string]any)["schemaVersion"].(map[string]any)["const"])
+
+	files := []string{
+		"testdata/valid_dashboard.json",
+		"testdata/variables_dashboard.json",
+		"testdata/rows_dashboard.json",
+		"testdata/mixed_datasources_dashboard.json",
+		"testdata/loki_dashboard.json",
+		"testdata/library_dashboard.json",
+		"testdata/bad_query.json",
+		"testdata/api_envelope_dashboard.json",
+		"testdata/shared_dashboard.json",
+		"testdata/service_dashboard_v2.json",
+		"testdata/tabs_dashboard_v2.json",
+		"testdata/legacy_dashboard.json",
+		"testdata/field_config_dashboard.json",
+		"testdata/transformations_dashboard.json",
+	}
+	for _, file := range files {
+		t.Run(file+" should match the published schema", func(t *testing.T) {
+			library, err := LoadLibraryPanels("testdata/library_panels")
+			assert.NoError(t, err)
+			doc, err := BuildDocumentation(file, Options{LibraryPanels: library, KnownMetrics: []string{"up"}})
+			assert.NoError(t, err)
+
+			var buf bytes.Buffer
+			assert.NoError(t, Render(&buf, doc, FormatJSON))
+			var value any
+			assert.NoError(t, json.Unmarshal(buf.Bytes(), &value))
+
+			for _, problem := range validateSchema(schema, schema, value, "") {
+				t.Error(problem)
+			}
+		})
+	}
+}
+
+// validateSchema checks a decoded JSON value against the subset of JSON Schema
+// used by the published documentation schema and returns the problems found.
+func validateSchema(root, schema map[string]any, value any, path string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/$defs/")
+		return validateSchema(root, root["$defs"].(map[string]any)[name].(map[string]any), value, path)
+	}
+
+	var problems []string
+	if expected, ok := schema["const"]; ok && expected != value {
+		problems = append(problems, fmt.Sprintf("%s: expected %v, got %v", path, expected, value))
+	}
+	if enum, ok := schema["enum"].([]any); ok && !slices.Contains(enum, value) {
+		problems = append(problems, fmt.Sprintf("%s: %v is not one of %v", path, value, enum))
+	}
+	if typ, ok := schema["type"].(string); ok && !hasSchemaType(typ, value) {
+		return append(problems, fmt.Sprintf("%s: expected %s, got %T", path, typ, value))
+	}
+
+	switch v := value.(type) {
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				problems = append(problems, validateSchema(root, items, item, fmt.Sprintf("%s/%d", path, i))...)
+			}
+		}
+	case map[string]any:
+		properties := map[string]any{}
+		closed := schema["additionalProperties"] == false || schema["unevaluatedProperties"] == false
+		schemas := []map[string]any{schema}
+		if allOf, ok := schema["allOf"].([]any); ok {
+			for _, sub := range allOf {
+				ref := strings.TrimPrefix(sub.(map[string]any)["$ref"].(string), "#/$defs/")
+				schemas = append(schemas, root["$defs"].(map[string]any)[ref].(map[string]any))
+			}
+		}
+		for _, s := range schemas {
+			if props, ok := s["properties"].(map[string]any); ok {
+				for name, prop := range props {
+					properties[name] = prop
+				}
+			}
+		}
+		if required, ok := schema["required"].([]any); ok {
+			for _, name := range required {
+				if _, ok := v[name.(string)]; !ok {
+					problems = append(problems, fmt.Sprintf("%s: missing required property %s", path, name))
+				}
+			}
+		}
+		for name, child := range v {
+			prop, ok := properties[name]
+			if !ok {
+				if closed {
+					problems = append(problems, fmt.Sprintf("%s: unexpected property %s", path, name))
+				}
+				continue
+			}
+			problems = append(problems, validateSchema(root, prop.(map[string]any), child, path+"/"+name)...)
+		}
+	}
+	return problems
+}
+
+// hasSchemaType reports whether a decoded JSON value has the given JSON Schema type.
+func hasSchemaType(typ string, value any) bool {
+	switch v := value.(type) {
+	case string:
+		return typ == "string"
+	case bool:
+		return typ == "boolean"
+	case float64:
+		return typ == "number" || (typ == "integer" && v == float64(int64(v)))
+	case []any:
+		return typ == "array"
+	case map[string]any:
+		return typ == "object"
+	case nil:
+		return typ == "null"
+	}
+	return false
+}
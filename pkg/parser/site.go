@@ -0,0 +1,307 @@
+package parser
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rastogiji/autodoc-grafana/pkg/templates"
+	"github.com/rastogiji/autodoc-grafana/pkg/utils"
+)
+
+// sitePage holds the data shared by every page of the HTML site.
+type sitePage struct {
+	// Root is the relative path from the page back to the site root
+	Root string
+}
+
+// siteIndex is the data of the site's index page.
+type siteIndex struct {
+	sitePage
+	// Dashboards contains the documented dashboards ordered by title
+	Dashboards []*siteDashboard
+}
+
+// siteDashboard is the data of a dashboard page.
+type siteDashboard struct {
+	sitePage
+	*DashboardDoc
+	// Page is the path of the dashboard page relative to the site root
+	Page string
+	// PanelCount is the number of documented panels
+	PanelCount int
+	// Rows contains the dashboard rows with their anchors
+	Rows []siteRow
+	// Warnings contains the diagnostics rendered on the page
+	Warnings []Diagnostic
+}
+
+// siteRow is a dashboard row with its anchor.
+type siteRow struct {
+	RowDoc
+	// Anchor is the ID of the row section
+	Anchor string
+	// Panels contains the row's panels with their anchors
+	Panels []sitePanel
+}
+
+// sitePanel is a panel with its anchor.
+type sitePanel struct {
+	PanelDoc
+	// Anchor is the ID of the panel table row
+	Anchor string
+}
+
+// siteMetrics is the data of the site's metrics page.
+type siteMetrics struct {
+	sitePage
+	// Metrics contains every metric ordered by name
+	Metrics []*siteMetric
+}
+
+// siteMetric is a metric with the panels using it.
+type siteMetric struct {
+	// Name is the metric name
+	Name string
+	// Anchor is the ID of the metric table row
+	Anchor string
+	// Panels contains the panels using the metric
+	Panels []siteMetricPanel
+}
+
+// siteMetricPanel is a panel using a metric.
+type siteMetricPanel struct {
+	// Dashboard is the title of the panel's dashboard
+	Dashboard string
+	// Panel is the panel title
+	Panel string
+	// URL is the link to the panel relative to the site root
+	URL string
+}
+
+// searchEntry is an entry of the client-side search index.
+type searchEntry struct {
+	// Kind is the kind of documented item: dashboard, panel or metric
+	Kind string `json:"kind"`
+	// Title is the title of the item
+	Title string `json:"title"`
+	// URL is the link to the item relative to the site root
+	URL string `json:"url"`
+	// Text is the searchable text describing the item
+	Text string `json:"text"`
+}
+
+// CreateSite writes a self-contained static HTML documentation site for a set
+// of dashboards: an index page, one page per dashboard, a metrics page and a
+// client-side search index. Pages don't load any external asset.
+//
+// Parameters:
+//   - docs: the documentation models of the dashboards
+//   - outputDir: the directory the site is written to
+//   - opts: options controlling how the site is written
+//
+// Returns an error if the site templates cannot be executed or a file cannot
+// be written.
+func CreateSite(docs []*DashboardDoc, outputDir string, opts Options) error {
+	tmpl, err := templates.GetSiteTemplate()
+	if err != nil {
+		return err
+	}
+	if opts.Check == nil {
+		if err := os.MkdirAll(filepath.Join(outputDir, "dashboards"), 0755); err != nil {
+			slog.Error("error creating site directory", slog.Any("error", err))
+			return fmt.Errorf("error creating site directory: %w", err)
+		}
+	}
+
+	dashboards := newSiteDashboards(docs)
+	metrics := newSiteMetrics(dashboards)
+
+	pages := map[string]struct {
+		template string
+		data     any
+	}{
+		"index.html":   {"index", siteIndex{Dashboards: dashboards}},
+		"metrics.html": {"metrics", siteMetrics{Metrics: metrics}},
+	}
+	for _, dashboard := range dashboards {
+		pages[dashboard.Page] = struct {
+			template string
+			data     any
+		}{"dashboard", dashboard}
+	}
+	for name, page := range pages {
+		var buf bytes.Buffer
+		if err := tmpl.ExecuteTemplate(&buf, page.template, page.data); err != nil {
+			slog.Error("error executing site template", slog.Any("error", err), slog.String("page", name))
+			return fmt.Errorf("error executing site template for %s: %w", name, err)
+		}
+		if err := writeOutput(filepath.Join(outputDir, filepath.FromSlash(name)), buf.Bytes(), opts); err != nil {
+			slog.Error("error writing site page", slog.Any("error", err), slog.String("page", name))
+			return fmt.Errorf("error writing site page %s: %w", name, err)
+		}
+	}
+
+	index, err := json.Marshal(newSearchIndex(dashboards, metrics))
+	if err != nil {
+		return fmt.Errorf("error encoding search index: %w", err)
+	}
+	script := "window.autodocSearchIndex = " + string(index) + ";\n"
+	if err := writeOutput(filepath.Join(outputDir, "search-index.js"), []byte(script), opts); err != nil {
+		slog.Error("error writing search index", slog.Any("error", err))
+		return fmt.Errorf("error writing search index: %w", err)
+	}
+	return nil
+}
+
+// newSiteDashboards prepares the dashboard pages, ordered by title. Page names
+// and anchors are slugs made unique within the site and within each page.
+func newSiteDashboards(docs []*DashboardDoc) []*siteDashboard {
+	sorted := slices.Clone(docs)
+	slices.SortFunc(sorted, func(a, b *DashboardDoc) int {
+		return cmp.Or(
+			strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title)),
+			strings.Compare(a.File, b.File),
+		)
+	})
+
+	pages := newSlugSet()
+	var dashboards []*siteDashboard
+	for _, doc := range sorted {
+		name := strings.TrimSuffix(filepath.Base(doc.File), filepath.Ext(doc.File))
+		dashboard := &siteDashboard{
+			sitePage:     sitePage{Root: "../"},
+			DashboardDoc: doc,
+			Page:         "dashboards/" + pages.unique(name, "dashboard") + ".html",
+		}
+		anchors := newSlugSet()
+		anchors.unique("variables", "")
+		anchors.unique("warnings", "")
+		for i, row := range doc.Rows {
+			sr := siteRow{RowDoc: row, Anchor: anchors.unique(row.Title, "row-"+strconv.Itoa(i+1))}
+			for _, panel := range row.Panels {
+				sr.Panels = append(sr.Panels, sitePanel{
+					PanelDoc: panel,
+					Anchor:   anchors.unique("panel-"+strconv.Itoa(panel.ID)+"-"+panel.Title, "panel"),
+				})
+				dashboard.PanelCount++
+			}
+			dashboard.Rows = append(dashboard.Rows, sr)
+		}
+		for _, diag := range doc.Diagnostics {
+			if diag.Severity != SeverityNote {
+				dashboard.Warnings = append(dashboard.Warnings, diag)
+			}
+		}
+		dashboards = append(dashboards, dashboard)
+	}
+	return dashboards
+}
+
+// newSiteMetrics lists every metric used by the dashboards' panels, ordered
+// by name, with links to the panels using it.
+func newSiteMetrics(dashboards []*siteDashboard) []*siteMetric {
+	byName := make(map[string]*siteMetric)
+	for _, dashboard := range dashboards {
+		for _, row := range dashboard.Rows {
+			for _, panel := range row.Panels {
+				for _, name := range panel.Metrics {
+					metric, ok := byName[name]
+					if !ok {
+						metric = &siteMetric{Name: name}
+						byName[name] = metric
+					}
+					metric.Panels = append(metric.Panels, siteMetricPanel{
+						Dashboard: dashboard.Title,
+						Panel:     panel.Title,
+						URL:       dashboard.Page + "#" + panel.Anchor,
+					})
+				}
+			}
+		}
+	}
+
+	metrics := make([]*siteMetric, 0, len(byName))
+	for _, metric := range byName {
+		metrics = append(metrics, metric)
+	}
+	slices.SortFunc(metrics, func(a, b *siteMetric) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	anchors := newSlugSet()
+	for _, metric := range metrics {
+		metric.Anchor = anchors.unique("metric-"+metric.Name, "metric")
+	}
+	return metrics
+}
+
+// newSearchIndex builds the client-side search index of the site.
+func newSearchIndex(dashboards []*siteDashboard, metrics []*siteMetric) []searchEntry {
+	var entries []searchEntry
+	for _, dashboard := range dashboards {
+		entries = append(entries, searchEntry{
+			Kind:  "dashboard",
+			Title: dashboard.Title,
+			URL:   dashboard.Page,
+			Text:  strings.Join(append([]string{dashboard.Description}, dashboard.Tags...), " "),
+		})
+		for _, row := range dashboard.Rows {
+			for _, panel := range row.Panels {
+				text := []string{dashboard.Title, row.Title, panel.Description, panel.Type}
+				text = append(text, panel.Metrics...)
+				text = append(text, panel.LogStreams...)
+				text = append(text, panel.Tables...)
+				text = append(text, panel.Indices...)
+				entries = append(entries, searchEntry{
+					Kind:  "panel",
+					Title: panel.Title,
+					URL:   dashboard.Page + "#" + panel.Anchor,
+					Text:  strings.Join(text, " "),
+				})
+			}
+		}
+	}
+	for _, metric := range metrics {
+		var text []string
+		for _, panel := range metric.Panels {
+			text = append(text, panel.Dashboard, panel.Panel)
+		}
+		entries = append(entries, searchEntry{
+			Kind:  "metric",
+			Title: metric.Name,
+			URL:   "metrics.html#" + metric.Anchor,
+			Text:  strings.Join(utils.GetUniqueElements(text), " "),
+		})
+	}
+	return entries
+}
+
+// slugSet hands out slugs that are unique within the set.
+type slugSet map[string]bool
+
+// newSlugSet creates an empty slug set.
+func newSlugSet() slugSet {
+	return make(slugSet)
+}
+
+// unique returns the slug of value, or of fallback if value has no letters or
+// digits, suffixed with a number if the slug was already handed out.
+func (s slugSet) unique(value, fallback string) string {
+	slug := utils.Slugify(value)
+	if slug == "" {
+		slug = utils.Slugify(fallback)
+	}
+	candidate := slug
+	for i := 2; s[candidate]; i++ {
+		candidate = slug + "-" + strconv.Itoa(i)
+	}
+	s[candidate] = true
+	return candidate
+}
@@ -0,0 +1,89 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/rastogiji/autodoc-grafana/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateSite(t *testing.T) {
+	var docs []*DashboardDoc
+	for _, file := range []string{"testdata/rows_dashboard.json", "testdata/loki_dashboard.json", "testdata/bad_query.json"} {
+		doc, err := BuildDocumentation(file, Options{})
+		assert.NoError(t, err)
+		docs = append(docs, doc)
+	}
+
+	outputDir := t.TempDir()
+	assert.NoError(t, CreateSite(docs, outputDir, Options{}))
+
+	read := func(name string) string {
+		bs, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(name)))
+		assert.NoError(t, err)
+		return string(bs)
+	}
+
+	tests := []struct {
+		name     string
+		file     string
+		contains []string
+	}{
+		{
+			name: "index should link every dashboard page",
+			file: "index.html",
+			contains: []string{
+				`href="dashboards/rows-dashboard.html"`,
+				`href="dashboards/loki-dashboard.html"`,
+				`href="dashboards/bad-query.html"`,
+			},
+		}, {
+			name: "dashboard page should have a section and an anchor per row",
+			file: "dashboards/rows-dashboard.html",
+			contains: []string{
+				`<section id="` + utils.Slugify(docs[0].Rows[1].Title) + `">`,
+				`<tr id="panel-` + strconv.Itoa(docs[0].Rows[1].Panels[0].ID),
+				`<script src="../search-index.js"></script>`,
+			},
+		}, {
+			name:     "dashboard page should list the warnings",
+			file:     "dashboards/bad-query.html",
+			contains: []string{`<section id="warnings">`, "error parsing promql expression"},
+		}, {
+			name:     "metrics page should link the panels using each metric",
+			file:     "metrics.html",
+			contains: []string{`<tr id="metric-` + utils.Slugify(docs[0].Panels()[0].Metrics[0]) + `">`, `href="dashboards/rows-dashboard.html#panel-`},
+		}, {
+			name:     "search index should be a script defining the index",
+			file:     "search-index.js",
+			contains: []string{"window.autodocSearchIndex = [", `"kind":"metric"`, `"kind":"panel"`, `"kind":"dashboard"`},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			content := read(tc.file)
+			for _, expected := range tc.contains {
+				assert.Contains(t, content, expected)
+			}
+		})
+	}
+
+	t.Run("pages should not load external assets", func(t *testing.T) {
+		for _, file := range []string{"index.html", "metrics.html", "dashboards/rows-dashboard.html"} {
+			content := read(file)
+			assert.False(t, strings.Contains(content, "http://") || strings.Contains(content, "https://"), file)
+		}
+	})
+}
+
+func TestSlugSetUnique(t *testing.T) {
+	slugs := newSlugSet()
+	assert.Equal(t, "cpu-usage", slugs.unique("CPU Usage", "row"))
+	assert.Equal(t, "cpu-usage-2", slugs.unique("cpu usage", "row"))
+	assert.Equal(t, "row-1", slugs.unique("!!!", "row-1"))
+}
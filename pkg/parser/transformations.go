@@ -0,0 +1,460 @@
+package parser
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// reducerNames maps the IDs of the Grafana reducers, used by the reduce,
+// groupBy and calculateField transformations, to a human readable name.
+// Reducers missing from the map are documented by their ID.
+var reducerNames = map[string]string{
+	"lastNotNull":   "last (not null)",
+	"firstNotNull":  "first (not null)",
+	"allIsNull":     "all null",
+	"allIsZero":     "all zero",
+	"changeCount":   "change count",
+	"distinctCount": "distinct count",
+	"diffperc":      "difference percent",
+	"stdDev":        "standard deviation",
+	"allValues":     "all values",
+	"uniqueValues":  "unique values",
+}
+
+// valueMatcherOperators maps the IDs of the value matchers of the
+// filterByValue transformation to the comparison they make.
+var valueMatcherOperators = map[string]string{
+	"greater":        ">",
+	"greaterOrEqual": ">=",
+	"lower":          "<",
+	"lowerOrEqual":   "<=",
+	"equal":          "=",
+	"notEqual":       "!=",
+}
+
+// simpleTransformations describes the transformations without options worth
+// documenting.
+var simpleTransformations = map[string]string{
+	"merge":           "merge the query results into one table",
+	"concatenate":     "concatenate the fields of every query result into one table",
+	"labelsToFields":  "turn the series labels into fields",
+	"seriesToRows":    "combine the series into one table with a row per value",
+	"rowsToFields":    "turn the rows into fields",
+	"timeSeriesTable": "turn the time series into a table with a sparkline per series",
+	"joinByLabels":    "join the series by their labels",
+	"flattenFields":   "flatten the nested fields",
+}
+
+// Transformation represents a transformation of the query results of a
+// panel, applied in order before the results are visualized.
+type Transformation struct {
+	// ID is the transformation type, e.g. "merge", "organize" or "reduce"
+	ID string `json:"id"`
+	// Disabled indicates that the transformation is skipped
+	Disabled bool `json:"disabled"`
+	// Filter restricts the transformation to some query results, e.g. to
+	// the results of query A
+	Filter *FieldMatcher `json:"filter"`
+	// Options holds the options of the transformation, specific to its type
+	Options json.RawMessage `json:"options"`
+}
+
+// String returns a human readable description of the transformation, e.g.
+// `join the queries on "instance" (outer join)`. Transformations without a
+// dedicated description are documented by their ID.
+func (t Transformation) String() string {
+	description := t.describe()
+	if t.Filter != nil && t.Filter.ID == "byRefId" {
+		var ref string
+		if json.Unmarshal(t.Filter.Options, &ref) == nil && ref != "" {
+			description += " (query " + ref + " only)"
+		}
+	}
+	return description
+}
+
+// describe returns the description of the transformation without its filter.
+func (t Transformation) describe() string {
+	if description, ok := simpleTransformations[t.ID]; ok {
+		return description
+	}
+	switch t.ID {
+	case "organize":
+		return t.describeOrganize()
+	case "calculateField":
+		return t.describeCalculateField()
+	case "filterByValue":
+		return t.describeFilterByValue()
+	case "joinByField", "seriesToColumns":
+		var opts struct {
+			ByField string `json:"byField"`
+			Mode    string `json:"mode"`
+		}
+		_ = json.Unmarshal(t.Options, &opts)
+		on := "time"
+		if opts.ByField != "" {
+			on = strconv.Quote(opts.ByField)
+		}
+		return fmt.Sprintf("join the query results on %s (%s join)", on, cmp.Or(opts.Mode, "outer"))
+	case "groupBy":
+		return t.describeGroupBy()
+	case "reduce":
+		var opts struct {
+			Reducers []string `json:"reducers"`
+			Mode     string   `json:"mode"`
+		}
+		_ = json.Unmarshal(t.Options, &opts)
+		if opts.Mode == "reduceFields" {
+			return "reduce each field to its " + reducerList(opts.Reducers)
+		}
+		return "reduce each series to a row with its " + reducerList(opts.Reducers)
+	case "sortBy":
+		var opts struct {
+			Sort []struct {
+				Field string `json:"field"`
+				Desc  bool   `json:"desc"`
+			} `json:"sort"`
+		}
+		_ = json.Unmarshal(t.Options, &opts)
+		if len(opts.Sort) == 0 {
+			return "sort the rows"
+		}
+		order := "ascending"
+		if opts.Sort[0].Desc {
+			order = "descending"
+		}
+		return fmt.Sprintf("sort by %q %s", opts.Sort[0].Field, order)
+	case "limit":
+		var opts struct {
+			LimitField json.Number `json:"limitField"`
+		}
+		_ = json.Unmarshal(t.Options, &opts)
+		return fmt.Sprintf("keep the first %s rows", cmp.Or(string(opts.LimitField), "10"))
+	case "filterFieldsByName":
+		return t.describeFilterFieldsByName()
+	case "filterByRefId":
+		var opts struct {
+			Include string `json:"include"`
+		}
+		_ = json.Unmarshal(t.Options, &opts)
+		return "keep the results of query " + opts.Include
+	case "renameByRegex":
+		var opts struct {
+			Regex         string `json:"regex"`
+			RenamePattern string `json:"renamePattern"`
+		}
+		_ = json.Unmarshal(t.Options, &opts)
+		return fmt.Sprintf("rename the fields matching /%s/ to %q", opts.Regex, opts.RenamePattern)
+	case "convertFieldType":
+		var opts struct {
+			Conversions []struct {
+				TargetField     string `json:"targetField"`
+				DestinationType string `json:"destinationType"`
+			} `json:"conversions"`
+		}
+		_ = json.Unmarshal(t.Options, &opts)
+		conversions := make([]string, 0, len(opts.Conversions))
+		for _, c := range opts.Conversions {
+			conversions = append(conversions, fmt.Sprintf("%q to %s", c.TargetField, c.DestinationType))
+		}
+		return "convert " + strings.Join(conversions, ", ")
+	case "extractFields":
+		var opts struct {
+			Source string `json:"source"`
+			Format string `json:"format"`
+		}
+		_ = json.Unmarshal(t.Options, &opts)
+		return strings.Join(strings.Fields(fmt.Sprintf("extract %s fields from %q", opts.Format, opts.Source)), " ")
+	case "partitionByValues":
+		var opts struct {
+			Fields []string `json:"fields"`
+		}
+		_ = json.Unmarshal(t.Options, &opts)
+		return "split into one table per value of " + quoteList(opts.Fields)
+	}
+	return t.ID + " transformation"
+}
+
+// describeOrganize describes the fields hidden, renamed and reordered by an
+// organize transformation, e.g. `hide "Time"; rename "Value" to "Errors"`.
+func (t Transformation) describeOrganize() string {
+	var opts struct {
+		ExcludeByName map[string]bool   `json:"excludeByName"`
+		IndexByName   map[string]int    `json:"indexByName"`
+		RenameByName  map[string]string `json:"renameByName"`
+	}
+	_ = json.Unmarshal(t.Options, &opts)
+
+	var hidden, renamed []string
+	for _, name := range slices.Sorted(maps.Keys(opts.ExcludeByName)) {
+		if opts.ExcludeByName[name] {
+			hidden = append(hidden, strconv.Quote(name))
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(opts.RenameByName)) {
+		if to := opts.RenameByName[name]; to != "" {
+			renamed = append(renamed, fmt.Sprintf("%q to %q", name, to))
+		}
+	}
+
+	var parts []string
+	if len(hidden) > 0 {
+		parts = append(parts, "hide "+strings.Join(hidden, ", "))
+	}
+	if len(renamed) > 0 {
+		parts = append(parts, "rename "+strings.Join(renamed, ", "))
+	}
+	if len(opts.IndexByName) > 0 {
+		parts = append(parts, "reorder")
+	}
+	if len(parts) == 0 {
+		return "organize the fields"
+	}
+	return "organize the fields: " + strings.Join(parts, "; ")
+}
+
+// describeCalculateField describes the field added by a calculateField
+// transformation, e.g. `add field "Error %" = "errors" / "total"`.
+func (t Transformation) describeCalculateField() string {
+	var opts struct {
+		Mode   string `json:"mode"`
+		Alias  string `json:"alias"`
+		Binary struct {
+			Left     json.RawMessage `json:"left"`
+			Operator string          `json:"operator"`
+			Right    json.RawMessage `json:"right"`
+		} `json:"binary"`
+		Unary struct {
+			Operator  string `json:"operator"`
+			FieldName string `json:"fieldName"`
+		} `json:"unary"`
+		Reduce struct {
+			Reducer string   `json:"reducer"`
+			Include []string `json:"include"`
+		} `json:"reduce"`
+		Cumulative struct {
+			Field   string `json:"field"`
+			Reducer string `json:"reducer"`
+		} `json:"cumulative"`
+		Window struct {
+			Field   string `json:"field"`
+			Reducer string `json:"reducer"`
+		} `json:"window"`
+		ReplaceFields bool `json:"replaceFields"`
+	}
+	_ = json.Unmarshal(t.Options, &opts)
+
+	var expr string
+	switch opts.Mode {
+	case "binary":
+		expr = fmt.Sprintf("%s %s %s", calculationOperand(opts.Binary.Left), opts.Binary.Operator, calculationOperand(opts.Binary.Right))
+	case "unary":
+		expr = fmt.Sprintf("%s(%q)", opts.Unary.Operator, opts.Unary.FieldName)
+	case "index":
+		expr = "row index"
+	case "cumulativeFunctions":
+		expr = fmt.Sprintf("cumulative %s of %q", reducerName(opts.Cumulative.Reducer), opts.Cumulative.Field)
+	case "windowFunctions":
+		expr = fmt.Sprintf("moving %s of %q", reducerName(opts.Window.Reducer), opts.Window.Field)
+	default:
+		fields := "all fields"
+		if len(opts.Reduce.Include) > 0 {
+			fields = quoteList(opts.Reduce.Include)
+		}
+		expr = fmt.Sprintf("%s of %s", reducerName(cmp.Or(opts.Reduce.Reducer, "sum")), fields)
+	}
+
+	description := "add field " + expr
+	if opts.Alias != "" {
+		description = fmt.Sprintf("add field %q = %s", opts.Alias, expr)
+	}
+	if opts.ReplaceFields {
+		description += ", replacing the other fields"
+	}
+	return description
+}
+
+// calculationOperand returns the operand of a binary calculation, written as
+// a field name or a number, or as an object with a field matcher or a fixed
+// value in recent Grafana versions.
+func calculationOperand(raw json.RawMessage) string {
+	var name string
+	if json.Unmarshal(raw, &name) == nil {
+		if _, err := strconv.ParseFloat(name, 64); err == nil {
+			return name
+		}
+		return strconv.Quote(name)
+	}
+	var operand struct {
+		Fixed   string        `json:"fixed"`
+		Matcher *FieldMatcher `json:"matcher"`
+	}
+	_ = json.Unmarshal(raw, &operand)
+	if operand.Matcher != nil {
+		var field string
+		_ = json.Unmarshal(operand.Matcher.Options, &field)
+		return strconv.Quote(field)
+	}
+	return operand.Fixed
+}
+
+// describeFilterByValue describes the rows kept or dropped by a filterByValue
+// transformation, e.g. `keep rows where "latency" > 0.5`.
+func (t Transformation) describeFilterByValue() string {
+	var opts struct {
+		Type    string `json:"type"`
+		Match   string `json:"match"`
+		Filters []struct {
+			FieldName string `json:"fieldName"`
+			Config    struct {
+				ID      string `json:"id"`
+				Options struct {
+					Value json.RawMessage `json:"value"`
+					From  json.RawMessage `json:"from"`
+					To    json.RawMessage `json:"to"`
+				} `json:"options"`
+			} `json:"config"`
+		} `json:"filters"`
+	}
+	_ = json.Unmarshal(t.Options, &opts)
+
+	conditions := make([]string, 0, len(opts.Filters))
+	for _, filter := range opts.Filters {
+		field, options := strconv.Quote(filter.FieldName), filter.Config.Options
+		var condition string
+		switch id := filter.Config.ID; id {
+		case "isNull":
+			condition = field + " is null"
+		case "isNotNull":
+			condition = field + " is not null"
+		case "regex":
+			var pattern string
+			_ = json.Unmarshal(options.Value, &pattern)
+			condition = fmt.Sprintf("%s matches /%s/", field, pattern)
+		case "range":
+			condition = fmt.Sprintf("%s is between %s and %s", field, jsonValue(options.From), jsonValue(options.To))
+		default:
+			condition = fmt.Sprintf("%s %s %s", field, cmp.Or(valueMatcherOperators[id], id), jsonValue(options.Value))
+		}
+		conditions = append(conditions, condition)
+	}
+
+	action := "keep"
+	if opts.Type == "exclude" {
+		action = "drop"
+	}
+	separator := " or "
+	if opts.Match == "all" {
+		separator = " and "
+	}
+	return fmt.Sprintf("%s rows where %s", action, strings.Join(conditions, separator))
+}
+
+// describeGroupBy describes the fields a groupBy transformation groups the
+// rows by and the calculations it makes, e.g. `group by "instance"; max of "Value"`.
+func (t Transformation) describeGroupBy() string {
+	var opts struct {
+		Fields map[string]struct {
+			Operation    string   `json:"operation"`
+			Aggregations []string `json:"aggregations"`
+		} `json:"fields"`
+	}
+	_ = json.Unmarshal(t.Options, &opts)
+
+	var groups, calculations []string
+	for _, name := range slices.Sorted(maps.Keys(opts.Fields)) {
+		field := opts.Fields[name]
+		switch field.Operation {
+		case "groupby":
+			groups = append(groups, strconv.Quote(name))
+		case "aggregate":
+			calculations = append(calculations, fmt.Sprintf("%s of %q", reducerList(field.Aggregations), name))
+		}
+	}
+	description := "group by " + strings.Join(groups, ", ")
+	if len(calculations) > 0 {
+		description += "; " + strings.Join(calculations, ", ")
+	}
+	return description
+}
+
+// describeFilterFieldsByName describes the fields kept or dropped by a
+// filterFieldsByName transformation.
+func (t Transformation) describeFilterFieldsByName() string {
+	type names struct {
+		Names   []string `json:"names"`
+		Pattern string   `json:"pattern"`
+	}
+	var opts struct {
+		Include *names `json:"include"`
+		Exclude *names `json:"exclude"`
+	}
+	_ = json.Unmarshal(t.Options, &opts)
+
+	action, filter := "keep", opts.Include
+	if filter == nil {
+		action, filter = "drop", opts.Exclude
+	}
+	switch {
+	case filter == nil:
+		return "filter the fields by name"
+	case filter.Pattern != "":
+		return fmt.Sprintf("%s the fields matching /%s/", action, filter.Pattern)
+	}
+	return fmt.Sprintf("%s the fields %s", action, quoteList(filter.Names))
+}
+
+// reducerName returns the human readable name of a reducer.
+func reducerName(reducer string) string {
+	return cmp.Or(reducerNames[reducer], reducer)
+}
+
+// reducerList returns the human readable names of reducers as a list.
+func reducerList(reducers []string) string {
+	names := make([]string, 0, len(reducers))
+	for _, r := range reducers {
+		names = append(names, reducerName(r))
+	}
+	return strings.Join(names, ", ")
+}
+
+// quoteList returns a list of quoted names, e.g. `"a", "b"`.
+func quoteList(list []string) string {
+	quoted := make([]string, 0, len(list))
+	for _, s := range list {
+		quoted = append(quoted, strconv.Quote(s))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// jsonValue returns a JSON scalar as written in a condition: numbers and
+// booleans as is and strings quoted.
+func jsonValue(raw json.RawMessage) string {
+	var value any
+	if json.Unmarshal(raw, &value) != nil {
+		return ""
+	}
+	if s, ok := value.(string); ok {
+		return strconv.Quote(s)
+	}
+	return strings.TrimSpace(string(raw))
+}
+
+// newTransformationDocs converts the transformations of a panel into their
+// documentation model, skipping the disabled ones since they do not change
+// the data.
+func newTransformationDocs(transformations []Transformation) []TransformationDoc {
+	var docs []TransformationDoc
+	for _, t := range transformations {
+		if t.Disabled || t.ID == "" {
+			continue
+		}
+		docs = append(docs, TransformationDoc{ID: t.ID, Description: t.String()})
+	}
+	return docs
+}
@@ -0,0 +1,125 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransformationString(t *testing.T) {
+	tests := []struct {
+		name           string
+		transformation string
+		expected       string
+	}{
+		{
+			name:           "merge should be described",
+			transformation: `{"id": "merge", "options": {}}`,
+			expected:       "merge the query results into one table",
+		},
+		{
+			name:           "organize should list the hidden, renamed and reordered fields",
+			transformation: `{"id": "organize", "options": {"excludeByName": {"Time": true, "job": false}, "indexByName": {"job": 0}, "renameByName": {"Value": "Errors", "job": ""}}}`,
+			expected:       `organize the fields: hide "Time"; rename "Value" to "Errors"; reorder`,
+		},
+		{
+			name:           "binary calculation should be written as an expression",
+			transformation: `{"id": "calculateField", "options": {"mode": "binary", "alias": "Error %", "binary": {"left": "errors", "operator": "/", "right": "total"}}}`,
+			expected:       `add field "Error %" = "errors" / "total"`,
+		},
+		{
+			name:           "binary calculation with matcher and fixed operands should be written as an expression",
+			transformation: `{"id": "calculateField", "options": {"mode": "binary", "binary": {"left": {"matcher": {"id": "byName", "options": "bytes"}}, "operator": "*", "right": {"fixed": "8"}}, "replaceFields": true}}`,
+			expected:       `add field "bytes" * 8, replacing the other fields`,
+		},
+		{
+			name:           "row reduction should default to the sum of all fields",
+			transformation: `{"id": "calculateField", "options": {}}`,
+			expected:       "add field sum of all fields",
+		},
+		{
+			name:           "row reduction should name the included fields",
+			transformation: `{"id": "calculateField", "options": {"mode": "reduceRow", "alias": "Total", "reduce": {"reducer": "mean", "include": ["a", "b"]}}}`,
+			expected:       `add field "Total" = mean of "a", "b"`,
+		},
+		{
+			name:           "filter by value should describe every condition",
+			transformation: `{"id": "filterByValue", "options": {"type": "exclude", "match": "all", "filters": [{"fieldName": "code", "config": {"id": "equal", "options": {"value": "200"}}}, {"fieldName": "latency", "config": {"id": "range", "options": {"from": 0, "to": 1}}}, {"fieldName": "error", "config": {"id": "isNull", "options": {}}}]}}`,
+			expected:       `drop rows where "code" = "200" and "latency" is between 0 and 1 and "error" is null`,
+		},
+		{
+			name:           "join by field should name the field and the join mode",
+			transformation: `{"id": "joinByField", "options": {"byField": "instance"}}`,
+			expected:       `join the query results on "instance" (outer join)`,
+		},
+		{
+			name:           "series to columns should be described as a join on time",
+			transformation: `{"id": "seriesToColumns", "options": {}}`,
+			expected:       "join the query results on time (outer join)",
+		},
+		{
+			name:           "reduce fields mode should reduce each field",
+			transformation: `{"id": "reduce", "options": {"reducers": ["lastNotNull"], "mode": "reduceFields"}}`,
+			expected:       "reduce each field to its last (not null)",
+		},
+		{
+			name:           "filter fields by name should list the fields kept",
+			transformation: `{"id": "filterFieldsByName", "options": {"include": {"names": ["Time", "Value"]}}}`,
+			expected:       `keep the fields "Time", "Value"`,
+		},
+		{
+			name:           "transformation restricted to a query should name it",
+			transformation: `{"id": "limit", "filter": {"id": "byRefId", "options": "B"}, "options": {"limitField": 5}}`,
+			expected:       "keep the first 5 rows (query B only)",
+		},
+		{
+			name:           "unknown transformation should be documented by its id",
+			transformation: `{"id": "heatmap", "options": {}}`,
+			expected:       "heatmap transformation",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var transformation Transformation
+			assert.NoError(t, json.Unmarshal([]byte(tc.transformation), &transformation))
+			assert.Equal(t, tc.expected, transformation.String())
+		})
+	}
+}
+
+func TestBuildDocumentationTransformations(t *testing.T) {
+	doc, err := BuildDocumentation("testdata/transformations_dashboard.json", Options{})
+	assert.NoError(t, err)
+
+	panels := doc.Panels()
+	assert.Equal(t, []string{
+		"queries A, B",
+		"merge the query results into one table",
+		`organize the fields: hide "Time"; rename "Value #A" to "errors", "Value #B" to "total"; reorder`,
+		`add field "Error %" = "errors" / "total"`,
+		`keep rows where "Error %" > 0.01 or "service" matches /checkout.*/`,
+		"table",
+	}, panels[0].Pipeline(), "disabled transformations should be skipped")
+	assert.Equal(t, []TransformationDoc{
+		{ID: "joinByField", Description: `join the query results on "pod" (inner join)`},
+		{ID: "groupBy", Description: `group by "node"; sum, max of "Value #B", distinct count of "pod"`},
+	}, panels[1].Transformations)
+	assert.Equal(t, []string{
+		"query A",
+		"reduce each series to a row with its max, mean, last (not null)",
+		"prepareTimeSeries transformation (query A only)",
+		"stat",
+	}, panels[2].Pipeline())
+
+	var buf bytes.Buffer
+	assert.NoError(t, Render(&buf, doc, FormatMarkdown))
+	assert.Contains(t, buf.String(), "| query A<br> → reduce each series to a row with its max, mean, last (not null)<br> → prepareTimeSeries transformation (query A only)<br> → stat |")
+}
+
+func TestPipelineWithoutTransformations(t *testing.T) {
+	panel := PanelDoc{Type: "timeseries", Targets: []TargetDoc{{RefID: "A"}}}
+	assert.Nil(t, panel.Pipeline(), "panels without transformations should have no pipeline")
+}
@@ -1,5 +1,14 @@
 package parser
 
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
 // Link represents a dashboard link with its metadata including type, title, and URL.
 type Link struct {
 	Type  string `json:"type"`
@@ -13,62 +22,443 @@ type Datasource struct {
 	UID  string `json:"uid"`
 }
 
+// UnmarshalJSON decodes a datasource reference. Besides the {type, uid} object
+// form, older dashboards and variables reference datasources by a plain string
+// (a name or a "${DS_NAME}" placeholder), which is stored in UID.
+func (d *Datasource) UnmarshalJSON(b []byte) error {
+	var name string
+	if err := json.Unmarshal(b, &name); err == nil {
+		*d = Datasource{UID: name}
+		return nil
+	}
+
+	type plain Datasource
+	var ds plain
+	if err := json.Unmarshal(b, &ds); err != nil {
+		return err
+	}
+	*d = Datasource(ds)
+	return nil
+}
+
+// String returns a human readable representation of the datasource reference,
+// e.g. "prometheus (uid: prom-main)".
+func (d *Datasource) String() string {
+	switch {
+	case d == nil:
+		return ""
+	case d.Type != "" && d.UID != "":
+		return d.Type + " (uid: " + d.UID + ")"
+	case d.Type != "":
+		return d.Type
+	default:
+		return d.UID
+	}
+}
+
 // Target represents a query target containing a PromQL expression and its associated datasource.
+// Queries for other datasources carry different fields, so the raw target JSON
+// is kept for the datasource specific extractors.
 type Target struct {
-	Expr       string     `json:"expr"`
-	Datasource Datasource `json:"datasource"`
+	RefID      string          `json:"refId"`
+	Expr       string          `json:"expr"`
+	Datasource Datasource      `json:"datasource"`
+	Raw        json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON decodes a query target and keeps a copy of its raw JSON.
+func (t *Target) UnmarshalJSON(b []byte) error {
+	type plain Target
+	var target plain
+	if err := json.Unmarshal(b, &target); err != nil {
+		return err
+	}
+	*t = Target(target)
+	t.Raw = append(json.RawMessage(nil), b...)
+	return nil
+}
+
+// GetRaw returns the raw JSON of the target. Targets that were not decoded
+// from JSON are encoded from their fields.
+func (t *Target) GetRaw() json.RawMessage {
+	if len(t.Raw) > 0 {
+		return t.Raw
+	}
+	bs, err := json.Marshal(t)
+	if err != nil {
+		return nil
+	}
+	return bs
 }
 
-// RowPanel represents a dashboard row panel that can contain other panels.
-// It includes metadata and can hold nested panels within it.
+// GridPos represents the position and size of a panel on the dashboard grid.
+// Y grows downwards and X grows to the right.
+type GridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// compareGridPos orders grid positions the way they appear on screen:
+// top to bottom, then left to right.
+func compareGridPos(a, b GridPos) int {
+	if c := cmp.Compare(a.Y, b.Y); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.X, b.X)
+}
+
+// RowPanel represents a top level dashboard panel. Row panels (type "row")
+// can hold the nested panels of a collapsed row; any other top level panel
+// is a regular panel placed directly on the dashboard grid.
 type RowPanel struct {
-	Title       string   `json:"title"`
-	Description string   `json:"description"`
-	Type        string   `json:"type"`
-	Targets     []Target `json:"targets"`
-	Panels      []Panel  `json:"panels"`
+	Panel
+	Collapsed bool    `json:"collapsed"`
+	Panels    []Panel `json:"panels"`
 }
 
 // Panel represents a standard dashboard panel with its metadata and query targets.
 type Panel struct {
-	Title       string   `json:"title"`
-	Description string   `json:"description"`
-	Type        string   `json:"type"`
-	Targets     []Target `json:"targets"`
+	ID           int              `json:"id"`
+	Title        string           `json:"title"`
+	Description  string           `json:"description"`
+	Type         string           `json:"type"`
+	GridPos      GridPos          `json:"gridPos"`
+	LibraryPanel *LibraryPanelRef `json:"libraryPanel"`
+	Datasource   *Datasource      `json:"datasource"`
+	Targets      []Target         `json:"targets"`
+	FieldConfig  FieldConfig      `json:"fieldConfig"`
+	// Transformations contains the transformations applied, in order, to the
+	// query results before they are visualized
+	Transformations []Transformation `json:"transformations"`
+
+	// pointer is the JSON pointer of the panel in the dashboard file, or in
+	// its source file if it has one, used to locate diagnostics
+	pointer string
+	// source is the file the panel's content was read from when it is not the
+	// dashboard file, e.g. the exported library panel it was resolved from
+	source *panelSource
+	// targetPointerFormat is the format of the JSON pointer of a target
+	// relative to the panel, given the target index; /targets/%d if empty
+	targetPointerFormat string
+	// migratedFrom is the deprecated panel type the panel was migrated from, if any
+	migratedFrom string
+}
+
+// targetPointer returns the JSON pointer of the i-th target relative to the
+// panel's pointer.
+func (p *Panel) targetPointer(i int) string {
+	return fmt.Sprintf(cmp.Or(p.targetPointerFormat, "/targets/%d"), i)
+}
+
+// FieldConfig represents the field configuration of a panel, which controls
+// how the values of its queries are displayed.
+type FieldConfig struct {
+	Defaults  FieldDefaults   `json:"defaults"`
+	Overrides []FieldOverride `json:"overrides"`
+}
+
+// FieldDefaults represents the field configuration applied to every field of
+// a panel. The same options are set for the fields matched by an override.
+type FieldDefaults struct {
+	// Unit is the unit of the values, e.g. "s", "bytes" or "percentunit"
+	Unit string `json:"unit,omitempty"`
+	// DisplayName replaces the name of the fields
+	DisplayName string `json:"displayName,omitempty"`
+	// Decimals is the number of decimals the values are displayed with
+	Decimals *int `json:"decimals,omitempty"`
+	// Min is the minimum of the value range, e.g. of a gauge
+	Min *float64 `json:"min,omitempty"`
+	// Max is the maximum of the value range, e.g. of a gauge
+	Max *float64 `json:"max,omitempty"`
+	// Thresholds contains the thresholds the values are colored by
+	Thresholds *Thresholds `json:"thresholds,omitempty"`
+	// Mappings contains the value mappings replacing values with a text
+	Mappings ValueMappings `json:"mappings,omitempty"`
+	// Color is the color scheme of the values
+	Color *FieldColor `json:"color,omitempty"`
+}
+
+// Thresholds represents the thresholds of a panel. Each step applies its
+// color from its value upwards; the first step has no value and sets the
+// base color.
+type Thresholds struct {
+	// Mode is absolute or percentage
+	Mode string `json:"mode"`
+	// Steps contains the threshold steps in increasing order of value
+	Steps []ThresholdStep `json:"steps"`
+}
+
+// ThresholdStep represents a step of the thresholds of a panel.
+type ThresholdStep struct {
+	Color string   `json:"color"`
+	Value *float64 `json:"value,omitempty"`
+}
+
+// String returns a human readable representation of the thresholds, e.g.
+// "green, red above 80".
+func (t *Thresholds) String() string {
+	if t == nil {
+		return ""
+	}
+	unit := ""
+	if t.Mode == "percentage" {
+		unit = "%"
+	}
+	steps := make([]string, 0, len(t.Steps))
+	for _, step := range t.Steps {
+		if step.Value == nil {
+			steps = append(steps, step.Color)
+			continue
+		}
+		steps = append(steps, fmt.Sprintf("%s above %s%s", step.Color, strconv.FormatFloat(*step.Value, 'f', -1, 64), unit))
+	}
+	return strings.Join(steps, ", ")
+}
+
+// Row represents a dashboard row together with the panels displayed under it,
+// in on-screen order. Panels placed above the first row belong to an implicit
+// row with an empty title.
+type Row struct {
+	Title     string
+	Collapsed bool
+	Panels    []Panel
+}
+
+// StringList is a list of strings that also accepts a single JSON string,
+// as used by the "text" and "value" fields of a variable's current value.
+type StringList []string
+
+// UnmarshalJSON decodes either a JSON string or an array of strings.
+func (s *StringList) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*s = StringList{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*s = list
+	return nil
+}
+
+// VariableQuery holds the query of a template variable. Depending on the
+// variable type and Grafana version the query is either a plain string or an
+// object carrying the query in its "query" field.
+type VariableQuery string
+
+// UnmarshalJSON decodes either a plain string query or a {"query": "..."} object.
+func (q *VariableQuery) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*q = VariableQuery(single)
+		return nil
+	}
+
+	var obj struct {
+		Query string `json:"query"`
+	}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return err
+	}
+	*q = VariableQuery(obj.Query)
+	return nil
+}
+
+// VariableCurrent represents the currently selected (default) value of a template variable.
+type VariableCurrent struct {
+	Text  StringList `json:"text"`
+	Value StringList `json:"value"`
+}
+
+// VariableOption represents one of the values offered by a template variable.
+type VariableOption struct {
+	Text     StringList `json:"text"`
+	Value    StringList `json:"value"`
+	Selected bool       `json:"selected"`
+}
+
+// Variable represents a dashboard template variable such as $namespace or $cluster.
+// Type is one of query, custom, interval, datasource, constant, textbox or adhoc.
+type Variable struct {
+	Name       string           `json:"name"`
+	Label      string           `json:"label"`
+	Type       string           `json:"type"`
+	Datasource *Datasource      `json:"datasource"`
+	Query      VariableQuery    `json:"query"`
+	Definition string           `json:"definition"`
+	Current    VariableCurrent  `json:"current"`
+	Options    []VariableOption `json:"options"`
+	Multi      bool             `json:"multi"`
+	IncludeAll bool             `json:"includeAll"`
+	Regex      string           `json:"regex"`
+
+	// pointer is the JSON pointer of the variable in the dashboard file, used
+	// to locate diagnostics; /templating/list/<index> if empty
+	pointer string
+}
+
+// GetQuery returns the query that best describes how the variable is populated.
+// The human readable definition is preferred over the raw query when present.
+func (v *Variable) GetQuery() string {
+	if v.Definition != "" {
+		return v.Definition
+	}
+	return string(v.Query)
+}
+
+// GetCurrent returns the variable's current value as displayed in Grafana,
+// joining multiple selected values with a comma.
+func (v *Variable) GetCurrent() string {
+	if len(v.Current.Text) > 0 {
+		return strings.Join(v.Current.Text, ", ")
+	}
+	return strings.Join(v.Current.Value, ", ")
+}
+
+// Templating represents the templating section of a dashboard which holds
+// all template variable definitions.
+type Templating struct {
+	List []Variable `json:"list"`
+}
+
+// DashboardInput is an input of a dashboard exported with "Export for sharing
+// externally", listed in its __inputs section. Grafana asks for a value of
+// every input when the dashboard is imported: a datasource of the plugin for
+// datasource inputs, or a text for constant inputs. The dashboard references
+// inputs as ${NAME}.
+type DashboardInput struct {
+	// Name is the input name, e.g. DS_PROMETHEUS
+	Name string `json:"name"`
+	// Label is the label shown on import
+	Label string `json:"label,omitempty"`
+	// Description is the description shown on import
+	Description string `json:"description,omitempty"`
+	// Type is the input type: "datasource" or "constant"
+	Type string `json:"type"`
+	// PluginID is the plugin type of a datasource input, e.g. prometheus
+	PluginID string `json:"pluginId,omitempty"`
+	// PluginName is the display name of the plugin of a datasource input
+	PluginName string `json:"pluginName,omitempty"`
+	// Value is the default value of a constant input
+	Value string `json:"value,omitempty"`
+}
+
+// DashboardRequirement is a plugin, or the Grafana version, required by a
+// dashboard exported with "Export for sharing externally", listed in its
+// __requires section.
+type DashboardRequirement struct {
+	// Type is the requirement type: "grafana", "datasource" or "panel"
+	Type string `json:"type"`
+	// ID is the plugin ID, e.g. prometheus or timeseries
+	ID string `json:"id"`
+	// Name is the display name of the plugin
+	Name string `json:"name,omitempty"`
+	// Version is the plugin or Grafana version the dashboard was exported with
+	Version string `json:"version,omitempty"`
 }
 
 // Dashboard represents a complete Grafana dashboard with its metadata, links, and panels.
 type Dashboard struct {
-	Title       string     `json:"title"`
-	Description string     `json:"description"`
-	Links       []Link     `json:"links"`
-	Panels      []RowPanel `json:"panels"`
+	// SchemaVersion is the version of the dashboard schema, used to migrate
+	// older dashboards
+	SchemaVersion int                    `json:"schemaVersion"`
+	UID           string                 `json:"uid"`
+	Title         string                 `json:"title"`
+	Tags          []string               `json:"tags"`
+	Description   string                 `json:"description"`
+	Links         []Link                 `json:"links"`
+	Panels        []RowPanel             `json:"panels"`
+	Templating    Templating             `json:"templating"`
+	Inputs        []DashboardInput       `json:"__inputs"`
+	Requires      []DashboardRequirement `json:"__requires"`
 }
 
-// GetPanels returns all panels from the dashboard, flattening the hierarchy
-// by extracting panels from row panels and converting row panels themselves
-// to regular panels. This provides a unified view of all dashboard content.
-func (d *Dashboard) GetPanels() []Panel {
-	var panels []Panel
-	for _, rowPanel := range d.Panels {
-		panels = append(panels, rowPanel.Panels...)
+// inputVariables returns the inputs of a dashboard exported for sharing
+// externally as template variables, so that ${DS_PROMETHEUS} datasource
+// placeholders resolve to the input's plugin type and constant inputs to
+// their value. The variables are only used for interpolation and are not
+// documented as template variables.
+func (d *Dashboard) inputVariables() []Variable {
+	var variables []Variable
+	for _, input := range d.Inputs {
+		switch input.Type {
+		case "datasource":
+			variables = append(variables, Variable{Name: input.Name, Type: "datasource", Query: VariableQuery(input.PluginID)})
+		case "constant":
+			variables = append(variables, Variable{Name: input.Name, Type: "constant", Query: VariableQuery(input.Value)})
+		}
+	}
+	return variables
+}
+
+// GetRows rebuilds the dashboard layout from the panels' grid positions and
+// the row boundaries. Top level panels are ordered by gridPos and assigned to
+// the row above them; collapsed rows keep their nested panels. Both collapsed
+// and expanded rows are therefore returned with the panels shown under them.
+func (d *Dashboard) GetRows() []Row {
+	items := slices.Clone(d.Panels)
+	slices.SortStableFunc(items, func(a, b RowPanel) int {
+		return compareGridPos(a.GridPos, b.GridPos)
+	})
+
+	rows := []Row{{}}
+	for _, item := range items {
+		if item.Type == "row" {
+			panels := slices.Clone(item.Panels)
+			slices.SortStableFunc(panels, func(a, b Panel) int {
+				return compareGridPos(a.GridPos, b.GridPos)
+			})
+			rows = append(rows, Row{
+				Title:     item.Title,
+				Collapsed: item.Collapsed,
+				Panels:    panels,
+			})
+			continue
+		}
+		current := &rows[len(rows)-1]
+		current.Panels = append(current.Panels, item.GetPanel())
 	}
-	for _, panel := range d.Panels {
-		panels = append(panels, panel.GetPanel())
+
+	if len(rows[0].Panels) == 0 {
+		rows = rows[1:]
 	}
+	return rows
+}
 
+// GetPanels returns all non-row panels from the dashboard in on-screen order,
+// flattening the row hierarchy returned by GetRows.
+func (d *Dashboard) GetPanels() []Panel {
+	var panels []Panel
+	for _, row := range d.GetRows() {
+		panels = append(panels, row.Panels...)
+	}
 	return panels
 }
 
-// GetPanel converts a RowPanel to a regular Panel by copying its metadata.
-// This allows row panels to be treated uniformly with other panel types
+// setPointers records the JSON pointer of every panel, including panels
+// nested in collapsed rows, so that diagnostics can be located in the file.
+// Panels converted from another schema already have a pointer and keep it.
+func (d *Dashboard) setPointers() {
+	for i := range d.Panels {
+		if d.Panels[i].pointer == "" {
+			d.Panels[i].pointer = fmt.Sprintf("/panels/%d", i)
+		}
+		for j := range d.Panels[i].Panels {
+			if d.Panels[i].Panels[j].pointer == "" {
+				d.Panels[i].Panels[j].pointer = fmt.Sprintf("/panels/%d/panels/%d", i, j)
+			}
+		}
+	}
+}
+
+// GetPanel returns the regular Panel of a top level RowPanel. This allows top
+// level panels to be treated uniformly with panels nested in collapsed rows
 // during documentation generation.
 func (r *RowPanel) GetPanel() Panel {
-	var panel Panel
-	panel.Title = r.Title
-	panel.Description = r.Description
-	panel.Type = r.Type
-	panel.Targets = r.Targets
-
-	return panel
+	return r.Panel
 }
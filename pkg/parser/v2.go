@@ -0,0 +1,435 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// dashboardV2 is a dashboard in the schema v2 of Grafana. Panels are kept in
+// a map of elements, referenced by name from a separate layout tree of grids,
+// rows, tabs and auto grids. Both the v2alpha1 and the v2beta1 forms of the
+// schema are supported.
+type dashboardV2 struct {
+	Metadata resourceMetadata `json:"metadata"`
+	Spec     struct {
+		Title       string                 `json:"title"`
+		Description string                 `json:"description"`
+		Tags        []string               `json:"tags"`
+		Links       []Link                 `json:"links"`
+		Elements    map[string]v2Element   `json:"elements"`
+		Layout      v2Layout               `json:"layout"`
+		Variables   []v2Variable           `json:"variables"`
+		Inputs      []DashboardInput       `json:"__inputs"`
+		Requires    []DashboardRequirement `json:"__requires"`
+	} `json:"spec"`
+}
+
+// v2Kind is the {kind, spec} structure of every object of the schema v2.
+type v2Kind[T any] struct {
+	Kind string `json:"kind"`
+	Spec T      `json:"spec"`
+}
+
+// v2Element is a panel or a library panel reference of a schema v2 dashboard.
+type v2Element struct {
+	Kind string `json:"kind"`
+	Spec struct {
+		ID           int              `json:"id"`
+		Title        string           `json:"title"`
+		Description  string           `json:"description"`
+		LibraryPanel *LibraryPanelRef `json:"libraryPanel"`
+		Data         v2Kind[struct {
+			Queries         []v2Kind[v2PanelQuery] `json:"queries"`
+			Transformations []v2Transformation     `json:"transformations"`
+		}] `json:"data"`
+		VizConfig struct {
+			Kind  string `json:"kind"`
+			Group string `json:"group"`
+			Spec  struct {
+				FieldConfig FieldConfig `json:"fieldConfig"`
+			} `json:"spec"`
+		} `json:"vizConfig"`
+	} `json:"spec"`
+}
+
+// v2PanelQuery is a query of a schema v2 panel.
+type v2PanelQuery struct {
+	RefID      string      `json:"refId"`
+	Datasource *Datasource `json:"datasource"`
+	Query      v2DataQuery `json:"query"`
+}
+
+// v2Transformation is a transformation of a schema v2 panel. In v2alpha1 its
+// kind is the transformation ID; in v2beta1 its kind is Transformation and its
+// group is the transformation ID.
+type v2Transformation struct {
+	Kind  string         `json:"kind"`
+	Group string         `json:"group"`
+	Spec  Transformation `json:"spec"`
+}
+
+// transformation returns the transformation with its ID.
+func (t v2Transformation) transformation() Transformation {
+	transformation := t.Spec
+	if transformation.ID == "" {
+		transformation.ID = t.Kind
+		if t.Kind == "Transformation" {
+			transformation.ID = t.Group
+		}
+	}
+	return transformation
+}
+
+// v2DataQuery is the datasource specific part of a schema v2 query. In
+// v2alpha1 its kind is the datasource plugin type; in v2beta1 its kind is
+// DataQuery, its group is the plugin type and it names the datasource.
+type v2DataQuery struct {
+	Kind       string `json:"kind"`
+	Group      string `json:"group"`
+	Datasource *struct {
+		Name string `json:"name"`
+	} `json:"datasource"`
+	Spec json.RawMessage `json:"spec"`
+}
+
+// datasource returns the datasource a query reads from, preferring the
+// reference of the query over the one of its panel query.
+func (q v2DataQuery) datasource(ref *Datasource) Datasource {
+	var ds Datasource
+	if ref != nil {
+		ds = *ref
+	}
+	if q.Kind == "DataQuery" {
+		if q.Group != "" {
+			ds.Type = q.Group
+		}
+		if q.Datasource != nil && q.Datasource.Name != "" {
+			ds.UID = q.Datasource.Name
+		}
+	} else if ds.Type == "" {
+		ds.Type = q.Kind
+	}
+	return ds
+}
+
+// v2Variable is a variable of a schema v2 dashboard. Its spec holds the
+// fields of every kind of variable.
+type v2Variable struct {
+	Kind string         `json:"kind"`
+	Spec v2VariableSpec `json:"spec"`
+}
+
+// v2VariableSpec is the spec of a schema v2 variable.
+type v2VariableSpec struct {
+	Name        string          `json:"name"`
+	Label       string          `json:"label"`
+	Datasource  *Datasource     `json:"datasource"`
+	PluginID    string          `json:"pluginId"`
+	Query       json.RawMessage `json:"query"`
+	Definition  string          `json:"definition"`
+	Current     VariableCurrent `json:"current"`
+	Multi       bool            `json:"multi"`
+	IncludeAll  bool            `json:"includeAll"`
+	Regex       string          `json:"regex"`
+	Description string          `json:"description"`
+}
+
+// v2VariableTypes maps the kinds of schema v2 variables to the variable types
+// of the schema v1.
+var v2VariableTypes = map[string]string{
+	"QueryVariable":      "query",
+	"CustomVariable":     "custom",
+	"ConstantVariable":   "constant",
+	"IntervalVariable":   "interval",
+	"TextVariable":       "textbox",
+	"DatasourceVariable": "datasource",
+	"AdhocVariable":      "adhoc",
+	"GroupByVariable":    "groupby",
+	"SwitchVariable":     "switch",
+}
+
+// v2Layout is a node of the layout tree of a schema v2 dashboard.
+type v2Layout struct {
+	Kind string          `json:"kind"`
+	Spec json.RawMessage `json:"spec"`
+}
+
+// v2ElementReference references an element of a schema v2 dashboard by name.
+type v2ElementReference struct {
+	Name string `json:"name"`
+}
+
+// v2GridItem is an element placed on a grid layout, or a row of a v2alpha1
+// grid layout holding its own grid items.
+type v2GridItem struct {
+	X         int                  `json:"x"`
+	Y         int                  `json:"y"`
+	Width     int                  `json:"width"`
+	Height    int                  `json:"height"`
+	Element   v2ElementReference   `json:"element"`
+	Title     string               `json:"title"`
+	Collapsed bool                 `json:"collapsed"`
+	Elements  []v2Kind[v2GridItem] `json:"elements"`
+}
+
+// v2Section is a row or a tab of a schema v2 layout with the panels shown in
+// it. The panels of a layout outside of any row or tab are held by a section
+// that is not a row.
+type v2Section struct {
+	Title     string
+	Collapsed bool
+	Row       bool
+	Panels    []Panel
+}
+
+// toDashboard converts a schema v2 dashboard into the schema v1 structure used
+// to document dashboards: every row and tab of the layout becomes a row, in
+// on-screen order, holding the panels of its elements. Tabs and rows nested in
+// rows or tabs are flattened into rows titled after their path, e.g.
+// "Service / Latency". Panels and variables keep the JSON pointer of their v2
+// definition so that diagnostics are located in the file.
+func (d *dashboardV2) toDashboard() Dashboard {
+	dash := Dashboard{
+		UID:         d.Metadata.Name,
+		Title:       d.Spec.Title,
+		Tags:        d.Spec.Tags,
+		Description: d.Spec.Description,
+		Links:       d.Spec.Links,
+		Inputs:      d.Spec.Inputs,
+		Requires:    d.Spec.Requires,
+	}
+
+	y := 0
+	for _, section := range d.sections(d.Spec.Layout, "") {
+		if !section.Row {
+			slices.SortStableFunc(section.Panels, func(a, b Panel) int {
+				return compareGridPos(a.GridPos, b.GridPos)
+			})
+			for _, panel := range section.Panels {
+				panel.GridPos = GridPos{H: panel.GridPos.H, W: panel.GridPos.W, Y: y}
+				dash.Panels = append(dash.Panels, RowPanel{Panel: panel})
+				y++
+			}
+			continue
+		}
+		dash.Panels = append(dash.Panels, RowPanel{
+			Panel:     Panel{Type: "row", Title: section.Title, GridPos: GridPos{Y: y}, pointer: "/spec/layout"},
+			Collapsed: section.Collapsed,
+			Panels:    section.Panels,
+		})
+		y++
+	}
+
+	for i, v := range d.Spec.Variables {
+		dash.Templating.List = append(dash.Templating.List, v.toVariable(fmt.Sprintf("/spec/variables/%d/spec", i)))
+	}
+	return dash
+}
+
+// sections returns the sections of a layout in on-screen order. Sections
+// holding no panels are only kept for rows and tabs without nested sections,
+// so that empty rows are documented like in schema v1 dashboards.
+//
+// Parameters:
+//   - layout: the layout to walk
+//   - path: the title of the row or tab holding the layout, if any
+func (d *dashboardV2) sections(layout v2Layout, path string) []v2Section {
+	top := v2Section{}
+	var nested []v2Section
+
+	switch layout.Kind {
+	case "GridLayout":
+		var spec struct {
+			Items []v2Kind[v2GridItem] `json:"items"`
+		}
+		_ = json.Unmarshal(layout.Spec, &spec)
+		slices.SortStableFunc(spec.Items, func(a, b v2Kind[v2GridItem]) int {
+			return compareGridPos(GridPos{X: a.Spec.X, Y: a.Spec.Y}, GridPos{X: b.Spec.X, Y: b.Spec.Y})
+		})
+		for _, item := range spec.Items {
+			if item.Kind != "GridLayoutRow" {
+				top.Panels = d.appendPanel(top.Panels, item.Spec)
+				continue
+			}
+			row := v2Section{Title: joinTitle(path, item.Spec.Title), Collapsed: item.Spec.Collapsed, Row: true}
+			for _, element := range item.Spec.Elements {
+				row.Panels = d.appendPanel(row.Panels, element.Spec)
+			}
+			nested = append(nested, row)
+		}
+	case "AutoGridLayout", "ResponsiveGridLayout":
+		var spec struct {
+			Items []v2Kind[v2GridItem] `json:"items"`
+		}
+		_ = json.Unmarshal(layout.Spec, &spec)
+		for i, item := range spec.Items {
+			item.Spec.Y = i
+			top.Panels = d.appendPanel(top.Panels, item.Spec)
+		}
+	case "RowsLayout", "TabsLayout":
+		var spec struct {
+			Rows []v2Kind[struct {
+				Title      string   `json:"title"`
+				Collapse   bool     `json:"collapse"`
+				HideHeader bool     `json:"hideHeader"`
+				Layout     v2Layout `json:"layout"`
+			}] `json:"rows"`
+			Tabs []v2Kind[struct {
+				Title  string   `json:"title"`
+				Layout v2Layout `json:"layout"`
+			}] `json:"tabs"`
+		}
+		_ = json.Unmarshal(layout.Spec, &spec)
+		for _, row := range spec.Rows {
+			title := path
+			if !row.Spec.HideHeader {
+				title = joinTitle(path, row.Spec.Title)
+			}
+			nested = append(nested, d.childSections(row.Spec.Layout, title, row.Spec.Collapse)...)
+		}
+		for _, tab := range spec.Tabs {
+			nested = append(nested, d.childSections(tab.Spec.Layout, joinTitle(path, tab.Spec.Title), false)...)
+		}
+	}
+
+	if len(top.Panels) > 0 {
+		return append([]v2Section{top}, nested...)
+	}
+	return nested
+}
+
+// childSections returns the sections of the layout of a row or a tab: the
+// row or tab itself, holding the panels placed directly in it, followed by
+// the rows and tabs nested in it.
+func (d *dashboardV2) childSections(layout v2Layout, title string, collapsed bool) []v2Section {
+	section := v2Section{Title: title, Collapsed: collapsed, Row: true}
+	nested := d.sections(layout, title)
+	if len(nested) > 0 && !nested[0].Row {
+		section.Panels = nested[0].Panels
+		nested = nested[1:]
+	}
+	if len(section.Panels) == 0 && len(nested) > 0 {
+		return nested
+	}
+	return append([]v2Section{section}, nested...)
+}
+
+// appendPanel appends the panel of the element a grid item references. Items
+// referencing a missing element are skipped, as Grafana does.
+func (d *dashboardV2) appendPanel(panels []Panel, item v2GridItem) []Panel {
+	element, ok := d.Spec.Elements[item.Element.Name]
+	if !ok {
+		return panels
+	}
+	panel := element.toPanel("/spec/elements/" + escapePointer(item.Element.Name) + "/spec")
+	panel.GridPos = GridPos{H: item.Height, W: item.Width, X: item.X, Y: item.Y}
+	return append(panels, panel)
+}
+
+// toPanel converts a schema v2 element into a panel.
+//
+// Parameters:
+//   - pointer: the JSON pointer of the element's spec in the file
+func (e v2Element) toPanel(pointer string) Panel {
+	panel := Panel{
+		ID:                  e.Spec.ID,
+		Title:               e.Spec.Title,
+		Description:         e.Spec.Description,
+		Type:                e.Spec.VizConfig.Kind,
+		LibraryPanel:        e.Spec.LibraryPanel,
+		FieldConfig:         e.Spec.VizConfig.Spec.FieldConfig,
+		pointer:             pointer,
+		targetPointerFormat: "/data/spec/queries/%d/spec/query/spec",
+	}
+	if e.Spec.VizConfig.Kind == "VizConfig" {
+		panel.Type = e.Spec.VizConfig.Group
+	}
+	for _, t := range e.Spec.Data.Spec.Transformations {
+		panel.Transformations = append(panel.Transformations, t.transformation())
+	}
+
+	var datasources []Datasource
+	for _, query := range e.Spec.Data.Spec.Queries {
+		ds := query.Spec.Query.datasource(query.Spec.Datasource)
+		fields := map[string]json.RawMessage{}
+		_ = json.Unmarshal(query.Spec.Query.Spec, &fields)
+		fields["refId"], _ = json.Marshal(query.Spec.RefID)
+		fields["datasource"], _ = json.Marshal(ds)
+
+		var target Target
+		bs, _ := json.Marshal(fields)
+		if err := json.Unmarshal(bs, &target); err != nil {
+			// keep a target with the raw query, so that the following targets
+			// keep the index of their query and the extractor reports the error
+			target = Target{RefID: query.Spec.RefID, Datasource: ds, Raw: bs}
+		}
+		panel.Targets = append(panel.Targets, target)
+		if !slices.Contains(datasources, ds) {
+			datasources = append(datasources, ds)
+		}
+	}
+	switch len(datasources) {
+	case 0:
+	case 1:
+		panel.Datasource = &datasources[0]
+	default:
+		panel.Datasource = &mixedDatasource
+	}
+	return panel
+}
+
+// toVariable converts a schema v2 variable into a template variable.
+//
+// Parameters:
+//   - pointer: the JSON pointer of the variable's spec in the file
+func (v v2Variable) toVariable(pointer string) Variable {
+	spec := v.Spec
+	variable := Variable{
+		Name:       spec.Name,
+		Label:      spec.Label,
+		Type:       v2VariableTypes[v.Kind],
+		Datasource: spec.Datasource,
+		Definition: spec.Definition,
+		Current:    spec.Current,
+		Multi:      spec.Multi,
+		IncludeAll: spec.IncludeAll,
+		Regex:      spec.Regex,
+		pointer:    pointer,
+	}
+	if variable.Type == "" {
+		variable.Type = strings.ToLower(strings.TrimSuffix(v.Kind, "Variable"))
+	}
+
+	var query v2DataQuery
+	if err := json.Unmarshal(spec.Query, &query); err == nil && query.Kind != "" {
+		ds := query.datasource(spec.Datasource)
+		if ds != (Datasource{}) {
+			variable.Datasource = &ds
+		}
+		_ = json.Unmarshal(query.Spec, &variable.Query)
+	} else {
+		_ = json.Unmarshal(spec.Query, &variable.Query)
+	}
+	if variable.Type == "datasource" {
+		variable.Query = VariableQuery(spec.PluginID)
+	}
+	return variable
+}
+
+// joinTitle returns the title of a row or tab nested in the row or tab at path.
+func joinTitle(path, title string) string {
+	if path == "" {
+		return title
+	}
+	if title == "" {
+		return path
+	}
+	return path + " / " + title
+}
+
+// escapePointer escapes a key for use as a JSON pointer (RFC 6901) token.
+func escapePointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
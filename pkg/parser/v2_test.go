@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildDocumentationFromSchemaV2(t *testing.T) {
+	t.Run("v1 and v2 forms of a dashboard should produce equivalent docs", func(t *testing.T) {
+		v1, err := BuildDocumentation("testdata/service_dashboard.json", Options{Strict: true})
+		assert.NoError(t, err)
+		v2, err := BuildDocumentation("testdata/service_dashboard_v2.json", Options{Strict: true})
+		assert.NoError(t, err)
+
+		assert.Equal(t, v1.UID, v2.UID)
+		assert.Equal(t, v1.Title, v2.Title)
+		assert.Equal(t, v1.Description, v2.Description)
+		assert.Equal(t, v1.Tags, v2.Tags)
+		assert.Equal(t, v1.Rows, v2.Rows)
+		assert.Equal(t, v1.Variables, v2.Variables)
+		latency := v2.Rows[1].Panels[1]
+		assert.Equal(t, "Latency", latency.Title)
+		assert.Equal(t, []TransformationDoc{{ID: "renameByRegex", Description: `rename the fields matching /Value/ to "p99"`}}, latency.Transformations)
+
+		var v1Markdown, v2Markdown bytes.Buffer
+		assert.NoError(t, Render(&v1Markdown, v1, FormatMarkdown))
+		assert.NoError(t, Render(&v2Markdown, v2, FormatMarkdown))
+		assert.Equal(t, v1Markdown.String(), v2Markdown.String())
+	})
+
+	t.Run("nested rows and tabs should be flattened into rows titled after their path", func(t *testing.T) {
+		doc, err := BuildDocumentation("testdata/tabs_dashboard_v2.json", Options{})
+		assert.NoError(t, err)
+
+		var titles [][]string
+		for _, row := range doc.Rows {
+			rowTitles := []string{row.Title}
+			for _, panel := range row.Panels {
+				rowTitles = append(rowTitles, panel.Title)
+			}
+			titles = append(titles, rowTitles)
+		}
+		assert.Equal(t, [][]string{
+			{"Summary", "Overview"},
+			{"Resources / Compute", "CPU", "Memory"},
+			{"Resources / Storage", "Disk"},
+		}, titles)
+		assert.Equal(t, "text", doc.Rows[0].Panels[0].Type)
+		assert.Equal(t, []string{"node_cpu_seconds_total"}, doc.Rows[1].Panels[0].Metrics)
+		assert.Equal(t, &Datasource{Type: "prometheus", UID: "prom"}, doc.Rows[1].Panels[0].Datasource)
+	})
+
+	t.Run("diagnostics should point into the v2 elements", func(t *testing.T) {
+		doc, err := BuildDocumentation("testdata/tabs_dashboard_v2.json", Options{})
+		assert.NoError(t, err)
+
+		var errors []Diagnostic
+		for _, diag := range doc.Diagnostics {
+			if diag.Severity == SeverityError {
+				errors = append(errors, diag)
+			}
+		}
+		assert.Len(t, errors, 1)
+		assert.Equal(t, "/spec/elements/memory/spec/data/spec/queries/0/spec/query/spec/expr", errors[0].Pointer)
+		assert.Equal(t, 55, errors[0].Line)
+	})
+
+	t.Run("queries that cannot be decoded should keep the index of the following queries", func(t *testing.T) {
+		bs := []byte(`{"apiVersion": "dashboard.grafana.app/v2beta1", "kind": "Dashboard", "spec": {"title": "Queries",
+  "elements": {"p": {"kind": "Panel", "spec": {"id": 1, "title": "P", "description": "Two queries", "data": {"kind": "QueryGroup", "spec": {"queries": [
+    {"kind": "PanelQuery", "spec": {"refId": "A", "datasource": {"type": "prometheus", "uid": "prom"}, "query": {"kind": "prometheus", "spec": {"expr": 42}}}},
+    {"kind": "PanelQuery", "spec": {"refId": "B", "datasource": {"type": "prometheus", "uid": "prom"}, "query": {"kind": "prometheus", "spec": {"expr": "sum(up"}}}}
+  ]}}, "vizConfig": {"kind": "timeseries", "spec": {}}}}},
+  "layout": {"kind": "GridLayout", "spec": {"items": [{"kind": "GridLayoutItem", "spec": {"x": 0, "y": 0, "width": 12, "height": 8, "element": {"kind": "ElementReference", "name": "p"}}}]}}}}`)
+		doc, err := BuildDocumentationFromBytes("queries.json", bs, Options{})
+		assert.NoError(t, err)
+
+		targets := doc.Panels()[0].Targets
+		assert.Len(t, targets, 2)
+		assert.Equal(t, "A", targets[0].RefID)
+		assert.NotEmpty(t, targets[0].Error)
+		assert.Equal(t, "B", targets[1].RefID)
+
+		var pointers []string
+		for _, diag := range doc.Diagnostics {
+			if diag.Severity == SeverityError {
+				pointers = append(pointers, diag.Pointer)
+			}
+		}
+		assert.Equal(t, []string{
+			"/spec/elements/p/spec/data/spec/queries/0/spec/query/spec",
+			"/spec/elements/p/spec/data/spec/queries/1/spec/query/spec/expr",
+		}, pointers)
+	})
+}
@@ -0,0 +1,175 @@
+package parser
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/rastogiji/autodoc-grafana/pkg/utils"
+)
+
+var (
+	// labelValuesQuery matches the label_values(metric, label) and label_values(label)
+	// variable query functions of the Prometheus datasource.
+	labelValuesQuery = regexp.MustCompile(`^\s*label_values\s*\(([\s\S]*)\)\s*$`)
+	// queryResultQuery matches the query_result(expr) variable query function.
+	queryResultQuery = regexp.MustCompile(`^\s*query_result\s*\(([\s\S]*)\)\s*$`)
+	// metricsQuery matches the metrics(regex) variable query function.
+	metricsQuery = regexp.MustCompile(`^\s*metrics\s*\(([\s\S]*)\)\s*$`)
+)
+
+// variableData represents a single dashboard template variable formatted
+// for documentation purposes.
+type variableData struct {
+	// Name is the variable name without the leading $
+	Name string
+	// Label is the display label shown in the dashboard
+	Label string
+	// Type is the variable type (e.g., "query", "custom", "interval")
+	Type string
+	// Datasource is a human readable reference to the variable's datasource
+	Datasource string
+	// Query is the query or definition used to populate the variable
+	Query string
+	// Current is the default (currently selected) value of the variable
+	Current string
+	// Multi indicates whether multiple values can be selected
+	Multi bool
+	// IncludeAll indicates whether an "All" option is offered
+	IncludeAll bool
+	// Regex is the regex used to filter or capture the variable values
+	Regex string
+	// Metrics contains the metric names referenced by the variable query
+	Metrics []string
+}
+
+// buildVariableDocs converts the dashboard template variables into their
+// documentation model, extracting the metrics referenced by each variable query.
+//
+// Parameters:
+//   - variables: the template variables from the dashboard's templating list
+//   - interpolator: the interpolator used to resolve variables nested in queries
+//
+// Returns the documentation model of each variable and a diagnostic for each
+// variable query referencing a PromQL expression that cannot be parsed.
+func buildVariableDocs(variables []Variable, interpolator *Interpolator) ([]VariableDoc, []Diagnostic) {
+	var docs []VariableDoc
+	var diagnostics []Diagnostic
+	for i, v := range variables {
+		vd := VariableDoc{
+			Name:       v.Name,
+			Label:      escapeTableCell(v.Label),
+			Type:       v.Type,
+			Datasource: v.Datasource,
+			Query:      v.GetQuery(),
+			Current:    v.GetCurrent(),
+			Multi:      v.Multi,
+			IncludeAll: v.IncludeAll,
+			Regex:      v.Regex,
+		}
+		if v.Type == "query" {
+			metrics, err := extractVariableMetrics(v.GetQuery(), interpolator)
+			if err != nil {
+				field := "query"
+				if v.Definition != "" {
+					field = "definition"
+				}
+				diagnostics = append(diagnostics, Diagnostic{
+					Pointer:  cmp.Or(v.pointer, fmt.Sprintf("/templating/list/%d", i)) + "/" + field,
+					Location: fmt.Sprintf("variable %q", v.Name),
+					Severity: SeverityError,
+					Code:     CodeQueryParseError,
+					Message:  singleLine(err.Error()),
+					err:      err,
+				})
+			}
+			vd.Metrics = utils.GetUniqueElements(metrics)
+		}
+		docs = append(docs, vd)
+	}
+	return docs, diagnostics
+}
+
+// newVariableData converts a variable documentation model into its markdown
+// representation, escaping values for use inside a markdown table cell.
+func newVariableData(v VariableDoc) variableData {
+	return variableData{
+		Name:       v.Name,
+		Label:      escapeTableCell(v.Label),
+		Type:       v.Type,
+		Datasource: escapeTableCell(v.Datasource.String()),
+		Query:      escapeTableCell(v.Query),
+		Current:    escapeTableCell(v.Current),
+		Multi:      v.Multi,
+		IncludeAll: v.IncludeAll,
+		Regex:      escapeTableCell(v.Regex),
+		Metrics:    v.Metrics,
+	}
+}
+
+// extractVariableMetrics extracts the metric names referenced by a Prometheus
+// variable query. It understands the label_values, query_result and metrics
+// query functions; any other query (e.g. SQL) yields no metrics.
+//
+// Parameters:
+//   - query: the variable query string
+//   - interpolator: the interpolator used to resolve variables nested in the query
+//
+// Returns a slice of metric names (or metric name patterns for metrics(regex))
+// and an error if the embedded PromQL expression cannot be parsed.
+func extractVariableMetrics(query string, interpolator *Interpolator) ([]string, error) {
+	if m := labelValuesQuery.FindStringSubmatch(query); m != nil {
+		selector, _, found := cutLastTopLevelComma(m[1])
+		if !found {
+			// label_values(label) does not reference any metric
+			return nil, nil
+		}
+		return extractMetricFromExpression(interpolator.Interpolate(selector))
+	}
+	if m := queryResultQuery.FindStringSubmatch(query); m != nil {
+		return extractMetricFromExpression(interpolator.Interpolate(m[1]))
+	}
+	if m := metricsQuery.FindStringSubmatch(query); m != nil {
+		pattern := strings.TrimSpace(m[1])
+		if pattern == "" {
+			return nil, nil
+		}
+		return []string{pattern}, nil
+	}
+	return nil, nil
+}
+
+// cutLastTopLevelComma splits s around the last comma that is neither nested
+// inside brackets nor part of a quoted string.
+func cutLastTopLevelComma(s string) (before, after string, found bool) {
+	depth := 0
+	var quote rune
+	idx := -1
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote && (i == 0 || s[i-1] != '\\') {
+				quote = 0
+			}
+		case r == '"' || r == '\'' || r == '`':
+			quote = r
+		case r == '(' || r == '{' || r == '[':
+			depth++
+		case r == ')' || r == '}' || r == ']':
+			depth--
+		case r == ',' && depth == 0:
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return s, "", false
+	}
+	return strings.TrimSpace(s[:idx]), strings.TrimSpace(s[idx+1:]), true
+}
+
+// escapeTableCell makes a value safe for use inside a markdown table cell by
+// escaping pipes and collapsing newlines.
+func escapeTableCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\r\n", " ", "\n", " ").Replace(s)
+}
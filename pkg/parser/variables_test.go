@@ -0,0 +1,89 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractVariableMetrics(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		expected     []string
+		expectError  bool
+		errorMessage string
+	}{
+		{
+			name:     "label_values with metric selector should return the metric",
+			query:    `label_values(up{job="x"}, instance)`,
+			expected: []string{"up"},
+		}, {
+			name:     "label_values with only a label should return no metrics",
+			query:    "label_values(instance)",
+			expected: nil,
+		}, {
+			name:     "label_values with variables in matchers should return the metric",
+			query:    `label_values(kube_pod_info{cluster="$cluster", pod=~"a,b"}, namespace)`,
+			expected: []string{"kube_pod_info"},
+		}, {
+			name:     "query_result should return all metrics in the expression",
+			query:    "query_result(sum(rate(http_requests_total[$__rate_interval])) / sum(up))",
+			expected: []string{"http_requests_total", "up"},
+		}, {
+			name:     "metrics should return the metric pattern",
+			query:    "metrics(node_cpu.*)",
+			expected: []string{"node_cpu.*"},
+		}, {
+			name:     "non prometheus query should return no metrics",
+			query:    "SELECT DISTINCT name FROM hosts",
+			expected: nil,
+		}, {
+			name:         "bad expression in query_result should return error",
+			query:        "query_result(sum(up)",
+			expectError:  true,
+			errorMessage: "error parsing promql expression",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			metrics, err := extractVariableMetrics(tc.query, NewInterpolator(nil))
+			if tc.expectError {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tc.errorMessage)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, metrics)
+			}
+		})
+	}
+}
+
+func TestNewVariableData(t *testing.T) {
+	tests := []struct {
+		name     string
+		variable VariableDoc
+		expected variableData
+	}{
+		{
+			name:     "plain values should be kept as they are",
+			variable: VariableDoc{Name: "cluster", Label: "Cluster", Type: "query", Datasource: &Datasource{Type: "prometheus", UID: "prom"}, Query: "label_values(up, cluster)"},
+			expected: variableData{Name: "cluster", Label: "Cluster", Type: "query", Datasource: "prometheus (uid: prom)", Query: "label_values(up, cluster)"},
+		}, {
+			name:     "pipes in the label and datasource should be escaped",
+			variable: VariableDoc{Name: "env", Label: "Env | Region", Type: "custom", Datasource: &Datasource{UID: "a|b"}, Query: "a|b,c"},
+			expected: variableData{Name: "env", Label: `Env \| Region`, Type: "custom", Datasource: `a\|b`, Query: `a\|b,c`},
+		}, {
+			name:     "newlines in the label should be replaced with spaces",
+			variable: VariableDoc{Name: "env", Label: "Environment\nname", Type: "custom", Current: "prod\r\nstaging", Regex: "/(a|b)/"},
+			expected: variableData{Name: "env", Label: "Environment name", Type: "custom", Current: "prod staging", Regex: `/(a\|b)/`},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, newVariableData(tc.variable))
+		})
+	}
+}
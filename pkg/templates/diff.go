@@ -0,0 +1,128 @@
+package templates
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"text/template"
+)
+
+var (
+	// diffTemplate contains the Go template string for the markdown report of
+	// the semantic changes between two versions of a dashboard, suitable for a
+	// pull request comment. It contains:
+	//   - A heading with the dashboard title and the compared versions
+	//   - A "Panels" table listing the added, removed and renamed panels
+	//   - A "Queries" section with a diff block per added, removed or changed query
+	//   - A "Metrics" list of the added and removed metrics
+	//   - A "Variables" table listing the added, removed and changed variables
+	//   - A "Thresholds" table listing the panels whose thresholds changed
+	//
+	// Sections without changes are omitted.
+	diffTemplate = `## Dashboard changes: {{markdownEscape .Title}}
+
+Comparing ` + "`{{.From}}`" + ` with ` + "`{{.To}}`" + `.
+{{- if .Empty}}
+
+No semantic changes.
+{{- end}}
+{{- with .TitleChange}}
+
+Dashboard renamed from **{{markdownEscape .From}}** to **{{markdownEscape .To}}**.
+{{- end}}
+{{- if or .AddedPanels .RemovedPanels .RenamedPanels}}
+
+### Panels
+
+| Change | Panel | Type |
+| ------ | ----- | ---- |
+{{- range .AddedPanels}}
+| Added | {{markdownEscape .Title}} | {{panelType .Type}} |
+{{- end}}
+{{- range .RemovedPanels}}
+| Removed | {{markdownEscape .Title}} | {{panelType .Type}} |
+{{- end}}
+{{- range .RenamedPanels}}
+| Renamed | {{markdownEscape .From}} → {{markdownEscape .To}} | |
+{{- end}}
+{{- end}}
+{{- if .ChangedQueries}}
+
+### Queries
+{{- range .ChangedQueries}}
+
+**{{markdownEscape .Panel.Title}}**, query {{markdownEscape .RefID}} {{if not .From}}added{{else if not .To}}removed{{else}}changed{{end}}:
+
+{{codeFence "diff" (diffLines .From .To)}}
+{{- end}}
+{{- end}}
+{{- if or .AddedMetrics .RemovedMetrics}}
+
+### Metrics
+{{range .AddedMetrics}}
+- Added ` + "`{{.}}`" + `
+{{- end}}
+{{- range .RemovedMetrics}}
+- Removed ` + "`{{.}}`" + `
+{{- end}}
+{{- end}}
+{{- if .VariableFields}}
+
+### Variables
+
+| Variable | Change | Field | Before | After |
+| -------- | ------ | ----- | ------ | ----- |
+{{- range .VariableFields}}
+| ` + "`${{.Name}}`" + ` | {{.Kind}} | {{.Field}} | {{markdownEscape .From}} | {{markdownEscape .To}} |
+{{- end}}
+{{- end}}
+{{- if .ChangedThresholds}}
+
+### Thresholds
+
+| Panel | Before | After |
+| ----- | ------ | ----- |
+{{- range .ChangedThresholds}}
+| {{markdownEscape .Panel.Title}} | {{markdownEscape .From}} | {{markdownEscape .To}} |
+{{- end}}
+{{- end}}
+`
+)
+
+// GetDiffTemplate creates and returns the parsed Go template of the markdown
+// report of a dashboard diff, with the helper functions of FuncMap and a
+// diffLines function formatting a change as the content of a diff block.
+//
+// Returns:
+//   - *template.Template: A parsed template ready for execution
+//   - error: An error if template parsing fails
+//
+// The returned template expects the parser package's dashboard diff data.
+func GetDiffTemplate() (*template.Template, error) {
+	funcs := FuncMap()
+	funcs["diffLines"] = diffLines
+	tmpl, err := template.New("diff").Funcs(funcs).Parse(diffTemplate)
+	if err != nil {
+		slog.Error("error generating the markdown diff template", slog.Any("error", err))
+		return nil, fmt.Errorf("error generating the markdown diff template: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+// diffLines formats the old and new values of a change as the lines of a
+// diff block, prefixed with - and + respectively.
+func diffLines(from, to string) string {
+	var lines []string
+	if from != "" {
+		for _, line := range strings.Split(from, "\n") {
+			lines = append(lines, "- "+line)
+		}
+	}
+	if to != "" {
+		for _, line := range strings.Split(to, "\n") {
+			lines = append(lines, "+ "+line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
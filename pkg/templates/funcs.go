@@ -0,0 +1,316 @@
+package templates
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/rastogiji/autodoc-grafana/pkg/utils"
+)
+
+// Group is an element of the list returned by the groupBy template function.
+type Group struct {
+	// Key is the value of the grouping field shared by the items
+	Key string
+	// Items contains the items of the group in their original order
+	Items []any
+}
+
+// panelTypeNames maps the IDs of the core Grafana panel plugins to their
+// display names.
+var panelTypeNames = map[string]string{
+	"alertlist":      "Alert list",
+	"annolist":       "Annotations list",
+	"barchart":       "Bar chart",
+	"bargauge":       "Bar gauge",
+	"candlestick":    "Candlestick",
+	"canvas":         "Canvas",
+	"dashlist":       "Dashboard list",
+	"flamegraph":     "Flame graph",
+	"gauge":          "Gauge",
+	"geomap":         "Geomap",
+	"graph":          "Graph (old)",
+	"heatmap":        "Heatmap",
+	"histogram":      "Histogram",
+	"logs":           "Logs",
+	"news":           "News",
+	"nodeGraph":      "Node graph",
+	"piechart":       "Pie chart",
+	"row":            "Row",
+	"singlestat":     "Singlestat (old)",
+	"stat":           "Stat",
+	"state-timeline": "State timeline",
+	"status-history": "Status history",
+	"table":          "Table",
+	"text":           "Text",
+	"timeseries":     "Time series",
+	"traces":         "Traces",
+	"trend":          "Trend",
+	"xychart":        "XY chart",
+}
+
+// FuncMap returns the helper functions available to custom templates:
+//   - join SEP LIST: joins the elements of a list with a separator
+//   - slugify S: converts a string to a URL and anchor friendly slug
+//   - markdownEscape S: escapes markdown syntax and newlines so that a value
+//     can be used inline or in a table cell
+//   - codeFence LANG S: wraps a string in a fenced code block
+//   - default DEFAULT VALUE: returns VALUE, or DEFAULT if VALUE is empty
+//   - sortBy FIELD LIST: returns a copy of a list sorted by a field
+//   - groupBy FIELD LIST: groups the elements of a list by a field, see Group
+//   - panelType TYPE: returns the display name of a panel type
+//
+// The list is the last argument of join, sortBy and groupBy so that they can
+// be used in pipelines, e.g. {{.Tags | join ", "}}. Fields are struct field
+// names or map keys, and can be nested with dots, e.g. "Datasource.Type".
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"join":           join,
+		"slugify":        utils.Slugify,
+		"markdownEscape": markdownEscape,
+		"codeFence":      codeFence,
+		"default":        defaultValue,
+		"sortBy":         sortBy,
+		"groupBy":        groupBy,
+		"panelType":      panelType,
+	}
+}
+
+// join joins the elements of a list, formatted with fmt.Sprint, with a separator.
+func join(sep string, list any) (string, error) {
+	items, err := listItems(list)
+	if err != nil {
+		return "", fmt.Errorf("join: %w", err)
+	}
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		values = append(values, fmt.Sprint(item.Interface()))
+	}
+	return strings.Join(values, sep), nil
+}
+
+// markdownEscapes escapes the markdown syntax characters and replaces newlines,
+// which would end a table row, with line breaks.
+var markdownEscapes = strings.NewReplacer(
+	`\`, `\\`,
+	"`", "\\`",
+	"*", `\*`,
+	"_", `\_`,
+	"[", `\[`,
+	"]", `\]`,
+	"<", `\<`,
+	">", `\>`,
+	"|", `\|`,
+	"\r\n", "<br>",
+	"\n", "<br>",
+)
+
+// markdownEscape escapes a string so that it is rendered verbatim inline or in
+// a markdown table cell.
+func markdownEscape(s string) string {
+	return markdownEscapes.Replace(s)
+}
+
+// codeFence wraps a string in a fenced code block of the given language. The
+// fence is longer than any backtick run in the string so that it cannot be
+// closed early.
+func codeFence(lang, s string) string {
+	longest, run := 0, 0
+	for _, r := range s {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+	return fence + lang + "\n" + strings.TrimSuffix(s, "\n") + "\n" + fence
+}
+
+// defaultValue returns value, or def if value is nil, false, zero or empty.
+func defaultValue(def, value any) any {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || v.IsZero() {
+		return def
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array:
+		if v.Len() == 0 {
+			return def
+		}
+	}
+	return value
+}
+
+// sortBy returns a copy of a list stably sorted by the given field of its elements.
+func sortBy(field string, list any) (any, error) {
+	items, err := listItems(list)
+	if err != nil {
+		return nil, fmt.Errorf("sortBy: %w", err)
+	}
+	if items == nil {
+		return list, nil
+	}
+	keys := make([]reflect.Value, len(items))
+	for i, item := range items {
+		if keys[i], err = fieldValue(item, field); err != nil {
+			return nil, fmt.Errorf("sortBy: %w", err)
+		}
+	}
+
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return compareValues(keys[a], keys[b])
+	})
+
+	sorted := reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(list).Elem()), 0, len(items))
+	for _, i := range order {
+		sorted = reflect.Append(sorted, items[i])
+	}
+	return sorted.Interface(), nil
+}
+
+// groupBy groups the elements of a list by the given field, in the order the
+// field values first appear in the list. Elements whose field is behind a nil
+// pointer are grouped under an empty key.
+func groupBy(field string, list any) ([]Group, error) {
+	items, err := listItems(list)
+	if err != nil {
+		return nil, fmt.Errorf("groupBy: %w", err)
+	}
+	var groups []Group
+	index := make(map[string]int)
+	for _, item := range items {
+		value, err := fieldValue(item, field)
+		if err != nil {
+			return nil, fmt.Errorf("groupBy: %w", err)
+		}
+		var key string
+		if value.IsValid() {
+			key = fmt.Sprint(value.Interface())
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, Group{Key: key})
+		}
+		groups[i].Items = append(groups[i].Items, item.Interface())
+	}
+	return groups, nil
+}
+
+// panelType returns the display name of a panel type, e.g. "Time series" for
+// timeseries. Unknown types, such as external plugin IDs, are humanized from
+// their ID, e.g. "Grafana clock panel" for grafana-clock-panel.
+func panelType(typ string) string {
+	if name, ok := panelTypeNames[typ]; ok {
+		return name
+	}
+	var b strings.Builder
+	for i, r := range typ {
+		switch {
+		case r == '-' || r == '_':
+			b.WriteByte(' ')
+		case unicode.IsUpper(r) && i > 0:
+			b.WriteByte(' ')
+			b.WriteRune(unicode.ToLower(r))
+		case i == 0:
+			b.WriteRune(unicode.ToUpper(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// listItems returns the elements of a slice or array, or nil for a nil list.
+func listItems(list any) ([]reflect.Value, error) {
+	v := reflect.ValueOf(list)
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("expected a list, got %T", list)
+	}
+	items := make([]reflect.Value, v.Len())
+	for i := range items {
+		items[i] = v.Index(i)
+	}
+	return items, nil
+}
+
+// fieldValue returns the value of a dot-separated field path in a struct, a
+// pointer to a struct or a map with string keys. Nil pointers along the path
+// yield an invalid value, which sorts first.
+func fieldValue(item reflect.Value, path string) (reflect.Value, error) {
+	v := item
+	for _, name := range strings.Split(path, ".") {
+		for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return reflect.Value{}, nil
+			}
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Struct:
+			field, ok := v.Type().FieldByName(name)
+			if !ok || !field.IsExported() {
+				return reflect.Value{}, fmt.Errorf("%s has no field %s", v.Type(), name)
+			}
+			v = v.FieldByIndex(field.Index)
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return reflect.Value{}, fmt.Errorf("%s is not keyed by strings", v.Type())
+			}
+			v = v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+			if !v.IsValid() {
+				return reflect.Value{}, nil
+			}
+		default:
+			return reflect.Value{}, fmt.Errorf("cannot read field %s of %s", name, v.Type())
+		}
+	}
+	return v, nil
+}
+
+// compareValues orders two field values: numbers numerically, booleans false
+// first, and any other value by its fmt.Sprint form.
+func compareValues(a, b reflect.Value) int {
+	for a.IsValid() && a.Kind() == reflect.Interface && !a.IsNil() {
+		a = a.Elem()
+	}
+	for b.IsValid() && b.Kind() == reflect.Interface && !b.IsNil() {
+		b = b.Elem()
+	}
+	if !a.IsValid() || !b.IsValid() {
+		return cmp.Compare(boolInt(a.IsValid()), boolInt(b.IsValid()))
+	}
+	switch {
+	case a.CanInt() && b.CanInt():
+		return cmp.Compare(a.Int(), b.Int())
+	case a.CanUint() && b.CanUint():
+		return cmp.Compare(a.Uint(), b.Uint())
+	case a.CanFloat() && b.CanFloat():
+		return cmp.Compare(a.Float(), b.Float())
+	case a.Kind() == reflect.Bool && b.Kind() == reflect.Bool:
+		return cmp.Compare(boolInt(a.Bool()), boolInt(b.Bool()))
+	}
+	return strings.Compare(fmt.Sprint(a.Interface()), fmt.Sprint(b.Interface()))
+}
+
+// boolInt converts a boolean to 0 or 1 for comparisons.
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,106 @@
+package templates
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFuncMap(t *testing.T) {
+	type Datasource struct {
+		Type string
+	}
+	type Panel struct {
+		ID         int
+		Title      string
+		Type       string
+		Datasource *Datasource
+	}
+	panels := []Panel{
+		{ID: 3, Title: "Latency", Type: "timeseries", Datasource: &Datasource{Type: "prometheus"}},
+		{ID: 1, Title: "Logs", Type: "logs", Datasource: &Datasource{Type: "loki"}},
+		{ID: 2, Title: "Errors", Type: "timeseries"},
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		data     any
+		expected string
+		errorMsg string
+	}{
+		{
+			name:     "join should join the elements with the separator",
+			template: `{{.Tags | join ", "}}|{{join "-" .IDs}}`,
+			data:     map[string]any{"Tags": []string{"a", "b"}, "IDs": []int{1, 2}},
+			expected: "a, b|1-2",
+		}, {
+			name:     "slugify should create an anchor",
+			template: `{{slugify "API / Latency (p99)"}}`,
+			expected: "api-latency-p99",
+		}, {
+			name:     "markdownEscape should escape markdown syntax and newlines",
+			template: `{{markdownEscape "a|b *c* <d>\nnext_line"}}`,
+			expected: `a\|b \*c\* \<d\><br>next\_line`,
+		}, {
+			name:     "codeFence should fence the code with the language",
+			template: `{{codeFence "promql" "sum(up)"}}`,
+			expected: "```promql\nsum(up)\n```",
+		}, {
+			name:     "codeFence should use a longer fence than the code",
+			template: "{{codeFence \"\" \"```\"}}",
+			expected: "````\n```\n````",
+		}, {
+			name:     "default should replace empty values",
+			template: `{{.Empty | default "n/a"}} {{.Set | default "n/a"}} {{.None | default "none"}}`,
+			data:     map[string]any{"Empty": "", "Set": "value", "None": []string{}},
+			expected: "n/a value none",
+		}, {
+			name:     "sortBy should sort by a field",
+			template: `{{range sortBy "ID" .}}{{.Title}} {{end}}`,
+			data:     panels,
+			expected: "Logs Errors Latency ",
+		}, {
+			name:     "sortBy should sort by a nested field with nil pointers first",
+			template: `{{range sortBy "Datasource.Type" .}}{{.Title}} {{end}}`,
+			data:     panels,
+			expected: "Errors Logs Latency ",
+		}, {
+			name:     "groupBy should group by a field in order of appearance",
+			template: `{{range groupBy "Type" .}}{{.Key}}:{{range .Items}} {{.Title}}{{end}};{{end}}`,
+			data:     panels,
+			expected: "timeseries: Latency Errors;logs: Logs;",
+		}, {
+			name:     "panelType should humanize known and plugin panel types",
+			template: `{{panelType "timeseries"}}, {{panelType "nodeGraph"}}, {{panelType "grafana-clock-panel"}}`,
+			expected: "Time series, Node graph, Grafana clock panel",
+		}, {
+			name:     "sortBy should return an error for unknown fields",
+			template: `{{sortBy "Missing" .}}`,
+			data:     panels,
+			errorMsg: "has no field Missing",
+		}, {
+			name:     "join should return an error for non lists",
+			template: `{{join ", " "text"}}`,
+			errorMsg: "expected a list",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl, err := template.New("test").Funcs(FuncMap()).Parse(tc.template)
+			assert.NoError(t, err)
+
+			var buf bytes.Buffer
+			err = tmpl.Execute(&buf, tc.data)
+			if tc.errorMsg != "" {
+				assert.ErrorContains(t, err, tc.errorMsg)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, buf.String())
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package templates
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"log/slog"
+)
+
+var (
+	// siteTemplate contains the html/template definitions of the static HTML
+	// documentation site. Every page is self-contained: styles and scripts are
+	// inlined and the only other file a page loads is the site's search index.
+	// It defines:
+	//   - "index": the list of documented dashboards
+	//   - "dashboard": one page per dashboard, with the prerequisites of
+	//     dashboards exported for sharing externally, a section and an anchor
+	//     per row and per panel, the template variables and the warnings
+	//   - "metrics": every metric with links to the panels using it
+	//
+	// Each page expects a .Root field holding the relative path back to the
+	// site root, used to link pages and load search-index.js.
+	siteTemplate = `{{define "head"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.}}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; margin: 0; color: #1f2328; line-height: 1.5; }
+header { background: #24292f; color: #fff; padding: 0.75rem 2rem; display: flex; gap: 1.5rem; align-items: center; flex-wrap: wrap; }
+header a { color: #fff; text-decoration: none; font-weight: 600; }
+main { padding: 1rem 2rem 3rem; max-width: 80rem; }
+a { color: #0969da; }
+code { background: #f6f8fa; border-radius: 4px; padding: 0.1rem 0.3rem; font-size: 0.9em; word-break: break-word; }
+table { border-collapse: collapse; width: 100%; margin: 1rem 0; }
+th, td { border: 1px solid #d0d7de; padding: 0.4rem 0.6rem; text-align: left; vertical-align: top; }
+th { background: #f6f8fa; }
+.description { white-space: pre-line; color: #57606a; }
+.tag { display: inline-block; background: #ddf4ff; border-radius: 1rem; padding: 0 0.6rem; margin-right: 0.3rem; font-size: 0.85em; }
+.muted { color: #57606a; }
+#search { position: relative; margin-left: auto; }
+#search input { padding: 0.3rem 0.6rem; border-radius: 4px; border: 0; width: 18rem; }
+#search-results { position: absolute; right: 0; background: #fff; border: 1px solid #d0d7de; border-radius: 4px; list-style: none; margin: 0.3rem 0 0; padding: 0; width: 26rem; max-height: 24rem; overflow-y: auto; z-index: 1; }
+#search-results:empty { display: none; }
+#search-results li a { display: block; color: #1f2328; padding: 0.4rem 0.6rem; font-weight: normal; }
+#search-results li a:hover { background: #f6f8fa; }
+</style>
+</head>
+{{end}}
+
+{{define "header"}}<header>
+<a href="{{.Root}}index.html">Dashboards</a>
+<a href="{{.Root}}metrics.html">Metrics</a>
+<div id="search">
+<input type="search" placeholder="Search dashboards, panels and metrics" aria-label="Search" autocomplete="off">
+<ul id="search-results"></ul>
+</div>
+</header>
+{{end}}
+
+{{define "footer"}}<script src="{{.Root}}search-index.js"></script>
+<script>
+(function () {
+  var root = {{.Root}};
+  var input = document.querySelector("#search input");
+  var results = document.getElementById("search-results");
+  input.addEventListener("input", function () {
+    var terms = input.value.toLowerCase().split(/\s+/).filter(Boolean);
+    results.innerHTML = "";
+    if (!terms.length || !window.autodocSearchIndex) {
+      return;
+    }
+    window.autodocSearchIndex.filter(function (entry) {
+      var text = (entry.title + " " + entry.text).toLowerCase();
+      return terms.every(function (term) { return text.indexOf(term) >= 0; });
+    }).slice(0, 50).forEach(function (entry) {
+      var item = document.createElement("li");
+      var link = document.createElement("a");
+      link.href = root + entry.url;
+      link.textContent = entry.kind + ": " + entry.title;
+      item.appendChild(link);
+      results.appendChild(item);
+    });
+  });
+})();
+</script>
+</body>
+</html>
+{{end}}
+
+{{define "index"}}{{template "head" "Dashboards"}}<body>
+{{template "header" .}}<main>
+<h1>Dashboards</h1>
+<table>
+<thead><tr><th>Dashboard</th><th>Description</th><th>Tags</th><th>Panels</th></tr></thead>
+<tbody>
+{{- range .Dashboards}}
+<tr><td><a href="{{.Page}}">{{.Title}}</a><br><span class="muted">{{.File}}</span></td><td class="description">{{.Description}}</td><td>{{range .Tags}}<span class="tag">{{.}}</span>{{end}}</td><td>{{.PanelCount}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+</main>
+{{template "footer" .}}{{end}}
+
+{{define "dashboard"}}{{template "head" .Title}}<body>
+{{template "header" .}}<main>
+<h1>{{.Title}}</h1>
+{{- if .Description}}
+<p class="description">{{.Description}}</p>
+{{- end}}
+{{- if .Tags}}
+<p>{{range .Tags}}<span class="tag">{{.}}</span>{{end}}</p>
+{{- end}}
+{{- if .Rows}}
+<nav><ul>
+{{- if or .Inputs .Requires}}
+<li><a href="#prerequisites">Prerequisites</a></li>
+{{- end}}
+{{- range .Rows}}{{if .Title}}
+<li><a href="#{{.Anchor}}">{{.Title}}</a></li>
+{{- end}}{{end}}
+{{- if .Variables}}
+<li><a href="#variables">Variables</a></li>
+{{- end}}
+{{- if .Warnings}}
+<li><a href="#warnings">Warnings</a></li>
+{{- end}}
+</ul></nav>
+{{- end}}
+{{- if or .Inputs .Requires}}
+<section id="prerequisites">
+<h2><a href="#prerequisites">Prerequisites</a></h2>
+{{- if .Inputs}}
+<p>Inputs to provide when importing the dashboard:</p>
+<table>
+<thead><tr><th>Name</th><th>Label</th><th>Type</th><th>Plugin</th><th>Default</th><th>Description</th></tr></thead>
+<tbody>
+{{- range .Inputs}}
+<tr><td><code>{{printf "${%s}" .Name}}</code></td><td>{{.Label}}</td><td>{{.Type}}</td><td>{{if .PluginName}}{{.PluginName}} ({{.PluginID}}){{else}}{{.PluginID}}{{end}}</td><td>{{if .Value}}<code>{{.Value}}</code>{{end}}</td><td class="description">{{.Description}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+{{- end}}
+{{- if .Requires}}
+<p>Required plugins:</p>
+<table>
+<thead><tr><th>Type</th><th>ID</th><th>Name</th><th>Version</th></tr></thead>
+<tbody>
+{{- range .Requires}}
+<tr><td>{{.Type}}</td><td><code>{{.ID}}</code></td><td>{{.Name}}</td><td>{{.Version}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+{{- end}}
+</section>
+{{- end}}
+{{- range .Rows}}
+<section id="{{.Anchor}}">
+{{- if .Title}}
+<h2><a href="#{{.Anchor}}">{{.Title}}</a>{{if .Collapsed}} <span class="muted">(collapsed)</span>{{end}}</h2>
+{{- end}}
+{{- if .Panels}}
+<table>
+<thead><tr><th>Panel Name</th><th>Panel Description</th><th>Panel Type</th><th>Values</th><th>Metrics Used</th><th>Log Streams</th><th>Other Queries</th><th>Data Pipeline</th></tr></thead>
+<tbody>
+{{- range .Panels}}
+<tr id="{{.Anchor}}"><td><a href="#{{.Anchor}}">{{.Title}}</a></td><td class="description">{{.Description}}</td><td>{{.Type}}</td>
+<td>{{with .FieldConfig}}{{if .Summary}}{{.Summary}}<br>{{end}}{{range .Overrides}}{{.Matcher}}: {{.Summary}}<br>{{end}}{{end}}</td>
+<td>{{range .MetricUsages}}<code>{{.String}}</code>{{if .Matches}} (matches {{range $i, $m := .Matches}}{{if $i}}, {{end}}<code>{{$m}}</code>{{end}}){{end}}<br>{{end}}</td>
+<td>{{range .LogQueries}}{{range .Streams}}<code>{{.}}</code><br>{{end}}{{if .LineFilters}}filters: {{range $i, $f := .LineFilters}}{{if $i}}, {{end}}<code>{{$f}}</code>{{end}}<br>{{end}}{{if .Parsers}}parsers: {{range $i, $p := .Parsers}}{{if $i}}, {{end}}<code>{{$p}}</code>{{end}}<br>{{end}}{{if .LabelFilters}}label filters: {{range $i, $f := .LabelFilters}}{{if $i}}, {{end}}<code>{{$f}}</code>{{end}}<br>{{end}}{{if .Aggregations}}aggregations: {{range $i, $a := .Aggregations}}{{if $i}}, {{end}}<code>{{$a}}</code>{{end}}<br>{{end}}{{end}}</td>
+<td>{{range .Tables}}table <code>{{.}}</code><br>{{end}}{{range .Indices}}index <code>{{.}}</code><br>{{end}}{{range .Queries}}<code>{{.}}</code><br>{{end}}</td>
+<td>{{range $i, $s := .Pipeline}}{{if $i}}<br>→ {{end}}{{$s}}{{end}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+{{- end}}
+</section>
+{{- end}}
+{{- if .Variables}}
+<section id="variables">
+<h2><a href="#variables">Variables</a></h2>
+<table>
+<thead><tr><th>Name</th><th>Label</th><th>Type</th><th>Datasource</th><th>Query</th><th>Default</th><th>Multi</th><th>Include All</th><th>Regex</th><th>Metrics Used</th></tr></thead>
+<tbody>
+{{- range .Variables}}
+<tr><td><code>${{.Name}}</code></td><td>{{.Label}}</td><td>{{.Type}}</td><td>{{with .Datasource}}{{.String}}{{end}}</td><td>{{if .Query}}<code>{{.Query}}</code>{{end}}</td><td>{{.Current}}</td><td>{{.Multi}}</td><td>{{.IncludeAll}}</td><td>{{if .Regex}}<code>{{.Regex}}</code>{{end}}</td><td>{{range .Metrics}}<code>{{.}}</code><br>{{end}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+</section>
+{{- end}}
+{{- if .Warnings}}
+<section id="warnings">
+<h2><a href="#warnings">Warnings</a></h2>
+<p>The following problems were skipped while generating this documentation:</p>
+<ul>
+{{- range .Warnings}}
+<li>{{.Location}}: {{.Message}}</li>
+{{- end}}
+</ul>
+</section>
+{{- end}}
+</main>
+{{template "footer" .}}{{end}}
+
+{{define "metrics"}}{{template "head" "Metrics"}}<body>
+{{template "header" .}}<main>
+<h1>Metrics</h1>
+<table>
+<thead><tr><th>Metric</th><th>Used By</th></tr></thead>
+<tbody>
+{{- range .Metrics}}
+<tr id="{{.Anchor}}"><td><a href="#{{.Anchor}}"><code>{{.Name}}</code></a></td><td>{{range .Panels}}<a href="{{.URL}}">{{.Dashboard}} / {{.Panel}}</a><br>{{end}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+</main>
+{{template "footer" .}}{{end}}`
+)
+
+// GetSiteTemplate creates and returns the parsed html/template set used to
+// generate the static HTML documentation site. The set defines the "index",
+// "dashboard" and "metrics" page templates.
+//
+// Returns:
+//   - *htmltemplate.Template: A parsed template set ready for execution
+//   - error: An error if template parsing fails
+func GetSiteTemplate() (*htmltemplate.Template, error) {
+	tmpl, err := htmltemplate.New("site").Parse(siteTemplate)
+	if err != nil {
+		slog.Error("error generating the html site templates", slog.Any("error", err))
+		return nil, fmt.Errorf("error generating the html site templates: %w", err)
+	}
+
+	return tmpl, nil
+}
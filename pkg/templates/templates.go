@@ -6,6 +6,8 @@ package templates
 import (
 	"fmt"
 	"log/slog"
+	"os"
+	"path/filepath"
 	"text/template"
 )
 
@@ -13,21 +15,98 @@ var (
 	// mdTemplate contains the Go template string for generating markdown documentation
 	// from Grafana dashboard data. It creates a structured table format with:
 	//   - Dashboard title and description as headers
-	//   - A table containing panel information with columns for:
+	//   - A "Prerequisites" section listing the inputs and the plugins, with
+	//     their versions, required to import a dashboard exported for sharing
+	//     externally
+	//   - One section per dashboard row, in on-screen order, headed by the row
+	//     title (panels above the first row are listed without a heading)
+	//   - A table per row containing panel information with columns for:
 	//     * Panel Name
 	//     * Panel Description
 	//     * Panel Type
-	//     * Metrics Used (formatted as inline code blocks)
+	//     * Values (how the values are displayed: unit, decimals, range,
+	//       thresholds, value mappings and color scheme, e.g. "seconds; red
+	//       above 0.5", followed by the field overrides)
+	//     * Metrics Used (formatted as inline code blocks, with the label
+	//       matchers and grouping labels each metric is used with, and the
+	//       known metrics matched by metric name patterns)
+	//     * Log Streams (LogQL stream selectors with their line filters, parser
+	//       stages, label filters and aggregations)
+	//     * Other Queries (tables, indices and raw queries of non-Prometheus
+	//       datasources, formatted as inline code blocks)
+	//     * Data Pipeline (for panels with transformations, the steps from
+	//       the queries through each transformation to the visualization)
+	//   - A "Variables" section listing the dashboard template variables with
+	//     their type, datasource, query, default value and referenced metrics
+	//   - A "Warnings" section listing the problems skipped while documenting
+	//     the dashboard, e.g. queries that could not be parsed
 	//
 	// The template uses Go template syntax with range loops to iterate over
 	// panels and their associated metrics.
 	mdTemplate = `# {{.Title}}
 {{.Description}}
+{{- if or .Inputs .Requires}}
 
-| Panel Name | Panel Description | Panel Type | Metrics Used |
-| ---------- | ----------------- | ---------- | -------- |
+## Prerequisites
+{{- if .Inputs}}
+
+Inputs to provide when importing the dashboard:
+
+| Name | Label | Type | Plugin | Default | Description |
+| ---- | ----- | ---- | ------ | ------- | ----------- |
+{{- range .Inputs}}
+| ` + "`{{printf \"${%s}\" .Name}}`" + ` | {{.Label}} | {{.Type}} | {{.Plugin}} | {{if .Value}}` + "`{{.Value}}`" + `{{end}} | {{.Description}} |
+{{- end}}
+{{- end}}
+{{- if .Requires}}
+
+Required plugins:
+
+| Type | ID | Name | Version |
+| ---- | -- | ---- | ------- |
+{{- range .Requires}}
+| {{.Type}} | {{.ID}} | {{.Name}} | {{.Version}} |
+{{- end}}
+{{- end}}
+{{- end}}
+{{- range .Rows}}
+{{- if .Title}}
+
+## {{.Title}}
+{{- end}}
+{{- if .Panels}}
+
+| Panel Name | Panel Description | Panel Type | Values | Metrics Used | Log Streams | Other Queries | Data Pipeline |
+| ---------- | ----------------- | ---------- | ------ | -------- | ----------- | ------------- | ------------- |
 {{- range .Panels}}
-| {{.Title}} | {{.Description}} | {{.Type}} | {{- range .Metrics}} ` + "`{{.}}`" + `<br> {{- end}} |
+| {{.Title}} | {{.Description}} | {{.Type}} | {{- range .Values}} {{.}}<br> {{- end}} | {{- range .MetricUsages}} ` + "`{{.Selector}}`" + `{{if .Matches}} (matches {{range $i, $m := .Matches}}{{if $i}}, {{end}}` + "`{{$m}}`" + `{{end}}){{end}}<br> {{- end}} | {{- range .LogQueries}}
+{{- range .Streams}} ` + "`{{.}}`" + `<br> {{- end}}
+{{- if .LineFilters}} filters: {{range $i, $f := .LineFilters}}{{if $i}}, {{end}}` + "`{{$f}}`" + `{{end}}<br> {{- end}}
+{{- if .Parsers}} parsers: {{range $i, $p := .Parsers}}{{if $i}}, {{end}}` + "`{{$p}}`" + `{{end}}<br> {{- end}}
+{{- if .LabelFilters}} label filters: {{range $i, $f := .LabelFilters}}{{if $i}}, {{end}}` + "`{{$f}}`" + `{{end}}<br> {{- end}}
+{{- if .Aggregations}} aggregations: {{range $i, $a := .Aggregations}}{{if $i}}, {{end}}` + "`{{$a}}`" + `{{end}}<br> {{- end}}
+{{- end}} | {{- range .Tables}} table ` + "`{{.}}`" + `<br> {{- end}} {{- range .Indices}} index ` + "`{{.}}`" + `<br> {{- end}} {{- range .Queries}} ` + "`{{.}}`" + `<br> {{- end}} | {{- range $i, $s := .Pipeline}}{{if $i}}<br> →{{end}} {{$s}}{{- end}} |
+{{- end}}
+{{- end}}
+{{- end}}
+{{- if .Variables}}
+
+## Variables
+
+| Name | Label | Type | Datasource | Query | Default | Multi | Include All | Regex | Metrics Used |
+| ---- | ----- | ---- | ---------- | ----- | ------- | ----- | ----------- | ----- | ------------ |
+{{- range .Variables}}
+| ` + "`${{.Name}}`" + ` | {{.Label}} | {{.Type}} | {{.Datasource}} | {{if .Query}}` + "`{{.Query}}`" + `{{end}} | {{.Current}} | {{.Multi}} | {{.IncludeAll}} | {{if .Regex}}` + "`{{.Regex}}`" + `{{end}} | {{- range .Metrics}} ` + "`{{.}}`" + `<br> {{- end}} |
+{{- end}}
+{{- end}}
+{{- if .Warnings}}
+
+## Warnings
+
+The following problems were skipped while generating this documentation:
+{{range .Warnings}}
+- {{.Location}}: {{.Message}}
+{{- end}}
 {{- end}}`
 )
 
@@ -40,7 +119,8 @@ var (
 //   - error: An error if template parsing fails
 //
 // The returned template expects data conforming to the MarkdownData structure
-// from the parser package, containing Title, Description, and Panels fields.
+// from the parser package, containing Title, Description, Inputs, Requires,
+// Rows, Variables, and Warnings fields.
 func GetTemplate() (*template.Template, error) {
 	tmpl, err := template.New("markdown").Parse(mdTemplate)
 	if err != nil {
@@ -50,3 +130,47 @@ func GetTemplate() (*template.Template, error) {
 
 	return tmpl, nil
 }
+
+// ParseCustomTemplate parses a user-supplied template replacing the default
+// markdown template, with the helper functions of FuncMap. Every *.tmpl file
+// of the partials directory is parsed into the same template set, so that the
+// template can include them with {{template "name.tmpl" .}} or use the
+// templates they define.
+//
+// Parameters:
+//   - path: path to the template file
+//   - partialsDir: path to a directory of partial templates, or empty
+//
+// Returns:
+//   - *template.Template: A parsed template named after the template file
+//   - error: An error if a template cannot be read or parsed
+//
+// The returned template expects the documentation model of a dashboard, the
+// parser package's DashboardDoc, whose values are not escaped for any format.
+func ParseCustomTemplate(path, partialsDir string) (*template.Template, error) {
+	tmpl := template.New(filepath.Base(path)).Funcs(FuncMap())
+	if partialsDir != "" {
+		info, err := os.Stat(partialsDir)
+		if err != nil || !info.IsDir() {
+			slog.Error("error reading template directory", slog.String("template-dir", partialsDir))
+			return nil, fmt.Errorf("error reading template directory %s: not a directory", partialsDir)
+		}
+		partials, err := filepath.Glob(filepath.Join(partialsDir, "*.tmpl"))
+		if err != nil {
+			return nil, fmt.Errorf("error reading template directory %s: %w", partialsDir, err)
+		}
+		if len(partials) > 0 {
+			if tmpl, err = tmpl.ParseFiles(partials...); err != nil {
+				slog.Error("error parsing partial templates", slog.Any("error", err))
+				return nil, fmt.Errorf("error parsing partial templates: %w", err)
+			}
+		}
+	}
+
+	tmpl, err := tmpl.ParseFiles(path)
+	if err != nil {
+		slog.Error("error parsing custom template", slog.Any("error", err), slog.String("template", path))
+		return nil, fmt.Errorf("error parsing custom template: %w", err)
+	}
+	return tmpl, nil
+}
@@ -1,6 +1,9 @@
 package templates
 
 import (
+	"bytes"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -50,29 +53,157 @@ func TestGetTemplate(t *testing.T) {
 				assert.NotNil(t, tmpl)
 
 				assert.Equal(t, "markdown", tmpl.Name())
+				type MetricUsage struct {
+					Selector string
+					Matches  []string
+				}
+
+				type LogQuery struct {
+					Streams      []string
+					LineFilters  []string
+					Parsers      []string
+					LabelFilters []string
+					Aggregations []string
+				}
+
 				type Panel struct {
-					Title       string
-					Description string
+					Title        string
+					Description  string
+					Type         string
+					Values       []string
+					Metrics      []string
+					MetricUsages []MetricUsage
+					LogQueries   []LogQuery
+					Tables       []string
+					Indices      []string
+					Queries      []string
+					Pipeline     []string
+				}
+
+				type Variable struct {
+					Name       string
+					Label      string
+					Type       string
+					Datasource string
+					Query      string
+					Current    string
+					Multi      bool
+					IncludeAll bool
+					Regex      string
+					Metrics    []string
+				}
+
+				type Row struct {
+					Title     string
+					Collapsed bool
+					Panels    []Panel
+				}
+
+				type Warning struct {
+					Location string
+					Message  string
+				}
+
+				type Input struct {
+					Name        string
+					Label       string
 					Type        string
-					Metrics     []string
+					Plugin      string
+					Value       string
+					Description string
+				}
+
+				type Requirement struct {
+					Type    string
+					ID      string
+					Name    string
+					Version string
 				}
 
 				type TemplateData struct {
 					Title       string
 					Description string
-					Panels      []Panel
+					Inputs      []Input
+					Requires    []Requirement
+					Rows        []Row
+					Variables   []Variable
+					Warnings    []Warning
 				}
 
 				testData := TemplateData{
 					Title:       "Test",
 					Description: "Test Description",
-					Panels: []Panel{
+					Inputs: []Input{
+						{Name: "DS_PROMETHEUS", Label: "Prometheus", Type: "datasource", Plugin: "Prometheus (prometheus)"},
+						{Name: "VAR_ENV", Type: "constant", Value: "prod", Description: "Environment"},
+					},
+					Requires: []Requirement{
+						{Type: "grafana", ID: "grafana", Name: "Grafana", Version: "10.4.0"},
+					},
+					Rows: []Row{
 						{
-							Title:       "Panel1",
-							Description: "Desc1",
-							Type:        "graph",
-							Metrics:     []string{"metric1"},
+							Panels: []Panel{
+								{
+									Title:        "Panel1",
+									Description:  "Desc1",
+									Type:         "graph",
+									Metrics:      []string{"metric1"},
+									MetricUsages: []MetricUsage{{Selector: `metric1{job="a"} by (le)`}, {Selector: `{__name__=~"node_cpu.*"}`, Matches: []string{"node_cpu_a", "node_cpu_b"}}},
+								},
+							},
 						},
+						{
+							Title:     "Row1",
+							Collapsed: true,
+							Panels: []Panel{
+								{
+									Title:        "Panel2",
+									Description:  "Desc2",
+									Type:         "stat",
+									Values:       []string{"seconds; red above 0.5", `field "errors": percent (0-100)`},
+									Metrics:      []string{"metric2"},
+									MetricUsages: []MetricUsage{{Selector: "metric2"}},
+								},
+								{
+									Title: "Logs",
+									Type:  "logs",
+									LogQueries: []LogQuery{
+										{
+											Streams:      []string{`{app="api"}`},
+											LineFilters:  []string{`\|= "error"`, `!= "debug"`},
+											Parsers:      []string{"json"},
+											Aggregations: []string{"sum by (level)", "count_over_time [5m]"},
+										},
+									},
+								},
+								{
+									Title:    "Panel3",
+									Type:     "table",
+									Tables:   []string{"orders"},
+									Indices:  []string{"logs-*"},
+									Queries:  []string{"select 1"},
+									Pipeline: []string{"queries A, B", "merge the query results into one table", "table"},
+								},
+							},
+						},
+						{
+							Title: "Empty Row",
+						},
+					},
+					Variables: []Variable{
+						{
+							Name:       "namespace",
+							Label:      "Namespace",
+							Type:       "query",
+							Datasource: "prometheus",
+							Query:      "label_values(up, namespace)",
+							Current:    "default",
+							Multi:      true,
+							Metrics:    []string{"up"},
+						},
+					},
+					Warnings: []Warning{
+						{Location: `panel "Broken", target A`, Message: "error parsing promql expression: unexpected end of input"},
 					},
 				}
 
@@ -83,11 +214,81 @@ func TestGetTemplate(t *testing.T) {
 				output := result.String()
 				assert.Contains(t, output, "# Test")
 				assert.Contains(t, output, "Test Description")
+				assert.Contains(t, output, "Test Description\n\n## Prerequisites\n\nInputs to provide when importing the dashboard:")
+				assert.Contains(t, output, "| `${DS_PROMETHEUS}` | Prometheus | datasource | Prometheus (prometheus) |  |  |")
+				assert.Contains(t, output, "| `${VAR_ENV}` |  | constant |  | `prod` | Environment |")
+				assert.Contains(t, output, "Required plugins:\n\n| Type | ID | Name | Version |\n| ---- | -- | ---- | ------- |\n| grafana | grafana | Grafana | 10.4.0 |")
+				assert.Less(t, strings.Index(output, "## Prerequisites"), strings.Index(output, "Panel1"))
 				assert.Contains(t, output, "Panel1")
 				assert.Contains(t, output, "Desc1")
 				assert.Contains(t, output, "graph")
-				assert.Contains(t, output, "`metric1`")
+				assert.Contains(t, output, "`metric1{job=\"a\"} by (le)`<br> `{__name__=~\"node_cpu.*\"}` (matches `node_cpu_a`, `node_cpu_b`)<br> |")
+				assert.Contains(t, output, "## Row1\n\n| Panel Name")
+				assert.Contains(t, output, "| Panel2 | Desc2 | stat | seconds; red above 0.5<br> field \"errors\": percent (0-100)<br> | `metric2`<br> | | |")
+				assert.Contains(t, output, "| Logs |  | logs | | | `{app=\"api\"}`<br> filters: `\\|= \"error\"`, `!= \"debug\"`<br> parsers: `json`<br> aggregations: `sum by (level)`, `count_over_time [5m]`<br> | |")
+				assert.Contains(t, output, "| Panel3 |  | table | | | | table `orders`<br> index `logs-*`<br> `select 1`<br> | queries A, B<br> → merge the query results into one table<br> → table |")
+				assert.Contains(t, output, "## Empty Row\n\n## Variables")
+				assert.Less(t, strings.Index(output, "Panel1"), strings.Index(output, "## Row1"))
+				assert.Contains(t, output, "## Variables")
+				assert.Contains(t, output, "| `$namespace` | Namespace | query | prometheus | `label_values(up, namespace)` | default | true | false |  | `up`<br> |")
+				assert.Contains(t, output, "## Warnings\n\nThe following problems were skipped while generating this documentation:\n\n- panel \"Broken\", target A: error parsing promql expression: unexpected end of input")
+				assert.Less(t, strings.Index(output, "## Variables"), strings.Index(output, "## Warnings"))
+			}
+		})
+	}
+}
+
+func TestParseCustomTemplate(t *testing.T) {
+	dir := t.TempDir()
+	partials := filepath.Join(dir, "partials")
+	assert.NoError(t, os.MkdirAll(partials, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(partials, "title.tmpl"), []byte(`# {{.Title | markdownEscape}}`), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(partials, "defs.tmpl"), []byte(`{{define "tags"}}{{join ", " .}}{{end}}`), 0644))
+	main := filepath.Join(dir, "house.tmpl")
+	assert.NoError(t, os.WriteFile(main, []byte(`{{template "title.tmpl" .}} ({{template "tags" .Tags}})`), 0644))
+
+	tests := []struct {
+		name        string
+		path        string
+		partialsDir string
+		expected    string
+		errorMsg    string
+	}{
+		{
+			name:        "template with partials should render",
+			path:        main,
+			partialsDir: partials,
+			expected:    `# My\_Dashboard (a, b)`,
+		}, {
+			name:     "template without its partials should fail to render",
+			path:     main,
+			errorMsg: `template "title.tmpl" not defined`,
+		}, {
+			name:     "missing template should return error",
+			path:     filepath.Join(dir, "missing.tmpl"),
+			errorMsg: "error parsing custom template",
+		}, {
+			name:        "missing partials directory should return error",
+			path:        main,
+			partialsDir: filepath.Join(dir, "missing"),
+			errorMsg:    "error reading template directory",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl, err := ParseCustomTemplate(tc.path, tc.partialsDir)
+			if err == nil {
+				assert.Equal(t, "house.tmpl", tmpl.Name())
+				var buf bytes.Buffer
+				err = tmpl.Execute(&buf, map[string]any{"Title": "My_Dashboard", "Tags": []string{"a", "b"}})
+				if tc.errorMsg == "" {
+					assert.NoError(t, err)
+					assert.Equal(t, tc.expected, buf.String())
+					return
+				}
 			}
+			assert.ErrorContains(t, err, tc.errorMsg)
 		})
 	}
 }
@@ -0,0 +1,272 @@
+package utils
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// MatchGlob reports whether a slash-separated path matches a glob pattern.
+// In addition to the syntax of path.Match, the pattern supports:
+//   - "**" as a whole path segment, matching zero or more directories
+//   - "{a,b}" alternatives, which can be nested
+//
+// Parameters:
+//   - pattern: the glob pattern, e.g. "teams/**/*.json"
+//   - name: the path to match, with forward slashes as separators
+//
+// Returns:
+//   - bool: true if the whole path matches the pattern
+//   - error: path.ErrBadPattern if the pattern is malformed
+//
+// Example:
+//
+//	MatchGlob("**/*.json", "teamA/api/overview.json")     // returns true
+//	MatchGlob("{teamA,teamB}/*.json", "teamC/overview.json") // returns false
+func MatchGlob(pattern, name string) (bool, error) {
+	alternatives, err := expandBraces(pattern)
+	if err != nil {
+		return false, err
+	}
+	for _, alternative := range alternatives {
+		for _, segment := range strings.Split(alternative, "/") {
+			if _, err := path.Match(segment, ""); err != nil {
+				return false, err
+			}
+		}
+	}
+	nameSegments := strings.Split(name, "/")
+	for _, alternative := range alternatives {
+		matched, err := matchSegments(strings.Split(alternative, "/"), nameSegments)
+		if err != nil || matched {
+			return matched, err
+		}
+	}
+	return false, nil
+}
+
+// GlobBase returns the directory a glob pattern is rooted in: its leading path
+// segments that contain no glob characters, or "." if the first segment is a
+// glob.
+//
+// Parameters:
+//   - pattern: the glob pattern, e.g. "dashboards/**/*.json"
+//
+// Returns:
+//   - string: the base directory of the pattern, e.g. "dashboards"
+//
+// Example:
+//
+//	GlobBase("dashboards/*/api.json") // returns "dashboards"
+//	GlobBase("*.json")                // returns "."
+func GlobBase(pattern string) string {
+	segments := strings.Split(filepath.ToSlash(pattern), "/")
+	static := 0
+	for static < len(segments)-1 && !IsGlobPattern(segments[static]) {
+		static++
+	}
+	base := strings.Join(segments[:static], "/")
+	switch {
+	case base == "" && strings.HasPrefix(pattern, "/"):
+		return string(filepath.Separator)
+	case base == "":
+		return "."
+	}
+	return filepath.FromSlash(base)
+}
+
+// Glob returns the names of the regular files matching a glob pattern, with
+// the syntax of MatchGlob. Unlike filepath.Glob, "**" descends into
+// subdirectories. Only the directories that can contain a match are read: a
+// pattern without "**" is never walked deeper than its number of segments,
+// and directories not matching its leading segments are skipped. The names
+// are sorted and start with the base directory of the pattern, see GlobBase.
+//
+// Parameters:
+//   - pattern: the glob pattern, e.g. "dashboards/**/*.json"
+//
+// Returns:
+//   - []string: the matching file names, nil if there are none
+//   - error: path.ErrBadPattern if the pattern is malformed, or an error if a
+//     directory cannot be read
+//
+// Example:
+//
+//	files, err := Glob("dashboards/**/*.json")
+//	// files = ["dashboards/overview.json", "dashboards/teamA/api.json"]
+func Glob(pattern string) ([]string, error) {
+	if _, err := MatchGlob(filepath.ToSlash(pattern), ""); err != nil {
+		return nil, err
+	}
+	base := GlobBase(pattern)
+	if !IsValidDirectory(base) {
+		return nil, nil
+	}
+	prefix := strings.TrimSuffix(filepath.ToSlash(base), "/") + "/"
+	if base == "." {
+		prefix = ""
+	}
+	rest := strings.TrimPrefix(filepath.ToSlash(pattern), prefix)
+	alternatives, err := expandBraces(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	err = filepath.WalkDir(base, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(base, name)
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if rel == "." || canContainMatch(alternatives, strings.Split(filepath.ToSlash(rel), "/")) {
+				return nil
+			}
+			return fs.SkipDir
+		}
+		matched, err := MatchGlob(rest, filepath.ToSlash(rel))
+		if matched {
+			matches = append(matches, name)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(matches)
+	return matches, nil
+}
+
+// RetrieveJSONFilesFromDirectoryRecursive scans a directory and its
+// subdirectories and returns the paths of the JSON files relative to it. Like
+// RetrieveJSONFilesFromDirectory, the extension comparison is case-insensitive.
+//
+// Parameters:
+//   - dirPath: the directory path to scan for JSON files
+//
+// Returns:
+//   - []string: sorted slice of JSON file paths relative to dirPath
+//   - error: error if a directory cannot be read or doesn't exist
+//
+// Example:
+//
+//	files, err := RetrieveJSONFilesFromDirectoryRecursive("./dashboards")
+//	// files = ["overview.json", "teamA/api/latency.json"]
+func RetrieveJSONFilesFromDirectoryRecursive(dirPath string) ([]string, error) {
+	if _, err := os.Stat(dirPath); err != nil {
+		return nil, err
+	}
+	var jsonFiles []string
+	err := filepath.WalkDir(dirPath, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || strings.ToLower(filepath.Ext(name)) != ".json" {
+			return nil
+		}
+		rel, err := filepath.Rel(dirPath, name)
+		if err != nil {
+			return err
+		}
+		jsonFiles = append(jsonFiles, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(jsonFiles)
+	return jsonFiles, nil
+}
+
+// matchSegments matches the segments of a brace-free pattern against the
+// segments of a path, letting a "**" segment consume any number of them.
+func matchSegments(pattern, name []string) (bool, error) {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for skip := 0; skip <= len(name); skip++ {
+				matched, err := matchSegments(pattern[1:], name[skip:])
+				if err != nil || matched {
+					return matched, err
+				}
+			}
+			return false, nil
+		}
+		if len(name) == 0 {
+			return false, nil
+		}
+		matched, err := path.Match(pattern[0], name[0])
+		if err != nil || !matched {
+			return false, err
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0, nil
+}
+
+// canContainMatch reports whether a directory, given by its path segments
+// relative to the base of a pattern, can contain a file matching one of the
+// brace-free alternatives of the pattern: its segments match the leading
+// segments of an alternative that has more segments, or that reaches a "**".
+func canContainMatch(alternatives []string, dir []string) bool {
+	for _, alternative := range alternatives {
+		pattern, name := strings.Split(alternative, "/"), dir
+		for len(name) > 0 && len(pattern) > 0 && pattern[0] != "**" {
+			if matched, err := path.Match(pattern[0], name[0]); err != nil || !matched {
+				break
+			}
+			pattern, name = pattern[1:], name[1:]
+		}
+		if len(pattern) > 0 && (len(name) == 0 || pattern[0] == "**") {
+			return true
+		}
+	}
+	return false
+}
+
+// expandBraces expands the first, outermost {a,b} alternatives of a pattern,
+// recursively, into the list of brace-free patterns it stands for.
+func expandBraces(pattern string) ([]string, error) {
+	open := strings.IndexByte(pattern, '{')
+	if open < 0 {
+		if strings.IndexByte(pattern, '}') >= 0 {
+			return nil, path.ErrBadPattern
+		}
+		return []string{pattern}, nil
+	}
+
+	depth, last := 0, open+1
+	var options []string
+	for i := open; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '{':
+			depth++
+		case ',':
+			if depth == 1 {
+				options = append(options, pattern[last:i])
+				last = i + 1
+			}
+		case '}':
+			depth--
+			if depth > 0 {
+				continue
+			}
+			options = append(options, pattern[last:i])
+			var expanded []string
+			for _, option := range options {
+				alternatives, err := expandBraces(pattern[:open] + option + pattern[i+1:])
+				if err != nil {
+					return nil, err
+				}
+				expanded = append(expanded, alternatives...)
+			}
+			return expanded, nil
+		}
+	}
+	return nil, path.ErrBadPattern
+}
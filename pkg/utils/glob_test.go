@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		name        string
+		pattern     string
+		path        string
+		expected    bool
+		expectError bool
+	}{
+		{name: "star should match within a directory", pattern: "*.json", path: "overview.json", expected: true},
+		{name: "star should not match across directories", pattern: "*.json", path: "teamA/overview.json", expected: false},
+		{name: "doublestar should match nested directories", pattern: "**/*.json", path: "teamA/api/overview.json", expected: true},
+		{name: "doublestar should match zero directories", pattern: "**/*.json", path: "overview.json", expected: true},
+		{name: "doublestar in the middle should match nested directories", pattern: "teamA/**/overview.json", path: "teamA/api/v1/overview.json", expected: true},
+		{name: "trailing doublestar should match everything below", pattern: "teamA/**", path: "teamA/api/overview.json", expected: true},
+		{name: "trailing doublestar should not match other directories", pattern: "teamA/**", path: "teamB/overview.json", expected: false},
+		{name: "braces should match any alternative", pattern: "{teamA,teamB}/*.json", path: "teamB/overview.json", expected: true},
+		{name: "nested braces should match any alternative", pattern: "*-{test,stag{e,ing}}.json", path: "api-staging.json", expected: true},
+		{name: "braces should not match other values", pattern: "{teamA,teamB}/*.json", path: "teamC/overview.json", expected: false},
+		{name: "unclosed brace should return error", pattern: "{teamA,teamB/*.json", path: "teamA/overview.json", expectError: true},
+		{name: "unopened brace should return error", pattern: "teamA}/*.json", path: "teamA/overview.json", expectError: true},
+		{name: "malformed class should return error even if the path does not reach it", pattern: "teamA/[a-", path: "teamB", expectError: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			matched, err := MatchGlob(tc.pattern, tc.path)
+			if tc.expectError {
+				assert.ErrorIs(t, err, path.ErrBadPattern)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, matched)
+		})
+	}
+}
+
+func TestGlobBase(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		expected string
+	}{
+		{pattern: "dashboards/**/*.json", expected: "dashboards"},
+		{pattern: "dashboards/teamA/*.json", expected: filepath.Join("dashboards", "teamA")},
+		{pattern: "dashboards/{teamA,teamB}/*.json", expected: "dashboards"},
+		{pattern: "*.json", expected: "."},
+		{pattern: "/srv/dashboards/*.json", expected: filepath.FromSlash("/srv/dashboards")},
+		{pattern: "/*.json", expected: string(filepath.Separator)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.pattern, func(t *testing.T) {
+			assert.Equal(t, tc.expected, GlobBase(tc.pattern))
+		})
+	}
+}
+
+func TestGlob(t *testing.T) {
+	tempDir := t.TempDir()
+	for _, file := range []string{
+		"overview.json",
+		"teamA/api/latency.json",
+		"teamA/api/readme.md",
+		"teamB/overview.json",
+	} {
+		path := filepath.Join(tempDir, file)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, nil, 0644))
+	}
+
+	tests := []struct {
+		name     string
+		pattern  string
+		expected []string
+	}{
+		{
+			name:     "doublestar should match files at any depth",
+			pattern:  "**/*.json",
+			expected: []string{"overview.json", "teamA/api/latency.json", "teamB/overview.json"},
+		},
+		{
+			name:     "star should only match the top level",
+			pattern:  "*.json",
+			expected: []string{"overview.json"},
+		},
+		{
+			name:     "braces should match every alternative",
+			pattern:  "{teamA/**,teamB}/*.json",
+			expected: []string{"teamA/api/latency.json", "teamB/overview.json"},
+		},
+		{
+			name:    "missing base directory should match nothing",
+			pattern: "teamC/**/*.json",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			matches, err := Glob(filepath.Join(tempDir, tc.pattern))
+			assert.NoError(t, err)
+			var expected []string
+			for _, file := range tc.expected {
+				expected = append(expected, filepath.Join(tempDir, file))
+			}
+			assert.Equal(t, expected, matches)
+		})
+	}
+
+	t.Run("malformed pattern should return error", func(t *testing.T) {
+		_, err := Glob(filepath.Join(tempDir, "[a-/*.json"))
+		assert.ErrorIs(t, err, path.ErrBadPattern)
+	})
+
+	t.Run("unreadable directories the pattern cannot match should be skipped", func(t *testing.T) {
+		if os.Geteuid() == 0 {
+			t.Skip("directory permissions are not enforced for root")
+		}
+		private := filepath.Join(tempDir, "private")
+		assert.NoError(t, os.Mkdir(private, 0))
+		t.Cleanup(func() { _ = os.Chmod(private, 0755) })
+
+		matches, err := Glob(filepath.Join(tempDir, "teamB", "*.json"))
+		assert.NoError(t, err)
+		assert.Len(t, matches, 1)
+		matches, err = Glob(filepath.Join(tempDir, "*.json"))
+		assert.NoError(t, err)
+		assert.Len(t, matches, 1)
+	})
+}
+
+func TestCanContainMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		dir      string
+		expected bool
+	}{
+		{name: "directory matching a leading segment should be walked", pattern: "teamA/*.json", dir: "teamA", expected: true},
+		{name: "directory as deep as the pattern should be skipped", pattern: "*/*.json", dir: "teamA/api", expected: false},
+		{name: "directory not matching a leading segment should be skipped", pattern: "teamA/*.json", dir: "teamB", expected: false},
+		{name: "directory below a doublestar should be walked", pattern: "teamA/**/*.json", dir: "teamA/api/v1", expected: true},
+		{name: "directory matching any alternative should be walked", pattern: "{teamA/api,teamB}/*.json", dir: "teamA/api", expected: true},
+		{name: "top level pattern should skip every directory", pattern: "*.json", dir: "teamA", expected: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			alternatives, err := expandBraces(tc.pattern)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, canContainMatch(alternatives, strings.Split(tc.dir, "/")))
+		})
+	}
+}
+
+func TestRetrieveJSONFilesFromDirectoryRecursive(t *testing.T) {
+	tempDir := t.TempDir()
+	for _, file := range []string{"root.json", "teamA/api/latency.JSON", "teamA/readme.md", "teamB/overview.json"} {
+		path := filepath.Join(tempDir, file)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, nil, 0644))
+	}
+
+	files, err := RetrieveJSONFilesFromDirectoryRecursive(tempDir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"root.json", filepath.Join("teamA", "api", "latency.JSON"), filepath.Join("teamB", "overview.json")}, files)
+
+	_, err = RetrieveJSONFilesFromDirectoryRecursive(filepath.Join(tempDir, "missing"))
+	assert.Error(t, err)
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"unicode"
 
 	"github.com/hashicorp/go-multierror"
 )
@@ -170,3 +171,33 @@ func RetrieveJSONFilesFromDirectory(dirPath string) ([]string, error) {
 	}
 	return jsonFiles, nil
 }
+
+// Slugify converts a string into a URL and file name friendly slug. Letters and
+// digits are lowercased and kept, every other run of characters is replaced by
+// a single hyphen, and leading and trailing hyphens are removed.
+//
+// Parameters:
+//   - s: the string to convert, e.g. a dashboard or row title
+//
+// Returns:
+//   - string: the slug, empty if s contains no letters or digits
+//
+// Example:
+//
+//	Slugify("API / Latency (p99)") // returns "api-latency-p99"
+func Slugify(s string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+			continue
+		}
+		hyphen = true
+	}
+	return b.String()
+}
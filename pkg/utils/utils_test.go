@@ -628,3 +628,38 @@ func TestRetrieveJSONFilesFromDirectory(t *testing.T) {
 		})
 	}
 }
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "title with spaces and punctuation. should return hyphenated lowercase slug",
+			input:    "API / Latency (p99)",
+			expected: "api-latency-p99",
+		},
+		{
+			name:     "leading and trailing separators. should be trimmed",
+			input:    "  --Node Exporter--  ",
+			expected: "node-exporter",
+		},
+		{
+			name:     "unicode letters. should be kept",
+			input:    "Übersicht Café",
+			expected: "übersicht-café",
+		},
+		{
+			name:     "no letters or digits. should return empty string",
+			input:    "!!!",
+			expected: "",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Slugify(tc.input))
+		})
+	}
+}